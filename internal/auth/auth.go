@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// User аутентифицированный пользователь запроса
+type User struct {
+	ID    string
+	Admin bool // администратор видит и возвращает любые платежи
+}
+
+// TokenValidator проверка токена, возвращает id пользователя
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (string, error)
+}
+
+type userKey struct{}
+
+// WithUser контекст с пользователем
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext пользователь из контекста
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userKey{}).(User)
+	return user, ok
+}
+
+// CanAccess пользователь из контекста является одной из сторон или администратором
+func CanAccess(ctx context.Context, userIDs ...string) bool {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return user.Admin || slices.Contains(userIDs, user.ID)
+}
+
+// UnaryServerInterceptor проверка bearer-токена из метаданных через сервис авторизации.
+// Методы из public вызываются без токена.
+func UnaryServerInterceptor(validator TokenValidator, admins []string, logger *zap.Logger, public ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if slices.Contains(public, info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate(ctx, validator, admins, logger, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor то же, что UnaryServerInterceptor, для потоковых методов
+func StreamServerInterceptor(validator TokenValidator, admins []string, logger *zap.Logger, public ...string) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if slices.Contains(public, info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		ctx, err := authenticate(stream.Context(), validator, admins, logger, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream поток с пользователем в контексте
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate контекст с пользователем, которому выдан токен из метаданных
+func authenticate(ctx context.Context, validator TokenValidator, admins []string, logger *zap.Logger, method string) (context.Context, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	userID, err := validator.ValidateToken(ctx, token)
+	if err != nil {
+		logger.Warn("Rejected request with invalid token", zap.String("method", method), zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return WithUser(ctx, User{ID: userID, Admin: slices.Contains(admins, userID)}), nil
+}
+
+// bearerToken токен из заголовка authorization: Bearer <token>
+func bearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", errors.New("missing metadata")
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", errors.New("missing authorization header")
+	}
+
+	scheme, token, found := strings.Cut(values[0], " ")
+	if !found || !strings.EqualFold(scheme, "bearer") || strings.TrimSpace(token) == "" {
+		return "", errors.New("authorization header must be a bearer token")
+	}
+	return strings.TrimSpace(token), nil
+}
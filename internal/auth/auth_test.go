@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type mockValidator struct {
+	tokens map[string]string
+}
+
+func (m *mockValidator) ValidateToken(ctx context.Context, token string) (string, error) {
+	if userID, ok := m.tokens[token]; ok {
+		return userID, nil
+	}
+	return "", errors.New("invalid token")
+}
+
+func callInterceptor(t *testing.T, ctx context.Context, method string) (User, error) {
+	t.Helper()
+	interceptor := UnaryServerInterceptor(
+		&mockValidator{tokens: map[string]string{"user-token": "user-1", "admin-token": "admin-1"}},
+		[]string{"admin-1"},
+		zap.NewNop(),
+		"/payment.PaymentService/Public",
+	)
+
+	var user User
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		user, _ = UserFromContext(ctx)
+		return nil, nil
+	})
+	return user, err
+}
+
+func withToken(header string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", header))
+}
+
+func TestUnaryServerInterceptor_ValidToken(t *testing.T) {
+	user, err := callInterceptor(t, withToken("Bearer user-token"), "/payment.PaymentService/GetPayment")
+	require.NoError(t, err)
+	assert.Equal(t, User{ID: "user-1"}, user)
+
+	user, err = callInterceptor(t, withToken("bearer admin-token"), "/payment.PaymentService/GetPayment")
+	require.NoError(t, err)
+	assert.Equal(t, User{ID: "admin-1", Admin: true}, user)
+}
+
+func TestUnaryServerInterceptor_Rejects(t *testing.T) {
+	for name, ctx := range map[string]context.Context{
+		"no metadata":   context.Background(),
+		"no header":     metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+		"not bearer":    withToken("Basic user-token"),
+		"invalid token": withToken("Bearer wrong"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := callInterceptor(t, ctx, "/payment.PaymentService/GetPayment")
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+}
+
+func TestUnaryServerInterceptor_PublicMethod(t *testing.T) {
+	user, err := callInterceptor(t, context.Background(), "/payment.PaymentService/Public")
+	require.NoError(t, err)
+	assert.Empty(t, user.ID)
+}
+
+func TestCanAccess(t *testing.T) {
+	assert.False(t, CanAccess(context.Background(), "user-1"))
+
+	ctx := WithUser(context.Background(), User{ID: "user-1"})
+	assert.True(t, CanAccess(ctx, "user-2", "user-1"))
+	assert.False(t, CanAccess(ctx, "user-2"))
+
+	admin := WithUser(context.Background(), User{ID: "admin", Admin: true})
+	assert.True(t, CanAccess(admin, "user-2"))
+}
+
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (m *mockServerStream) Context() context.Context {
+	return m.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(&mockValidator{tokens: map[string]string{"user-token": "user-1"}}, nil, zap.NewNop())
+	info := &grpc.StreamServerInfo{FullMethod: "/payment.PaymentService/WatchPayment"}
+
+	var user User
+	err := interceptor(nil, &mockServerStream{ctx: withToken("Bearer user-token")}, info, func(srv any, stream grpc.ServerStream) error {
+		user, _ = UserFromContext(stream.Context())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", user.ID)
+
+	err = interceptor(nil, &mockServerStream{ctx: withToken("Bearer wrong")}, info, func(srv any, stream grpc.ServerStream) error {
+		return nil
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"go.uber.org/zap"
+)
+
+// Типы брокеров, которые можно выбрать в конфигурации
+const (
+	TypeMemory = "memory"
+	TypeNATS   = "nats"
+	TypeKafka  = "kafka"
+)
+
+// Message доменное событие, готовое к отправке
+type Message struct {
+	ID   string // стабильный id события, по нему потребители отбрасывают дубли
+	Type string // тип события, например payment.paid
+	Key  string // id платежа, события одного платежа попадают в одну партицию
+	Body []byte // JSON-конверт события
+}
+
+// Broker брокер сообщений, в который релей outbox публикует события.
+// Publish возвращает nil только после того, как брокер принял сообщение.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// New создание брокера по типу из конфигурации
+func New(ctx context.Context, cfg config.Broker, logger *zap.Logger) (Broker, error) {
+	switch cfg.Type {
+	case TypeMemory, "":
+		logger.Warn("Using in-process broker, domain events are not delivered to other services")
+		return NewMemoryBroker(), nil
+	case TypeNATS:
+		return NewNATSBroker(ctx, cfg)
+	case TypeKafka:
+		return NewKafkaBroker(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown broker type: %s", cfg.Type)
+	}
+}
+
+var errBrokerClosed = errors.New("broker is closed")
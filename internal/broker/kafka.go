@@ -0,0 +1,43 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+)
+
+// KafkaBroker публикация в топик Subject. Ключ сообщения - id платежа, поэтому события
+// одного платежа попадают в одну партицию по порядку. Id и тип события передаются в заголовках.
+type KafkaBroker struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaBroker(cfg config.Broker) *KafkaBroker {
+	return &KafkaBroker{writer: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers...),
+		Topic:        cfg.Subject,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll, // событие не теряется при падении лидера партиции
+	}}
+}
+
+func (b *KafkaBroker) Publish(ctx context.Context, msg Message) error {
+	err := b.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(msg.Key),
+		Value: msg.Body,
+		Headers: []kafka.Header{
+			{Key: "event-id", Value: []byte(msg.ID)},
+			{Key: "event-type", Value: []byte(msg.Type)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to kafka: %w", err)
+	}
+	return nil
+}
+
+func (b *KafkaBroker) Close() error {
+	return b.writer.Close()
+}
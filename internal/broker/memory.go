@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker брокер внутри процесса для тестов и локального запуска.
+// Сообщения получают только подписчики этого же процесса.
+type MemoryBroker struct {
+	mu          sync.RWMutex
+	subscribers []chan Message
+	closed      bool
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+// Subscribe подписка на все последующие сообщения. Publish ждет, пока подписчик освободит буфер
+func (b *MemoryBroker) Subscribe(buffer int) <-chan Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Message, buffer)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers = append(b.subscribers, ch)
+	return ch
+}
+
+// Publish доставка сообщения всем подписчикам, без подписчиков сообщение отбрасывается
+func (b *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return errBrokerClosed
+	}
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close закрытие каналов подписчиков
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		for _, ch := range b.subscribers {
+			close(ch)
+		}
+	}
+	return nil
+}
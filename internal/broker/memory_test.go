@@ -0,0 +1,33 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker_DeliversToSubscribers(t *testing.T) {
+	b := NewMemoryBroker()
+	first, second := b.Subscribe(1), b.Subscribe(1)
+
+	msg := Message{ID: "event-1", Type: "payment.paid", Key: "payment-1", Body: []byte(`{}`)}
+	assert.NoError(t, b.Publish(context.Background(), msg))
+	assert.Equal(t, msg, <-first)
+	assert.Equal(t, msg, <-second)
+
+	assert.NoError(t, b.Close())
+	_, ok := <-first
+	assert.False(t, ok)
+	assert.Error(t, b.Publish(context.Background(), msg))
+}
+
+func TestMemoryBroker_PublishWaitsForSlowSubscriber(t *testing.T) {
+	b := NewMemoryBroker()
+	b.Subscribe(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.Publish(ctx, Message{ID: "event-1"}), context.DeadlineExceeded)
+}
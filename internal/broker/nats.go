@@ -0,0 +1,57 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+)
+
+// NATSBroker публикация в JetStream. Subject события - <Subject>.<тип события>,
+// id события передается в Nats-Msg-Id, и JetStream сам отбрасывает повторы в окне дедупликации.
+type NATSBroker struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewNATSBroker подключение к NATS и создание стрима событий, если его еще нет
+func NewNATSBroker(ctx context.Context, cfg config.Broker) (*NATSBroker, error) {
+	conn, err := nats.Connect(cfg.NATSURL, nats.Name("payment-service"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.NATSStream,
+		Subjects: []string{cfg.Subject + ".>"},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create nats stream %s: %w", cfg.NATSStream, err)
+	}
+	return &NATSBroker{conn: conn, js: js, subject: cfg.Subject}, nil
+}
+
+func (b *NATSBroker) Publish(ctx context.Context, msg Message) error {
+	natsMsg := &nats.Msg{
+		Subject: b.subject + "." + msg.Type,
+		Data:    msg.Body,
+		Header:  nats.Header{"Payment-Id": []string{msg.Key}},
+	}
+	if _, err := b.js.PublishMsg(ctx, natsMsg, jetstream.WithMsgID(msg.ID)); err != nil {
+		return fmt.Errorf("failed to publish to nats: %w", err)
+	}
+	return nil
+}
+
+// Close дожидается отправки буферизованных сообщений
+func (b *NATSBroker) Close() error {
+	return b.conn.Drain()
+}
@@ -2,10 +2,19 @@ package clients
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure" // Import insecure package
 
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
 	pb "gitlab.crja72.ru/gospec/go8/payment/internal/payment-service/proto"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 )
 
 type AuthClient struct {
@@ -14,7 +23,11 @@ type AuthClient struct {
 }
 
 func NewAuthClient(grpcServerAddress string) (*AuthClient, error) {
-	conn, err := grpc.NewClient(grpcServerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(grpcServerAddress,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor("auth")),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +39,20 @@ func NewAuthClient(grpcServerAddress string) (*AuthClient, error) {
 	}, nil
 }
 
+// Check состояние подключения к сервису авторизации для health-проверки
+func (a *AuthClient) Check(ctx context.Context) error {
+	state := a.conn.GetState()
+	switch state {
+	case connectivity.Idle:
+		a.conn.Connect() // подключение ленивое, без вызовов оно так и останется в Idle
+		return nil
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return fmt.Errorf("auth connection is %s", state)
+	default:
+		return nil
+	}
+}
+
 func (a *AuthClient) Close() {
 	if a.conn != nil {
 		a.conn.Close()
@@ -39,3 +66,54 @@ func (a *AuthClient) GetUserById(ctx context.Context, id string) (*pb.GetUserByI
 	}
 	return a.client.GetUserById(ctx, request)
 }
+
+// WalletResolver кошелек пользователя, на который переводятся выплаты и возвраты
+type WalletResolver interface {
+	WalletID(ctx context.Context, userID string) (string, error)
+}
+
+// WalletID кошелек YooMoney пользователя
+func (a *AuthClient) WalletID(ctx context.Context, userID string) (string, error) {
+	user, err := a.GetUserById(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return user.YoomoneyId, nil
+}
+
+// ErrInvalidToken токен не прошел проверку или в нем нет id пользователя
+var ErrInvalidToken = errors.New("invalid token")
+
+// ValidateToken проверка токена в сервисе авторизации, возвращает id пользователя.
+// Сервис авторизации id не возвращает, поэтому после успешной проверки он берется из claims токена.
+func (a *AuthClient) ValidateToken(ctx context.Context, token string) (string, error) {
+	if _, err := a.client.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: token}); err != nil {
+		return "", err
+	}
+	return userIDFromToken(token)
+}
+
+// userIDFromToken id пользователя из claims JWT, подпись уже проверена сервисом авторизации
+func userIDFromToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	for _, claim := range []string{"sub", "user_id", "id"} {
+		if userID, ok := claims[claim].(string); ok && userID != "" {
+			return userID, nil
+		}
+	}
+	return "", ErrInvalidToken
+}
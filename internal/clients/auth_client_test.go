@@ -2,8 +2,10 @@ package clients
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"net"
+	"strings"
 	"testing"
 
 	"google.golang.org/grpc"
@@ -40,6 +42,19 @@ func (m *MockAuthServer) GetUserById(ctx context.Context, req *proto.GetUserById
 	return nil, errors.New("user not found")
 }
 
+func (m *MockAuthServer) ValidateToken(ctx context.Context, req *proto.ValidateTokenRequest) (*proto.ValidateTokenResponse, error) {
+	if strings.HasSuffix(req.Token, ".valid-signature") {
+		return &proto.ValidateTokenResponse{}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// testToken JWT с заданными claims, подпись проверяет только мок сервиса авторизации
+func testToken(claims string, signature string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + "." + signature
+}
+
 func bufDialer(ctx context.Context, s string) (net.Conn, error) {
 	return listener.Dial()
 }
@@ -85,3 +100,28 @@ func TestAuthClient_GetUserById_InvalidID(t *testing.T) {
 	assert.Nil(t, response)
 	assert.Contains(t, err.Error(), "user not found")
 }
+
+func TestAuthClient_ValidateToken(t *testing.T) {
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	authClient := &AuthClient{
+		client: proto.NewAuthClient(conn),
+		conn:   conn,
+	}
+
+	userID, err := authClient.ValidateToken(context.Background(), testToken(`{"sub":"user-1"}`, "valid-signature"))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	userID, err = authClient.ValidateToken(context.Background(), testToken(`{"user_id":"user-2"}`, "valid-signature"))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-2", userID)
+
+	_, err = authClient.ValidateToken(context.Background(), testToken(`{"sub":"user-1"}`, "forged"))
+	assert.Error(t, err)
+
+	_, err = authClient.ValidateToken(context.Background(), testToken(`{"name":"no id"}`, "valid-signature"))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
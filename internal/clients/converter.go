@@ -1,16 +1,26 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-const baseURL = "https://api.fastforex.io/convert"
+const (
+	baseURL     = "https://api.fastforex.io/convert"
+	fetchOneURL = "https://api.fastforex.io/fetch-one"
+
+	// ForexSourceName имя источника курсов FastForex
+	ForexSourceName = "fastforex"
+)
 
 type ForexClient struct {
 	APIKey string
@@ -24,10 +34,17 @@ type ConversionResponse struct {
 	Ms     int64              `json:"ms"`
 }
 
+type fetchOneResponse struct {
+	Base    string             `json:"base"`
+	Result  map[string]float64 `json:"result"`
+	Updated string             `json:"updated"`
+	Error   string             `json:"error"`
+}
+
 func NewForexClient(cfg *config.Config) *ForexClient {
 	return &ForexClient{
 		APIKey: cfg.Forex.Key,
-		Client: &http.Client{Timeout: 10 * time.Second},
+		Client: &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(metrics.NewRoundTripper(ForexSourceName, nil))},
 	}
 }
 
@@ -61,7 +78,61 @@ func (fc *ForexClient) ConvertCurrency(from string, to string, amount float64) (
 		return -1, err
 	}
 
-	return response.Result[to], nil
+	result, ok := response.Result[to]
+	if !ok {
+		return -1, &UnknownCurrencyError{Currency: to}
+	}
+
+	return result, nil
+}
+
+// Name имя источника курсов
+func (fc *ForexClient) Name() string {
+	return ForexSourceName
+}
+
+// Rate курс from->to, время берется из поля updated ответа
+func (fc *ForexClient) Rate(ctx context.Context, from, to string) (ExchangeRate, error) {
+	url := fmt.Sprintf("%s?from=%s&to=%s&api_key=%s", fetchOneURL, from, to, fc.APIKey)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+
+	resp, err := fc.Client.Do(req)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+
+	var response fetchOneResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return ExchangeRate{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK { // на неизвестную валюту fastforex отвечает 400 с текстом ошибки
+		if resp.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(response.Error), "currency") {
+			return ExchangeRate{}, &UnknownCurrencyError{Currency: from}
+		}
+		return ExchangeRate{}, fmt.Errorf("error: received non-OK HTTP status %d", resp.StatusCode)
+	}
+
+	rate, ok := response.Result[to]
+	if !ok {
+		return ExchangeRate{}, &UnknownCurrencyError{Currency: to}
+	}
+
+	timestamp, err := time.Parse(time.DateTime, response.Updated)
+	if err != nil {
+		timestamp = time.Now()
+	}
+
+	return ExchangeRate{From: from, To: to, Rate: rate, Source: ForexSourceName, Timestamp: timestamp.UTC()}, nil
 }
 
 // ConvertToRub конвертер валют в рубли
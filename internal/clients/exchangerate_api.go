@@ -0,0 +1,88 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// ExchangeRateAPISourceName имя запасного источника курсов open.er-api.com
+const ExchangeRateAPISourceName = "open.er-api"
+
+// ExchangeRateAPIClient запасной источник курсов, работает без ключа
+type ExchangeRateAPIClient struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+type exchangeRateAPIResponse struct {
+	Result             string             `json:"result"`
+	ErrorType          string             `json:"error-type"`
+	BaseCode           string             `json:"base_code"`
+	TimeLastUpdateUnix int64              `json:"time_last_update_unix"`
+	Rates              map[string]float64 `json:"rates"`
+}
+
+func NewExchangeRateAPIClient(cfg *config.Config) *ExchangeRateAPIClient {
+	return &ExchangeRateAPIClient{
+		BaseURL: cfg.Rates.FallbackURL,
+		Client:  &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(metrics.NewRoundTripper(ExchangeRateAPISourceName, nil))},
+	}
+}
+
+// Name имя источника курсов
+func (c *ExchangeRateAPIClient) Name() string {
+	return ExchangeRateAPISourceName
+}
+
+// Rate курс from->to из последней публикации источника
+func (c *ExchangeRateAPIClient) Rate(ctx context.Context, from, to string) (ExchangeRate, error) {
+	url := fmt.Sprintf("%s/latest/%s", c.BaseURL, from)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+
+	var response exchangeRateAPIResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return ExchangeRate{}, err
+	}
+
+	if response.ErrorType == "unsupported-code" {
+		return ExchangeRate{}, &UnknownCurrencyError{Currency: from}
+	}
+	if resp.StatusCode != http.StatusOK || response.Result != "success" {
+		return ExchangeRate{}, fmt.Errorf("error: status %d, result %q, error %q", resp.StatusCode, response.Result, response.ErrorType)
+	}
+
+	rate, ok := response.Rates[to]
+	if !ok {
+		return ExchangeRate{}, &UnknownCurrencyError{Currency: to}
+	}
+
+	return ExchangeRate{
+		From:      from,
+		To:        to,
+		Rate:      rate,
+		Source:    ExchangeRateAPISourceName,
+		Timestamp: time.Unix(response.TimeLastUpdateUnix, 0).UTC(),
+	}, nil
+}
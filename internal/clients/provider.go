@@ -0,0 +1,108 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+// Статусы операций, которые возвращают платежные шлюзы
+const (
+	ProviderStatusSuccess = "success"
+	ProviderStatusPending = "pending"
+	ProviderStatusFailed  = "failed"
+	ProviderStatusError   = "error"
+)
+
+// ErrProviderRejected шлюз отказал в операции, деньги точно не ушли.
+// Остальные ошибки (таймаут, обрыв соединения, 5xx) не говорят, выполнена ли операция
+var ErrProviderRejected = errors.New("provider rejected operation")
+
+// PaymentProvider платежный шлюз, через который проходят деньги платежа
+type PaymentProvider interface {
+	// Name имя шлюза, под которым он хранится в платеже и реестре
+	Name() string
+	// CreateCheckout ссылка на оплату платежа на сумму amount в валюте шлюза
+	CreateCheckout(ctx context.Context, payment *models.Payment, amount models.Money) (string, error)
+	// CheckStatus статус оплаты платежа и сумма, зачисленная на основной счет за вычетом комиссии шлюза
+	CheckStatus(ctx context.Context, payment *models.Payment) (string, models.Money, error)
+	// Payout перевод средств платежа получателю
+	Payout(ctx context.Context, payment *models.Payment, receiver string) (string, error)
+	// Refund возврат суммы refund отправителю платежа, возвращает идентификатор операции в шлюзе.
+	// Отказ шлюза оборачивает ErrProviderRejected
+	Refund(ctx context.Context, payment *models.Payment, refund *models.Refund, receiver string) (string, error)
+}
+
+// Направления операций в истории шлюза
+const (
+	OperationIn  = "in"  // поступление на основной счет
+	OperationOut = "out" // списание с основного счета: выплата или возврат
+)
+
+// ProviderOperation операция из истории шлюза
+type ProviderOperation struct {
+	ID        string
+	Label     string // метка, которую сервис передал при создании операции
+	Direction string
+	Status    string // ProviderStatus*
+	Amount    models.Money
+	Time      time.Time
+}
+
+// OperationHistory шлюз, который отдает историю операций для сверки
+type OperationHistory interface {
+	// Operations операции основного счета за окно [from, to)
+	Operations(ctx context.Context, from, to time.Time) ([]ProviderOperation, error)
+}
+
+// ProviderRegistry реестр платежных шлюзов по имени
+type ProviderRegistry struct {
+	providers   map[string]PaymentProvider
+	defaultName string
+}
+
+// NewProviderRegistry создание реестра, defaultName используется для платежей без явного шлюза
+func NewProviderRegistry(defaultName string, providers ...PaymentProvider) (*ProviderRegistry, error) {
+	r := &ProviderRegistry{
+		providers:   make(map[string]PaymentProvider, len(providers)),
+		defaultName: defaultName,
+	}
+	for _, provider := range providers {
+		r.providers[provider.Name()] = provider
+	}
+	if _, ok := r.providers[defaultName]; !ok {
+		return nil, fmt.Errorf("default payment provider %q is not registered", defaultName)
+	}
+	return r, nil
+}
+
+// Get получение шлюза по имени, пустое имя означает шлюз по умолчанию
+func (r *ProviderRegistry) Get(name string) (PaymentProvider, error) {
+	if name == "" {
+		name = r.defaultName
+	}
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown payment provider: %s", name)
+	}
+	return provider, nil
+}
+
+// DefaultName имя шлюза по умолчанию
+func (r *ProviderRegistry) DefaultName() string {
+	return r.defaultName
+}
+
+// Names имена всех зарегистрированных шлюзов
+func (r *ProviderRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
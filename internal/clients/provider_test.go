@@ -0,0 +1,69 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+type mockProvider struct {
+	name string
+}
+
+func (m *mockProvider) Name() string { return m.name }
+
+func (m *mockProvider) CreateCheckout(ctx context.Context, payment *models.Payment, amount models.Money) (string, error) {
+	return "https://checkout/" + payment.ID, nil
+}
+
+func (m *mockProvider) CheckStatus(ctx context.Context, payment *models.Payment) (string, models.Money, error) {
+	return ProviderStatusSuccess, payment.Amount, nil
+}
+
+func (m *mockProvider) Payout(ctx context.Context, payment *models.Payment, receiver string) (string, error) {
+	return ProviderStatusSuccess, nil
+}
+
+func (m *mockProvider) Refund(ctx context.Context, payment *models.Payment, refund *models.Refund, receiver string) (string, error) {
+	return "mock-" + refund.ID, nil
+}
+
+func TestProviderRegistry_Get(t *testing.T) {
+	registry, err := NewProviderRegistry(YooMoneyProviderName, &YooMoneyClient{}, &mockProvider{name: "mock"})
+	assert.NoError(t, err)
+
+	provider, err := registry.Get("mock")
+	assert.NoError(t, err)
+	assert.Equal(t, "mock", provider.Name())
+
+	provider, err = registry.Get("")
+	assert.NoError(t, err)
+	assert.Equal(t, YooMoneyProviderName, provider.Name())
+
+	_, err = registry.Get("paypal")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown payment provider")
+
+	assert.Equal(t, []string{"mock", YooMoneyProviderName}, registry.Names())
+}
+
+func TestNewProviderRegistry_UnknownDefault(t *testing.T) {
+	_, err := NewProviderRegistry("paypal", &YooMoneyClient{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not registered")
+}
+
+func TestYooMoneyRefund_TransfersBackWithRefundLabel(t *testing.T) {
+	mockClient := createMockHTTPClient2(`{"status": "success"}`, 200, nil)
+	client := &YooMoneyClient{Client: mockClient, APIBaseURL: "https://mock-yoomoney.ru"}
+
+	payment := &models.Payment{ID: "payment-id", Amount: models.Money{MinorUnits: 10000, Currency: "RUB"}}
+	reference, err := client.Refund(context.Background(), payment, &models.Refund{ID: "refund-id", Amount: models.Money{MinorUnits: 2500, Currency: "RUB"}}, "payer-wallet")
+	assert.NoError(t, err)
+	assert.Equal(t, "refund-refund-id", reference)
+
+	_, err = client.Refund(context.Background(), payment, &models.Refund{ID: "refund-id"}, "payer-wallet")
+	assert.Error(t, err)
+}
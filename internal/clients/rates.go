@@ -0,0 +1,138 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// ErrUnknownCurrency валюта не поддерживается сервисом или источниками курсов
+var ErrUnknownCurrency = models.ErrUnknownCurrency
+
+// UnknownCurrencyError ошибка с кодом неизвестной валюты
+type UnknownCurrencyError = models.UnknownCurrencyError
+
+// ExchangeRate курс from->to с источником и временем, на которое он актуален
+type ExchangeRate struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      float64   `json:"rate"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Conversion результат конвертации и курс, по которому она сделана
+type Conversion struct {
+	Amount models.Money
+	Rate   ExchangeRate
+}
+
+// RateProvider источник курсов валют
+type RateProvider interface {
+	Name() string
+	Rate(ctx context.Context, from, to string) (ExchangeRate, error)
+}
+
+// CurrencyConverter конвертер валют, которым пользуется сервис
+type CurrencyConverter interface {
+	Convert(ctx context.Context, amount models.Money, to string) (Conversion, error)
+	ConvertToRub(ctx context.Context, amount models.Money) (Conversion, error)
+}
+
+// RatesConverter конвертер с кэшем курсов в редиске и запасными источниками.
+// Источники опрашиваются по порядку, пока один из них не вернет курс.
+type RatesConverter struct {
+	providers []RateProvider
+	redis     *redis.Client
+	ttl       time.Duration
+	logger    *zap.Logger
+}
+
+// NewRatesConverter создание конвертера, первый источник основной, остальные запасные
+func NewRatesConverter(redis *redis.Client, ttl time.Duration, logger *zap.Logger, providers ...RateProvider) *RatesConverter {
+	return &RatesConverter{
+		providers: providers,
+		redis:     redis,
+		ttl:       ttl,
+		logger:    logger,
+	}
+}
+
+// ConvertToRub конвертация в рубли
+func (c *RatesConverter) ConvertToRub(ctx context.Context, amount models.Money) (Conversion, error) {
+	return c.Convert(ctx, amount, "RUB")
+}
+
+// Convert конвертация суммы по актуальному курсу с округлением по правилам целевой валюты
+func (c *RatesConverter) Convert(ctx context.Context, amount models.Money, to string) (Conversion, error) {
+	rate, err := c.Rate(ctx, amount.Currency, to)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	converted, err := amount.Convert(rate.Rate, rate.To)
+	if err != nil {
+		return Conversion{}, err
+	}
+	return Conversion{Amount: converted, Rate: rate}, nil
+}
+
+// Rate курс из кэша, а при его отсутствии из первого ответившего источника
+func (c *RatesConverter) Rate(ctx context.Context, from, to string) (ExchangeRate, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	for _, currency := range []string{from, to} {
+		if _, err := models.LookupCurrency(currency); err != nil { // неподдерживаемые валюты не отправляем в источники
+			return ExchangeRate{}, err
+		}
+	}
+
+	if from == to {
+		return ExchangeRate{From: from, To: to, Rate: 1, Source: "identity", Timestamp: time.Now()}, nil
+	}
+
+	cacheKey := fmt.Sprintf("exchange_rate:%s:%s", from, to)
+	if c.redis != nil {
+		cached, err := c.redis.Get(ctx, cacheKey).Result()
+		if err == nil {
+			var rate ExchangeRate
+			if err := json.Unmarshal([]byte(cached), &rate); err == nil {
+				return rate, nil
+			}
+		}
+	}
+
+	var errs []error
+	unknown := 0
+	for _, provider := range c.providers {
+		rate, err := provider.Rate(ctx, from, to)
+		if err != nil {
+			c.logger.Warn("Exchange rate provider failed", zap.String("source", provider.Name()), zap.String("from", from), zap.String("to", to), zap.Error(err))
+			if errors.Is(err, ErrUnknownCurrency) {
+				unknown++
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+
+		if c.redis != nil {
+			if data, err := json.Marshal(rate); err == nil {
+				c.redis.Set(ctx, cacheKey, data, c.ttl)
+			}
+		}
+		return rate, nil
+	}
+
+	if unknown > 0 && unknown == len(c.providers) { // все источники не знают валюту
+		var unknownErr *UnknownCurrencyError
+		errors.As(errs[0], &unknownErr)
+		return ExchangeRate{}, unknownErr
+	}
+	return ExchangeRate{}, fmt.Errorf("all exchange rate providers failed: %v", errors.Join(errs...))
+}
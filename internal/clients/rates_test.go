@@ -0,0 +1,123 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap/zaptest"
+)
+
+type mockRateProvider struct {
+	name  string
+	rate  float64
+	err   error
+	calls int
+}
+
+func (m *mockRateProvider) Name() string { return m.name }
+
+func (m *mockRateProvider) Rate(ctx context.Context, from, to string) (ExchangeRate, error) {
+	m.calls++
+	if m.err != nil {
+		return ExchangeRate{}, m.err
+	}
+	return ExchangeRate{From: from, To: to, Rate: m.rate, Source: m.name, Timestamp: time.Unix(1700000000, 0).UTC()}, nil
+}
+
+func newTestRedis(t *testing.T) *redis.Client {
+	mockRedis := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mockRedis.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func TestRatesConverter_CachesRate(t *testing.T) {
+	primary := &mockRateProvider{name: "primary", rate: 90}
+	converter := NewRatesConverter(newTestRedis(t), time.Minute, zaptest.NewLogger(t), primary)
+
+	conversion, err := converter.ConvertToRub(context.Background(), models.Money{MinorUnits: 1000, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money{MinorUnits: 90000, Currency: "RUB"}, conversion.Amount)
+	assert.Equal(t, "primary", conversion.Rate.Source)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), conversion.Rate.Timestamp)
+
+	_, err = converter.ConvertToRub(context.Background(), models.Money{MinorUnits: 2000, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, primary.calls)
+}
+
+func TestRatesConverter_FallsBackToSecondary(t *testing.T) {
+	primary := &mockRateProvider{name: "primary", err: errors.New("timeout")}
+	secondary := &mockRateProvider{name: "secondary", rate: 100}
+	converter := NewRatesConverter(nil, time.Minute, zaptest.NewLogger(t), primary, secondary)
+
+	conversion, err := converter.ConvertToRub(context.Background(), models.Money{MinorUnits: 200, Currency: "EUR"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money{MinorUnits: 20000, Currency: "RUB"}, conversion.Amount)
+	assert.Equal(t, "secondary", conversion.Rate.Source)
+}
+
+func TestRatesConverter_UnknownCurrency(t *testing.T) {
+	primary := &mockRateProvider{name: "primary", err: &UnknownCurrencyError{Currency: "KWD"}}
+	secondary := &mockRateProvider{name: "secondary", err: &UnknownCurrencyError{Currency: "KWD"}}
+	converter := NewRatesConverter(nil, time.Minute, zaptest.NewLogger(t), primary, secondary)
+
+	_, err := converter.ConvertToRub(context.Background(), models.Money{MinorUnits: 1000, Currency: "KWD"})
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+	var unknownErr *UnknownCurrencyError
+	assert.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, "KWD", unknownErr.Currency)
+
+	calls := primary.calls
+	_, err = converter.ConvertToRub(context.Background(), models.Money{MinorUnits: 100, Currency: "XXX"})
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+	assert.Equal(t, calls, primary.calls) // невалидный код не уходит в источники
+}
+
+func TestRatesConverter_AllProvidersFail(t *testing.T) {
+	primary := &mockRateProvider{name: "primary", err: errors.New("timeout")}
+	secondary := &mockRateProvider{name: "secondary", err: &UnknownCurrencyError{Currency: "USD"}}
+	converter := NewRatesConverter(nil, time.Minute, zaptest.NewLogger(t), primary, secondary)
+
+	_, err := converter.ConvertToRub(context.Background(), models.Money{MinorUnits: 100, Currency: "USD"})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnknownCurrency)
+	assert.Contains(t, err.Error(), "all exchange rate providers failed")
+}
+
+func TestForexClient_Rate(t *testing.T) {
+	mockResponse := `{"base": "USD", "result": {"RUB": 92.5}, "updated": "2024-11-20 10:00:00", "ms": 3}`
+	forexClient := &ForexClient{Client: createMockHTTPClient(mockResponse, http.StatusOK, nil)}
+
+	rate, err := forexClient.Rate(context.Background(), "USD", "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, 92.5, rate.Rate)
+	assert.Equal(t, ForexSourceName, rate.Source)
+	assert.Equal(t, time.Date(2024, 11, 20, 10, 0, 0, 0, time.UTC), rate.Timestamp)
+
+	forexClient.Client = createMockHTTPClient(`{"base": "USD", "result": {}}`, http.StatusOK, nil)
+	_, err = forexClient.Rate(context.Background(), "USD", "RUB")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestExchangeRateAPIClient_Rate(t *testing.T) {
+	mockResponse := `{"result": "success", "base_code": "EUR", "time_last_update_unix": 1700000000, "rates": {"RUB": 101.2}}`
+	client := &ExchangeRateAPIClient{BaseURL: "https://mock", Client: createMockHTTPClient(mockResponse, http.StatusOK, nil)}
+
+	rate, err := client.Rate(context.Background(), "EUR", "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, 101.2, rate.Rate)
+	assert.Equal(t, ExchangeRateAPISourceName, rate.Source)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), rate.Timestamp)
+
+	client.Client = createMockHTTPClient(`{"result": "error", "error-type": "unsupported-code"}`, http.StatusNotFound, nil)
+	_, err = client.Rate(context.Background(), "XXX", "RUB")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
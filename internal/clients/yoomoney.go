@@ -1,17 +1,19 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
 
 	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type YooMoneyClient struct {
@@ -23,15 +25,15 @@ type YooMoneyClient struct {
 
 func NewYooMoneyClient(cfg *config.Config) *YooMoneyClient {
 	return &YooMoneyClient{
-		Client:     &http.Client{Timeout: 10 * time.Second},
+		Client:     &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(metrics.NewRoundTripper(YooMoneyProviderName, nil))},
 		Token:      cfg.Yoomoney.Token,
 		ClientID:   cfg.Yoomoney.ClientID,
 		APIBaseURL: "https://yoomoney.ru",
 	}
 }
 
-// CheckPaymentStatus проверяет статус платежа
-func (c *YooMoneyClient) CheckPaymentStatus(label string) (string, error) {
+// CheckPaymentStatus проверяет статус платежа, возвращает сумму, зачисленную по операции за вычетом комиссии
+func (c *YooMoneyClient) CheckPaymentStatus(ctx context.Context, label string) (string, models.Money, error) {
 	apiURL := fmt.Sprintf("%s/api/operation-history", c.APIBaseURL)
 
 	params := url.Values{}
@@ -39,9 +41,9 @@ func (c *YooMoneyClient) CheckPaymentStatus(label string) (string, error) {
 	params.Add("records", "1")
 	params.Add("type", "deposition")
 
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(params.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(params.Encode()))
 	if err != nil {
-		return "error", fmt.Errorf("failed to create request: %v", err)
+		return "error", models.Money{}, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.Token))
@@ -49,86 +51,96 @@ func (c *YooMoneyClient) CheckPaymentStatus(label string) (string, error) {
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		return "error", fmt.Errorf("failed to make API request: %v", err)
+		return "error", models.Money{}, fmt.Errorf("failed to make API request: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "error", fmt.Errorf("failed to read response body: %v", err)
+		return "error", models.Money{}, fmt.Errorf("failed to read response body: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "error", fmt.Errorf("API response status: %s, body: %s", resp.Status, string(body))
+		return "error", models.Money{}, fmt.Errorf("API response status: %s, body: %s", resp.Status, string(body))
 	}
 
 	var response struct {
 		Error      string `json:"error"`
 		Operations []struct {
-			Status string `json:"status"`
+			Status string  `json:"status"`
+			Amount float64 `json:"amount"`
 		} `json:"operations"`
 	}
 
 	if err := json.Unmarshal(body, &response); err != nil {
-		return "error", fmt.Errorf("failed to decode response: %v", err)
+		return "error", models.Money{}, fmt.Errorf("failed to decode response: %v", err)
 	}
 
 	if response.Error != "" {
-		return "error", fmt.Errorf("API error: %s", response.Error)
+		return "error", models.Money{}, fmt.Errorf("API error: %s", response.Error)
 	}
 
 	if len(response.Operations) == 0 {
-		return "error", fmt.Errorf("no operations found for label: %s", label)
+		return "error", models.Money{}, fmt.Errorf("no operations found for label: %s", label)
 	}
 
-	status := response.Operations[0].Status
+	operation := response.Operations[0]
+	credited, err := models.MoneyFromFloat(operation.Amount, "RUB") // кошелек рублевый
+	if err != nil {
+		return "error", models.Money{}, fmt.Errorf("invalid operation amount: %w", err)
+	}
 
-	switch status {
+	switch operation.Status {
 	case "success":
-		return "success", nil
+		return "success", credited, nil
 	case "refused":
-		return "failed", fmt.Errorf("payment refused")
+		return "failed", credited, fmt.Errorf("payment refused")
 	case "in_progress":
-		return "pending", nil
+		return "pending", credited, nil
 	default:
-		return "error", fmt.Errorf("unexpected payment status: %s", status)
+		return "error", models.Money{}, fmt.Errorf("unexpected payment status: %s", operation.Status)
 	}
 }
 
-// CreateTransfer Создает перевод
-func (c *YooMoneyClient) CreateTransfer(payment *models.Payment, receiver string) (string, error) {
+// CreateTransfer Создает перевод. Ошибки проверки данных оборачивают ErrProviderRejected: перевод не отправлялся
+func (c *YooMoneyClient) CreateTransfer(ctx context.Context, payment *models.Payment, receiver string) (string, error) {
 	if payment == nil {
-		return "", fmt.Errorf("payment information is required")
+		return "", fmt.Errorf("%w: payment information is required", ErrProviderRejected)
 	}
 	if payment.ToUserID == "" {
-		return "", fmt.Errorf("recipient (to_user_id) is required")
+		return "", fmt.Errorf("%w: recipient (to_user_id) is required", ErrProviderRejected)
 	}
-	if payment.Amount <= 0 {
-		return "", fmt.Errorf("amount must be greater than zero")
+	if !payment.Amount.IsPositive() {
+		return "", fmt.Errorf("%w: amount must be greater than zero", ErrProviderRejected)
 	}
-	if payment.Currency == "" {
-		return "", fmt.Errorf("currency is required")
+	if payment.Amount.Currency == "" {
+		return "", fmt.Errorf("%w: currency is required", ErrProviderRejected)
 	}
 	if payment.ID == "" {
-		return "", fmt.Errorf("payment ID is required")
+		return "", fmt.Errorf("%w: payment ID is required", ErrProviderRejected)
 	}
 
+	return c.transfer(ctx, receiver, payment.Amount, payment.ID)
+}
+
+// transfer перевод с основного счета на кошелек получателя
+func (c *YooMoneyClient) transfer(ctx context.Context, receiver string, amount models.Money, label string) (string, error) {
 	apiURL := fmt.Sprintf("%s/api/request-payment", c.APIBaseURL)
 
 	params := url.Values{}
 	params.Add("pattern_id", "p2p")
 	params.Add("to", receiver)
-	params.Add("amount", strconv.FormatFloat(payment.Amount, 'f', 2, 64))
-	params.Add("comment", payment.ID)
-	params.Add("message", payment.ID)
-	params.Add("label", payment.ID)
-	params.Add("currency", payment.Currency)
+	params.Add("amount", amount.String())
+	params.Add("comment", label)
+	params.Add("message", label)
+	params.Add("label", label)
+	params.Add("currency", amount.Currency)
 
 	reqBody := params.Encode()
 
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(reqBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return "", fmt.Errorf("%w: failed to create request: %v", ErrProviderRejected, err)
 	}
 
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.Token))
@@ -145,6 +157,9 @@ func (c *YooMoneyClient) CreateTransfer(payment *models.Payment, receiver string
 		return "", fmt.Errorf("failed to read response body: %v", err)
 	}
 
+	if rejectedStatus(resp.StatusCode) {
+		return "", fmt.Errorf("%w: API response status: %s, body: %s", ErrProviderRejected, resp.Status, string(body))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("API response status: %s, body: %s", resp.Status, string(body))
 	}
@@ -164,18 +179,23 @@ func (c *YooMoneyClient) CreateTransfer(payment *models.Payment, receiver string
 		return "success", nil
 	case "refused":
 		errorMsg, _ := result["error"].(string)
-		return "failed", fmt.Errorf("transfer refused: %s", errorMsg)
+		return "failed", fmt.Errorf("%w: transfer refused: %s", ErrProviderRejected, errorMsg)
 	default:
 		return "error", fmt.Errorf("unexpected transfer status: %s", status)
 	}
 }
 
+// rejectedStatus ответ 4xx: запрос отклонен до выполнения. Таймаут шлюза (408) исход не определяет
+func rejectedStatus(code int) bool {
+	return code >= http.StatusBadRequest && code < http.StatusInternalServerError && code != http.StatusRequestTimeout
+}
+
 // QuickPayment создает ссылку для оплаты
-func (c *YooMoneyClient) QuickPayment(receiver, targets, paymentType string, sum float64, formcomment, label, comment, successURL string) (string, error) {
+func (c *YooMoneyClient) QuickPayment(ctx context.Context, receiver, targets, paymentType string, sum models.Money, formcomment, label, comment, successURL string) (string, error) {
 	if receiver == "" {
 		return "", fmt.Errorf("receiver is required")
 	}
-	if sum <= 0 {
+	if !sum.IsPositive() {
 		return "", fmt.Errorf("sum must be greater than zero")
 	}
 
@@ -185,7 +205,7 @@ func (c *YooMoneyClient) QuickPayment(receiver, targets, paymentType string, sum
 	payload.Add("receiver", receiver) // Receiver's YooMoney wallet
 	payload.Add("quickpay-form", "shop")
 	payload.Add("paymentType", paymentType) // Payment method: 'PC' or 'AC'
-	payload.Add("sum", strconv.FormatFloat(sum, 'f', 2, 64))
+	payload.Add("sum", sum.String())
 	payload.Add("targets", targets) // Payment purpose/target description
 
 	if formcomment != "" {
@@ -203,7 +223,12 @@ func (c *YooMoneyClient) QuickPayment(receiver, targets, paymentType string, sum
 
 	finalURL := baseURL + payload.Encode()
 
-	resp, err := http.Get(finalURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", finalURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := c.Client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to validate URL: %v", err)
 	}
@@ -0,0 +1,120 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+// yooMoneyHistoryPage сколько операций запрашивается за раз, больше API не отдает
+const yooMoneyHistoryPage = 100
+
+// yooMoneyOperation операция в ответе operation-history
+type yooMoneyOperation struct {
+	OperationID string    `json:"operation_id"`
+	Status      string    `json:"status"`
+	Datetime    time.Time `json:"datetime"`
+	Direction   string    `json:"direction"`
+	Amount      float64   `json:"amount"`
+	Label       string    `json:"label"`
+}
+
+// Operations история операций кошелька за окно [from, to) постранично
+func (c *YooMoneyClient) Operations(ctx context.Context, from, to time.Time) ([]ProviderOperation, error) {
+	var operations []ProviderOperation
+	startRecord := ""
+	for {
+		page, next, err := c.operationHistory(ctx, from, to, startRecord)
+		if err != nil {
+			return nil, err
+		}
+		for _, operation := range page {
+			amount, err := models.MoneyFromFloat(operation.Amount, "RUB") // кошелек рублевый
+			if err != nil {
+				return nil, fmt.Errorf("invalid amount of operation %s: %w", operation.OperationID, err)
+			}
+			operations = append(operations, ProviderOperation{
+				ID:        operation.OperationID,
+				Label:     operation.Label,
+				Direction: operation.Direction,
+				Status:    operationStatus(operation.Status),
+				Amount:    amount,
+				Time:      operation.Datetime,
+			})
+		}
+		if next == "" || next == startRecord {
+			return operations, nil
+		}
+		startRecord = next
+	}
+}
+
+// operationHistory одна страница истории, возвращает номер первой записи следующей страницы
+func (c *YooMoneyClient) operationHistory(ctx context.Context, from, to time.Time, startRecord string) ([]yooMoneyOperation, string, error) {
+	apiURL := fmt.Sprintf("%s/api/operation-history", c.APIBaseURL)
+
+	params := url.Values{}
+	params.Add("from", from.Format(time.RFC3339))
+	params.Add("till", to.Format(time.RFC3339)) // операции раньше till
+	params.Add("records", fmt.Sprint(yooMoneyHistoryPage))
+	if startRecord != "" {
+		params.Add("start_record", startRecord)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to make API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("API response status: %s, body: %s", resp.Status, string(body))
+	}
+
+	var response struct {
+		Error      string              `json:"error"`
+		NextRecord string              `json:"next_record"`
+		Operations []yooMoneyOperation `json:"operations"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, "", fmt.Errorf("failed to decode response: %v", err)
+	}
+	if response.Error != "" {
+		return nil, "", fmt.Errorf("API error: %s", response.Error)
+	}
+	return response.Operations, response.NextRecord, nil
+}
+
+// operationStatus статус операции YooMoney в терминах ProviderStatus*
+func operationStatus(status string) string {
+	switch status {
+	case "success":
+		return ProviderStatusSuccess
+	case "refused":
+		return ProviderStatusFailed
+	case "in_progress":
+		return ProviderStatusPending
+	default:
+		return ProviderStatusError
+	}
+}
@@ -0,0 +1,90 @@
+package clients
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+// ErrInvalidSignature подпись уведомления не совпала с секретом
+var ErrInvalidSignature = errors.New("invalid notification signature")
+
+// YooMoneyNotification HTTP-уведомление YooMoney о входящем переводе
+type YooMoneyNotification struct {
+	NotificationType string
+	OperationID      string
+	Amount           string // зачислено на счет, за вычетом комиссии
+	WithdrawAmount   string // списано с плательщика, не подписано
+	Currency         string // числовой код валюты, всегда 643
+	Datetime         string
+	Sender           string
+	Codepro          bool
+	Label            string // метка, равная id платежа
+	Unaccepted       bool
+	TestNotification bool
+}
+
+// Status статус оплаты в терминах PaymentProvider.
+// Перевод с протекцией или не зачисленный из-за лимитов еще не получен.
+func (n *YooMoneyNotification) Status() string {
+	if n.Codepro || n.Unaccepted {
+		return ProviderStatusPending
+	}
+	return ProviderStatusSuccess
+}
+
+// Paid сумма, зачисленная на счет за вычетом комиссии. Берется amount, а не withdraw_amount:
+// withdraw_amount не входит в sha1_hash и может быть подменен. YooMoney присылает суммы только в рублях (код валюты 643)
+func (n *YooMoneyNotification) Paid() (models.Money, error) {
+	if n.Currency != "643" {
+		return models.Money{}, fmt.Errorf("unexpected notification currency: %s", n.Currency)
+	}
+	return models.ParseMoney(n.Amount, "RUB")
+}
+
+// ParseYooMoneyNotification разбор уведомления с проверкой sha1_hash по секрету из настроек кошелька
+func ParseYooMoneyNotification(form url.Values, secret string) (*YooMoneyNotification, error) {
+	notification := &YooMoneyNotification{
+		NotificationType: form.Get("notification_type"),
+		OperationID:      form.Get("operation_id"),
+		Amount:           form.Get("amount"),
+		WithdrawAmount:   form.Get("withdraw_amount"),
+		Currency:         form.Get("currency"),
+		Datetime:         form.Get("datetime"),
+		Sender:           form.Get("sender"),
+		Codepro:          form.Get("codepro") == "true",
+		Label:            form.Get("label"),
+		Unaccepted:       form.Get("unaccepted") == "true",
+		TestNotification: form.Get("test_notification") == "true",
+	}
+
+	if notification.NotificationType == "" || notification.OperationID == "" {
+		return nil, fmt.Errorf("notification_type and operation_id are required")
+	}
+
+	// порядок полей задан документацией YooMoney
+	signed := strings.Join([]string{
+		notification.NotificationType,
+		notification.OperationID,
+		notification.Amount,
+		notification.Currency,
+		notification.Datetime,
+		notification.Sender,
+		form.Get("codepro"),
+		secret,
+		notification.Label,
+	}, "&")
+	sum := sha1.Sum([]byte(signed))
+	expected := hex.EncodeToString(sum[:])
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(form.Get("sha1_hash")))) != 1 {
+		return nil, ErrInvalidSignature
+	}
+	return notification, nil
+}
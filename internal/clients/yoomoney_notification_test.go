@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+func signedNotification(secret string) url.Values {
+	form := url.Values{}
+	form.Set("notification_type", "p2p-incoming")
+	form.Set("operation_id", "1234567")
+	form.Set("amount", "300.00")
+	form.Set("withdraw_amount", "301.50")
+	form.Set("currency", "643")
+	form.Set("datetime", "2024-01-01T10:00:00Z")
+	form.Set("sender", "41001000040")
+	form.Set("codepro", "false")
+	form.Set("label", "payment-id")
+
+	sum := sha1.Sum([]byte("p2p-incoming&1234567&300.00&643&2024-01-01T10:00:00Z&41001000040&false&" + secret + "&payment-id"))
+	form.Set("sha1_hash", hex.EncodeToString(sum[:]))
+	return form
+}
+
+func TestParseYooMoneyNotification_Valid(t *testing.T) {
+	notification, err := ParseYooMoneyNotification(signedNotification("secret"), "secret")
+	require.NoError(t, err)
+
+	assert.Equal(t, "payment-id", notification.Label)
+	assert.Equal(t, "301.50", notification.WithdrawAmount)
+	assert.Equal(t, ProviderStatusSuccess, notification.Status())
+}
+
+func TestYooMoneyNotification_Paid(t *testing.T) {
+	notification, err := ParseYooMoneyNotification(signedNotification("secret"), "secret")
+	require.NoError(t, err)
+
+	paid, err := notification.Paid() // подписанная зачисленная сумма, а не списанная с плательщика
+	require.NoError(t, err)
+	assert.Equal(t, models.Money{MinorUnits: 30000, Currency: "RUB"}, paid)
+
+	form := signedNotification("secret")
+	form.Set("withdraw_amount", "1000000.00") // withdraw_amount не подписан, подмена не ломает подпись и не влияет на сумму
+	notification, err = ParseYooMoneyNotification(form, "secret")
+	require.NoError(t, err)
+	paid, err = notification.Paid()
+	require.NoError(t, err)
+	assert.Equal(t, models.Money{MinorUnits: 30000, Currency: "RUB"}, paid)
+
+	notification.Currency = "840"
+	_, err = notification.Paid()
+	assert.Error(t, err)
+}
+
+func TestParseYooMoneyNotification_InvalidSignature(t *testing.T) {
+	_, err := ParseYooMoneyNotification(signedNotification("secret"), "other-secret")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	form := signedNotification("secret")
+	form.Set("label", "another-payment") // подмена метки ломает подпись
+	_, err = ParseYooMoneyNotification(form, "secret")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestParseYooMoneyNotification_Unaccepted(t *testing.T) {
+	form := signedNotification("secret")
+	form.Set("unaccepted", "true")
+
+	notification, err := ParseYooMoneyNotification(form, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, ProviderStatusPending, notification.Status())
+}
+
+func TestParseYooMoneyNotification_MissingFields(t *testing.T) {
+	_, err := ParseYooMoneyNotification(url.Values{}, "secret")
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+// YooMoneyProviderName имя шлюза YooMoney в реестре и в платежах
+const YooMoneyProviderName = "yoomoney"
+
+// RefundLabelPrefix префикс метки перевода возврата, за ним идет id возврата
+const RefundLabelPrefix = "refund-"
+
+var (
+	_ PaymentProvider  = (*YooMoneyClient)(nil)
+	_ OperationHistory = (*YooMoneyClient)(nil)
+)
+
+// Name имя шлюза
+func (c *YooMoneyClient) Name() string {
+	return YooMoneyProviderName
+}
+
+// CreateCheckout ссылка на оплату на основной счет, меткой служит id платежа
+func (c *YooMoneyClient) CreateCheckout(ctx context.Context, payment *models.Payment, amount models.Money) (string, error) {
+	return c.QuickPayment(ctx, models.CoreAccount, payment.ID, "AC", amount, payment.ID, payment.ID, payment.ID, "")
+}
+
+// CheckStatus проверка оплаты по метке платежа
+func (c *YooMoneyClient) CheckStatus(ctx context.Context, payment *models.Payment) (string, models.Money, error) {
+	return c.CheckPaymentStatus(ctx, payment.ID)
+}
+
+// Payout перевод с основного счета получателю
+func (c *YooMoneyClient) Payout(ctx context.Context, payment *models.Payment, receiver string) (string, error) {
+	return c.CreateTransfer(ctx, payment, receiver)
+}
+
+// Refund у кошельков YooMoney нет возвратов, поэтому переводим сумму возврата обратно отправителю.
+// Метка перевода служит идентификатором операции.
+func (c *YooMoneyClient) Refund(ctx context.Context, payment *models.Payment, refund *models.Refund, receiver string) (string, error) {
+	if payment == nil || payment.ID == "" || refund == nil || refund.ID == "" {
+		return "", fmt.Errorf("%w: payment and refund information is required", ErrProviderRejected)
+	}
+	if !refund.Amount.IsPositive() {
+		return "", fmt.Errorf("%w: amount must be greater than zero", ErrProviderRejected)
+	}
+
+	label := RefundLabelPrefix + refund.ID
+	if _, err := c.transfer(ctx, receiver, refund.Amount, label); err != nil {
+		return "", err
+	}
+	return label, nil
+}
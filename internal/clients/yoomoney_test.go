@@ -2,8 +2,11 @@ package clients
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -37,7 +40,7 @@ func createMockHTTPClient2(responseBody string, statusCode int, err error) *http
 
 func TestCheckPaymentStatus_Success(t *testing.T) {
 	mockResponse := `{
-		"operations": [{"status": "success"}],
+		"operations": [{"status": "success", "amount": 970.50}],
 		"error": ""
 	}`
 
@@ -49,9 +52,10 @@ func TestCheckPaymentStatus_Success(t *testing.T) {
 		APIBaseURL: "https://mock-yoomoney.ru",
 	}
 
-	status, err := client.CheckPaymentStatus("valid-label")
+	status, credited, err := client.CheckPaymentStatus(context.Background(), "valid-label")
 	assert.NoError(t, err)
 	assert.Equal(t, "success", status)
+	assert.Equal(t, models.Money{MinorUnits: 97050, Currency: "RUB"}, credited)
 }
 
 func TestCheckPaymentStatus_Failure(t *testing.T) {
@@ -68,7 +72,7 @@ func TestCheckPaymentStatus_Failure(t *testing.T) {
 		APIBaseURL: "https://mock-yoomoney.ru",
 	}
 
-	status, err := client.CheckPaymentStatus("valid-label")
+	status, _, err := client.CheckPaymentStatus(context.Background(), "valid-label")
 	assert.Error(t, err)
 	assert.Equal(t, "failed", status)
 	assert.Contains(t, err.Error(), "payment refused")
@@ -84,7 +88,7 @@ func TestCheckPaymentStatus_Error(t *testing.T) {
 		APIBaseURL: "https://mock-yoomoney.ru",
 	}
 
-	status, err := client.CheckPaymentStatus("valid-label")
+	status, _, err := client.CheckPaymentStatus(context.Background(), "valid-label")
 	assert.Error(t, err)
 	assert.Equal(t, "error", status)
 	assert.Contains(t, err.Error(), "API error")
@@ -103,21 +107,21 @@ func TestCreateTransfer_Success(t *testing.T) {
 
 	payment := &models.Payment{
 		ID:       "payment-id",
-		Amount:   100.0,
-		Currency: "RUB",
+		Amount:   models.Money{MinorUnits: 10000, Currency: "RUB"},
 		ToUserID: "recipient-id",
 	}
 
-	status, err := client.CreateTransfer(payment, "receiver-id")
+	status, err := client.CreateTransfer(context.Background(), payment, "receiver-id")
 	assert.NoError(t, err)
 	assert.Equal(t, "success", status)
 }
 
 func TestCreateTransfer_InvalidPayment(t *testing.T) {
 	client := &YooMoneyClient{}
-	_, err := client.CreateTransfer(nil, "receiver-id")
+	_, err := client.CreateTransfer(context.Background(), nil, "receiver-id")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "payment information is required")
+	assert.ErrorIs(t, err, ErrProviderRejected) // перевод не отправлялся, демон может повторить выплату
 }
 
 func TestCreateTransfer_Failure(t *testing.T) {
@@ -133,15 +137,40 @@ func TestCreateTransfer_Failure(t *testing.T) {
 
 	payment := &models.Payment{
 		ID:       "payment-id",
-		Amount:   100.0,
-		Currency: "RUB",
+		Amount:   models.Money{MinorUnits: 10000, Currency: "RUB"},
 		ToUserID: "recipient-id",
 	}
 
-	status, err := client.CreateTransfer(payment, "receiver-id")
+	status, err := client.CreateTransfer(context.Background(), payment, "receiver-id")
 	assert.Error(t, err)
 	assert.Equal(t, "failed", status)
 	assert.Contains(t, err.Error(), "insufficient funds")
+	assert.ErrorIs(t, err, ErrProviderRejected)
+}
+
+func TestRefund_RejectedOnlyOnDefiniteFailure(t *testing.T) {
+	payment := &models.Payment{ID: "payment-id", Amount: models.Money{MinorUnits: 10000, Currency: "RUB"}}
+	refund := &models.Refund{ID: "refund-id", PaymentID: payment.ID, Amount: models.Money{MinorUnits: 5000, Currency: "RUB"}}
+
+	cases := []struct {
+		name     string
+		body     string
+		status   int
+		err      error
+		rejected bool
+	}{
+		{"refused", `{"status": "refused", "error": "not_enough_funds"}`, http.StatusOK, nil, true},
+		{"bad request", `{}`, http.StatusBadRequest, nil, true},
+		{"server error", `{}`, http.StatusBadGateway, nil, false},
+		{"gateway timeout", `{}`, http.StatusRequestTimeout, nil, false},
+		{"connection error", ``, http.StatusOK, context.DeadlineExceeded, false},
+	}
+	for _, c := range cases {
+		client := &YooMoneyClient{Client: createMockHTTPClient2(c.body, c.status, c.err), Token: "mock-token", APIBaseURL: "https://mock-yoomoney.ru"}
+		_, err := client.Refund(context.Background(), payment, refund, "receiver-id")
+		assert.Error(t, err, c.name)
+		assert.Equal(t, c.rejected, errors.Is(err, ErrProviderRejected), c.name)
+	}
 }
 
 func TestQuickPayment_Success(t *testing.T) {
@@ -154,7 +183,7 @@ func TestQuickPayment_Success(t *testing.T) {
 		APIBaseURL: "https://mock-yoomoney.ru",
 	}
 
-	url, err := client.QuickPayment("receiver-id", "targets", "PC", 100.0, "comment", "label", "additional-comment", "https://success.url")
+	url, err := client.QuickPayment(context.Background(), "receiver-id", "targets", "PC", models.Money{MinorUnits: 10000, Currency: "RUB"}, "comment", "label", "additional-comment", "https://success.url")
 	assert.NoError(t, err)
 	assert.Contains(t, url, "receiver=receiver-id")
 	assert.Contains(t, url, "sum=100.00")
@@ -162,7 +191,43 @@ func TestQuickPayment_Success(t *testing.T) {
 
 func TestQuickPayment_InvalidInput(t *testing.T) {
 	client := &YooMoneyClient{}
-	_, err := client.QuickPayment("", "targets", "PC", 0, "", "", "", "")
+	_, err := client.QuickPayment(context.Background(), "", "targets", "PC", models.Money{}, "", "", "", "")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "receiver is required")
 }
+
+func TestOperations_Paginates(t *testing.T) {
+	var startRecords []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "2024-01-01T00:00:00Z", r.PostForm.Get("from"))
+		assert.Equal(t, "2024-01-02T00:00:00Z", r.PostForm.Get("till"))
+		startRecords = append(startRecords, r.PostForm.Get("start_record"))
+		if r.PostForm.Get("start_record") == "" {
+			w.Write([]byte(`{"next_record": "1", "operations": [
+				{"operation_id": "op-1", "status": "success", "datetime": "2024-01-01T10:00:00Z", "direction": "in", "amount": 99.5, "label": "payment-1"}
+			]}`))
+			return
+		}
+		w.Write([]byte(`{"operations": [
+			{"operation_id": "op-2", "status": "refused", "datetime": "2024-01-01T11:00:00Z", "direction": "out", "amount": 10, "label": "payment-2"}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := &YooMoneyClient{Client: server.Client(), Token: "mock-token", APIBaseURL: server.URL}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	operations, err := client.Operations(context.Background(), from, from.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "1"}, startRecords)
+	assert.Equal(t, []ProviderOperation{
+		{ID: "op-1", Label: "payment-1", Direction: OperationIn, Status: ProviderStatusSuccess, Amount: models.Money{MinorUnits: 9950, Currency: "RUB"}, Time: from.Add(10 * time.Hour)},
+		{ID: "op-2", Label: "payment-2", Direction: OperationOut, Status: ProviderStatusFailed, Amount: models.Money{MinorUnits: 1000, Currency: "RUB"}, Time: from.Add(11 * time.Hour)},
+	}, operations)
+}
+
+func TestOperations_APIError(t *testing.T) {
+	client := &YooMoneyClient{Client: createMockHTTPClient2(`{"error": "illegal_param_from"}`, http.StatusOK, nil), APIBaseURL: "https://mock-yoomoney.ru"}
+	_, err := client.Operations(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	assert.ErrorContains(t, err, "illegal_param_from")
+}
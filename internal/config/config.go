@@ -1,25 +1,46 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 // Config Общая конфигурация
 type Config struct {
-	Server   Server   `yaml:"server" env-prefix:"SERVER_"`
-	Postgres Postgres `yaml:"postgres" env-prefix:"POSTGRES_"`
-	Redis    Redis    `yaml:"redis" env-prefix:"REDIS_"`
-	Forex    Forex    `yaml:"forex" env-prefix:"FOREX_"`
-	Yoomoney Yoomoney `yaml:"yoomoney" env-prefix:"YOOMONEY_"`
+	Server         Server         `yaml:"server" env-prefix:"SERVER_"`
+	Postgres       Postgres       `yaml:"postgres" env-prefix:"POSTGRES_"`
+	Redis          Redis          `yaml:"redis" env-prefix:"REDIS_"`
+	Forex          Forex          `yaml:"forex" env-prefix:"FOREX_"`
+	Rates          Rates          `yaml:"rates" env-prefix:"RATES_"`
+	Yoomoney       Yoomoney       `yaml:"yoomoney" env-prefix:"YOOMONEY_"`
+	Queue          Queue          `yaml:"queue" env-prefix:"QUEUE_"`
+	Providers      Providers      `yaml:"providers" env-prefix:"PROVIDERS_"`
+	Idempotency    Idempotency    `yaml:"idempotency" env-prefix:"IDEMPOTENCY_"`
+	Demon          Demon          `yaml:"demon" env-prefix:"DEMON_"`
+	Auth           Auth           `yaml:"auth" env-prefix:"AUTH_"`
+	Cache          Cache          `yaml:"cache" env-prefix:"CACHE_"`
+	Tracing        Tracing        `yaml:"tracing" env-prefix:"TRACING_"`
+	Expiration     Expiration     `yaml:"expiration" env-prefix:"EXPIRATION_"`
+	Reconciliation Reconciliation `yaml:"reconciliation" env-prefix:"RECONCILIATION_"`
+	Refunds        Refunds        `yaml:"refunds" env-prefix:"REFUNDS_"`
+	Broker         Broker         `yaml:"broker" env-prefix:"BROKER_"`
+	Outbox         Outbox         `yaml:"outbox" env-prefix:"OUTBOX_"`
+	Webhooks       Webhooks       `yaml:"webhooks" env-prefix:"WEBHOOKS_"`
+	Fees           Fees           `yaml:"fees" env-prefix:"FEES_"`
+	Risk           Risk           `yaml:"risk" env-prefix:"RISK_"`
 }
 
 // Server конфигурация сервера
 type Server struct {
-	Port int `yaml:"Port" env:"PORT"`
+	Port            int           `yaml:"Port" env:"PORT"`
+	HTTPPort        int           `yaml:"HTTPPort" env:"HTTP_PORT" env-default:"8080"`              // порт для HTTP-уведомлений платежных шлюзов
+	ShutdownTimeout time.Duration `yaml:"ShutdownTimeout" env:"SHUTDOWN_TIMEOUT" env-default:"30s"` // сколько ждать завершения запросов и демона при остановке
+	HealthInterval  time.Duration `yaml:"HealthInterval" env:"HEALTH_INTERVAL" env-default:"5s"`    // как часто проверять бд, Redis и авторизацию
 }
 
 // Postgres конфигурация бд
@@ -42,11 +63,166 @@ type Forex struct {
 	Key string `yaml:"Key" env:"KEY"`
 }
 
+// Rates конфигурация курсов валют
+type Rates struct {
+	CacheTTL    time.Duration `yaml:"CacheTTL" env:"CACHE_TTL" env-default:"10m"`
+	FallbackURL string        `yaml:"FallbackURL" env:"FALLBACK_URL" env-default:"https://open.er-api.com/v6"` // запасной источник, если fastforex недоступен
+}
+
 // Yoomoney юмани для оплаты
 type Yoomoney struct {
-	Token    string `yaml:"Token" env:"TOKEN"`
-	ClientID string `yaml:"ClientID" env:"CLIENT_ID"`
-	Receiver int    `yaml:"Receiver" env:"RECEIVER"`
+	Token              string `yaml:"Token" env:"TOKEN"`
+	ClientID           string `yaml:"ClientID" env:"CLIENT_ID"`
+	Receiver           int    `yaml:"Receiver" env:"RECEIVER"`
+	NotificationSecret string `yaml:"NotificationSecret" env:"NOTIFICATION_SECRET"` // секрет HTTP-уведомлений, без него уведомления не принимаются
+}
+
+// Providers конфигурация платежных шлюзов
+type Providers struct {
+	Default string `yaml:"Default" env:"DEFAULT" env-default:"yoomoney"` // шлюз для платежей без явно указанного
+}
+
+// Queue конфигурация очереди проверки платежей
+type Queue struct {
+	Type              string        `yaml:"Type" env:"TYPE" env-default:"redis"` // memory или redis
+	Stream            string        `yaml:"Stream" env:"STREAM" env-default:"payments:check"`
+	Group             string        `yaml:"Group" env:"GROUP" env-default:"payment-demon"`
+	Consumer          string        `yaml:"Consumer" env:"CONSUMER"` // по умолчанию имя хоста
+	VisibilityTimeout time.Duration `yaml:"VisibilityTimeout" env:"VISIBILITY_TIMEOUT" env-default:"1m"`
+}
+
+// Idempotency конфигурация ключей идемпотентности
+type Idempotency struct {
+	TTL         time.Duration `yaml:"TTL" env:"TTL" env-default:"24h"`                 // сколько хранится ответ по ключу
+	LockTimeout time.Duration `yaml:"LockTimeout" env:"LOCK_TIMEOUT" env-default:"1m"` // через сколько незавершенный запрос с ключом можно повторить
+}
+
+// Demon конфигурация демона проверки платежей
+type Demon struct {
+	PollFallback time.Duration `yaml:"PollFallback" env:"POLL_FALLBACK" env-default:"5m"`  // сколько ждать уведомления, прежде чем опрашивать шлюз
+	PollInterval time.Duration `yaml:"PollInterval" env:"POLL_INTERVAL" env-default:"30s"` // как часто опрашивать шлюз по одному платежу
+	Workers      int           `yaml:"Workers" env:"WORKERS" env-default:"4"`              // сколько платежей обрабатывается параллельно
+	RetryInitial time.Duration `yaml:"RetryInitial" env:"RETRY_INITIAL" env-default:"1s"`  // пауза после первой ошибки, дальше удваивается
+	RetryMax     time.Duration `yaml:"RetryMax" env:"RETRY_MAX" env-default:"5m"`          // предел паузы между попытками
+	MaxAttempts  int           `yaml:"MaxAttempts" env:"MAX_ATTEMPTS" env-default:"10"`    // после стольких ошибок подряд платеж уходит в dead letter
+}
+
+// Expiration срок оплаты платежей
+type Expiration struct {
+	Lifetime      time.Duration `yaml:"Lifetime" env:"LIFETIME" env-default:"24h"`           // сколько неоплаченный платеж ждет оплаты, 0 - бессрочно
+	SweepInterval time.Duration `yaml:"SweepInterval" env:"SWEEP_INTERVAL" env-default:"1m"` // как часто искать просроченные платежи
+	BatchSize     int           `yaml:"BatchSize" env:"BATCH_SIZE" env-default:"100"`        // сколько платежей истекает за один запрос к бд
+}
+
+// Reconciliation сверка платежей с историей операций шлюза
+type Reconciliation struct {
+	Interval time.Duration `yaml:"Interval" env:"INTERVAL" env-default:"1h"`   // как часто запускать сверку, 0 - только вручную
+	Window   time.Duration `yaml:"Window" env:"WINDOW" env-default:"24h"`      // за какой период до запуска сверяются операции
+	AutoFix  bool          `yaml:"AutoFix" env:"AUTO_FIX" env-default:"false"` // переводить оплаченные в шлюзе платежи в SUCCESS
+}
+
+// Refunds проверка возвратов, по которым шлюз не ответил определенно
+type Refunds struct {
+	CheckInterval time.Duration `yaml:"CheckInterval" env:"CHECK_INTERVAL" env-default:"1m"` // как часто проверять возвраты в PENDING
+	CheckAfter    time.Duration `yaml:"CheckAfter" env:"CHECK_AFTER" env-default:"5m"`       // возраст возврата, после которого его исход ищется в истории шлюза
+	FailAfter     time.Duration `yaml:"FailAfter" env:"FAIL_AFTER" env-default:"24h"`        // возврат без операции в истории дольше этого считается неудавшимся
+	BatchSize     int           `yaml:"BatchSize" env:"BATCH_SIZE" env-default:"100"`        // сколько возвратов проверяется за раз
+}
+
+// Broker брокер, в который публикуются доменные события платежей
+type Broker struct {
+	Type         string   `yaml:"Type" env:"TYPE" env-default:"memory"`         // memory, nats или kafka
+	Subject      string   `yaml:"Subject" env:"SUBJECT" env-default:"payments"` // префикс subject в NATS, топик в Kafka
+	NATSURL      string   `yaml:"NATSURL" env:"NATS_URL" env-default:"nats://localhost:4222"`
+	NATSStream   string   `yaml:"NATSStream" env:"NATS_STREAM" env-default:"PAYMENTS"`                             // стрим JetStream, создается при запуске
+	KafkaBrokers []string `yaml:"KafkaBrokers" env:"KAFKA_BROKERS" env-separator:"," env-default:"localhost:9092"` // адреса через запятую
+}
+
+// Outbox релей событий из outbox в брокер
+type Outbox struct {
+	PollInterval time.Duration `yaml:"PollInterval" env:"POLL_INTERVAL" env-default:"1s"` // пауза, когда неопубликованных событий нет или брокер недоступен
+	BatchSize    int           `yaml:"BatchSize" env:"BATCH_SIZE" env-default:"100"`
+	Retention    time.Duration `yaml:"Retention" env:"RETENTION" env-default:"168h"` // сколько хранятся опубликованные события
+}
+
+// Webhooks отправка вебхуков интеграторам
+type Webhooks struct {
+	PollInterval time.Duration `yaml:"PollInterval" env:"POLL_INTERVAL" env-default:"1s"`
+	BatchSize    int           `yaml:"BatchSize" env:"BATCH_SIZE" env-default:"50"`
+	Timeout      time.Duration `yaml:"Timeout" env:"TIMEOUT" env-default:"10s"`            // ожидание ответа адреса
+	RetryInitial time.Duration `yaml:"RetryInitial" env:"RETRY_INITIAL" env-default:"30s"` // пауза после первой неудачи, дальше удваивается
+	RetryMax     time.Duration `yaml:"RetryMax" env:"RETRY_MAX" env-default:"1h"`
+	MaxAttempts  int           `yaml:"MaxAttempts" env:"MAX_ATTEMPTS" env-default:"10"` // после стольких неудач доставка переходит в FAILED
+	AllowHTTP    bool          `yaml:"AllowHTTP" env:"ALLOW_HTTP" env-default:"false"`  // разрешить адреса http://, по умолчанию только https
+}
+
+// Fees комиссия платформы, удерживается из выплаты получателю. Без правил платежи бесплатные
+type Fees struct {
+	Rules FeeRules `yaml:"Rules" env:"RULES"` // в переменной окружения - JSON-массив правил
+}
+
+// FeeRule правило комиссии. Суммы в минорных единицах валюты платежа, проценты - от суммы платежа
+type FeeRule struct {
+	Currency  string  `yaml:"Currency" json:"currency"`    // пусто - любая валюта
+	MinAmount int64   `yaml:"MinAmount" json:"min_amount"` // диапазон суммы платежа [MinAmount, MaxAmount), 0 в MaxAmount - без верхней границы
+	MaxAmount int64   `yaml:"MaxAmount" json:"max_amount"`
+	Fixed     int64   `yaml:"Fixed" json:"fixed"`
+	Percent   float64 `yaml:"Percent" json:"percent"`
+	MinFee    int64   `yaml:"MinFee" json:"min_fee"` // 0 - без ограничения
+	MaxFee    int64   `yaml:"MaxFee" json:"max_fee"`
+	FXMarkup  float64 `yaml:"FXMarkup" json:"fx_markup"` // процент сверху за конвертацию, если платеж не в рублях
+}
+
+// FeeRules правила комиссии, применяется первое подходящее
+type FeeRules []FeeRule
+
+// SetValue разбор правил из переменной окружения
+func (r *FeeRules) SetValue(value string) error {
+	if value == "" {
+		*r = nil
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), r); err != nil {
+		return fmt.Errorf("invalid fee rules: %w", err)
+	}
+	return nil
+}
+
+// Risk правила проверки платежа до его создания. Суммы в минорных единицах по валютам,
+// нулевой лимит или валюта без лимита - без ограничения
+type Risk struct {
+	Enabled               bool             `yaml:"Enabled" env:"ENABLED" env-default:"true"`     // false - выключены лимиты, черный список и платеж самому себе проверяются всегда
+	Blocklist             []string         `yaml:"Blocklist" env:"BLOCKLIST" env-separator:","`  // пользователи, которые не могут ни платить, ни получать платежи
+	MaxAmount             map[string]int64 `yaml:"MaxAmount" env:"MAX_AMOUNT" env-separator:","` // RUB:10000000,USD:100000
+	HourlyCount           int              `yaml:"HourlyCount" env:"HOURLY_COUNT"`               // платежей от одного пользователя за час
+	DailyCount            int              `yaml:"DailyCount" env:"DAILY_COUNT"`
+	HourlyAmount          map[string]int64 `yaml:"HourlyAmount" env:"HOURLY_AMOUNT" env-separator:","` // сумма платежей от одного пользователя за час
+	DailyAmount           map[string]int64 `yaml:"DailyAmount" env:"DAILY_AMOUNT" env-separator:","`
+	NewRecipientCooling   time.Duration    `yaml:"NewRecipientCooling" env:"NEW_RECIPIENT_COOLING" env-default:"24h"`      // сколько получатель считается новым после первой оплаты ему
+	NewRecipientMaxAmount map[string]int64 `yaml:"NewRecipientMaxAmount" env:"NEW_RECIPIENT_MAX_AMOUNT" env-separator:","` // платеж новому получателю больше этой суммы уходит на проверку
+}
+
+// Auth конфигурация сервиса авторизации
+type Auth struct {
+	Address string   `yaml:"Address" env:"ADDRESS" env-default:"localhost:8888"`
+	Admins  []string `yaml:"Admins" env:"ADMINS" env-separator:","` // id пользователей с доступом ко всем платежам
+}
+
+// Cache конфигурация кэша платежей в Redis
+type Cache struct {
+	Enabled    bool          `yaml:"Enabled" env:"ENABLED" env-default:"true"` // false - все чтения идут в бд
+	PaymentTTL time.Duration `yaml:"PaymentTTL" env:"PAYMENT_TTL" env-default:"10m"`
+	HistoryTTL time.Duration `yaml:"HistoryTTL" env:"HISTORY_TTL" env-default:"10m"`
+	DetailsTTL time.Duration `yaml:"DetailsTTL" env:"DETAILS_TTL" env-default:"10m"`
+}
+
+// Tracing конфигурация трассировки OpenTelemetry
+type Tracing struct {
+	Exporter    string  `yaml:"Exporter" env:"EXPORTER" env-default:"none"`           // none, stdout или otlp
+	Endpoint    string  `yaml:"Endpoint" env:"ENDPOINT" env-default:"localhost:4317"` // адрес OTLP-коллектора по gRPC
+	Insecure    bool    `yaml:"Insecure" env:"INSECURE" env-default:"true"`
+	ServiceName string  `yaml:"ServiceName" env:"SERVICE_NAME" env-default:"payment-service"`
+	SampleRatio float64 `yaml:"SampleRatio" env:"SAMPLE_RATIO" env-default:"1"` // доля трассируемых запросов
 }
 
 // LoadConfig загрузка конфигурации
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,6 +21,8 @@ func TestLoadConfig_FromEnvironment(t *testing.T) {
 	t.Setenv("YOOMONEY_TOKEN", "yoomoneytoken")
 	t.Setenv("YOOMONEY_CLIENT_ID", "yoomoneyclientid")
 	t.Setenv("YOOMONEY_RECEIVER", "12345")
+	t.Setenv("RISK_MAX_AMOUNT", "RUB:100000000,USD:1000000")
+	t.Setenv("FEES_RULES", `[{"currency":"RUB","percent":2.5,"min_fee":1000}]`)
 
 	config, err := LoadConfig()
 	assert.NoError(t, err)
@@ -37,6 +40,26 @@ func TestLoadConfig_FromEnvironment(t *testing.T) {
 	assert.Equal(t, "yoomoneytoken", config.Yoomoney.Token)
 	assert.Equal(t, "yoomoneyclientid", config.Yoomoney.ClientID)
 	assert.Equal(t, 12345, config.Yoomoney.Receiver)
+	assert.True(t, config.Cache.Enabled)
+	assert.Equal(t, 10*time.Minute, config.Cache.HistoryTTL)
+	assert.Equal(t, 4, config.Demon.Workers)
+	assert.Equal(t, 10, config.Demon.MaxAttempts)
+	assert.Equal(t, 24*time.Hour, config.Expiration.Lifetime)
+	assert.Equal(t, 100, config.Expiration.BatchSize)
+	assert.Equal(t, time.Hour, config.Reconciliation.Interval)
+	assert.False(t, config.Reconciliation.AutoFix)
+	assert.Equal(t, 5*time.Minute, config.Refunds.CheckAfter)
+	assert.Equal(t, 24*time.Hour, config.Refunds.FailAfter)
+	assert.Equal(t, "memory", config.Broker.Type)
+	assert.Equal(t, []string{"localhost:9092"}, config.Broker.KafkaBrokers)
+	assert.Equal(t, 168*time.Hour, config.Outbox.Retention)
+	assert.Equal(t, 30*time.Second, config.Webhooks.RetryInitial)
+	assert.Equal(t, 10, config.Webhooks.MaxAttempts)
+	assert.False(t, config.Webhooks.AllowHTTP)
+	assert.True(t, config.Risk.Enabled)
+	assert.Equal(t, map[string]int64{"RUB": 100000000, "USD": 1000000}, config.Risk.MaxAmount)
+	assert.Equal(t, 24*time.Hour, config.Risk.NewRecipientCooling)
+	assert.Equal(t, FeeRules{{Currency: "RUB", Percent: 2.5, MinFee: 1000}}, config.Fees.Rules)
 }
 
 func TestLoadConfig_InvalidFile(t *testing.T) {
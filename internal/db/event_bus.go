@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	eventChannelPrefix = "payment_events:"
+	eventBufferSize    = 16
+)
+
+// PaymentEventBus рассылка смен статусов платежей подписчикам
+type PaymentEventBus interface {
+	Publish(ctx context.Context, event *models.PaymentEvent) error
+	// Subscribe подписка на события платежа, отписка через возвращаемую функцию
+	Subscribe(paymentID string) (<-chan *models.PaymentEvent, func())
+}
+
+// LocalEventBus рассылка событий подписчикам внутри одного экземпляра сервиса
+type LocalEventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan *models.PaymentEvent]struct{}
+	closed      bool
+	logger      *zap.Logger
+}
+
+// NewLocalEventBus создание локальной рассылки
+func NewLocalEventBus(logger *zap.Logger) *LocalEventBus {
+	return &LocalEventBus{
+		subscribers: make(map[string]map[chan *models.PaymentEvent]struct{}),
+		logger:      logger,
+	}
+}
+
+func (b *LocalEventBus) Publish(ctx context.Context, event *models.PaymentEvent) error {
+	b.dispatch(event)
+	return nil
+}
+
+func (b *LocalEventBus) Subscribe(paymentID string) (<-chan *models.PaymentEvent, func()) {
+	ch := make(chan *models.PaymentEvent, eventBufferSize)
+
+	b.mu.Lock()
+	if b.closed { // сервис останавливается, новых подписок нет
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers[paymentID] == nil {
+		b.subscribers[paymentID] = make(map[chan *models.PaymentEvent]struct{})
+	}
+	b.subscribers[paymentID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[paymentID][ch]; !ok { // канал уже закрыт в Close
+				return
+			}
+			delete(b.subscribers[paymentID], ch)
+			if len(b.subscribers[paymentID]) == 0 {
+				delete(b.subscribers, paymentID)
+			}
+			close(ch)
+		})
+	}
+}
+
+// Close закрытие всех подписок, чтобы потоки WatchPayment завершились до остановки gRPC-сервера
+func (b *LocalEventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, channels := range b.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+	}
+	b.subscribers = make(map[string]map[chan *models.PaymentEvent]struct{})
+	b.closed = true
+}
+
+// dispatch отправка события локальным подписчикам, медленный подписчик пропускает события
+func (b *LocalEventBus) dispatch(event *models.PaymentEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[event.PaymentID] {
+		select {
+		case ch <- event:
+		default:
+			b.logger.Warn("Dropping payment event for slow subscriber", zap.String("payment_id", event.PaymentID), zap.Int64("event_id", event.ID))
+		}
+	}
+}
+
+// RedisEventBus рассылка событий между экземплярами сервиса через Redis pub/sub.
+// Каждый экземпляр держит одну подписку на все платежи и раздает события своим подписчикам.
+type RedisEventBus struct {
+	*LocalEventBus
+	client *redis.Client
+}
+
+// NewRedisEventBus создание рассылки, события начинают приходить после Start
+func NewRedisEventBus(client *redis.Client, logger *zap.Logger) *RedisEventBus {
+	return &RedisEventBus{
+		LocalEventBus: NewLocalEventBus(logger),
+		client:        client,
+	}
+}
+
+// Publish отправка события всем экземплярам, включая текущий
+func (b *RedisEventBus) Publish(ctx context.Context, event *models.PaymentEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, eventChannelPrefix+event.PaymentID, data).Err()
+}
+
+// Start чтение событий из Redis до отмены ctx
+func (b *RedisEventBus) Start(ctx context.Context) {
+	pubsub := b.client.PSubscribe(ctx, eventChannelPrefix+"*")
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var event models.PaymentEvent
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				b.logger.Warn("Dropping malformed payment event", zap.String("channel", message.Channel), zap.Error(err))
+				continue
+			}
+			if event.PaymentID == "" {
+				event.PaymentID = strings.TrimPrefix(message.Channel, eventChannelPrefix)
+			}
+			b.dispatch(&event)
+		}
+	}
+}
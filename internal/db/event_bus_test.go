@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+func receiveEvent(t *testing.T, events <-chan *models.PaymentEvent) *models.PaymentEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("payment event was not delivered")
+		return nil
+	}
+}
+
+func TestLocalEventBus_DeliversToPaymentSubscribers(t *testing.T) {
+	bus := NewLocalEventBus(zap.NewNop())
+
+	events, unsubscribe := bus.Subscribe("payment-1")
+	other, unsubscribeOther := bus.Subscribe("payment-2")
+	defer unsubscribeOther()
+
+	require.NoError(t, bus.Publish(context.Background(), &models.PaymentEvent{ID: 1, PaymentID: "payment-1", NewStatus: models.StatusSuccess}))
+
+	event := receiveEvent(t, events)
+	assert.Equal(t, models.StatusSuccess, event.NewStatus)
+	assert.Empty(t, other)
+
+	unsubscribe()
+	unsubscribe() // повторная отписка безопасна
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestLocalEventBus_CloseEndsSubscriptions(t *testing.T) {
+	bus := NewLocalEventBus(zap.NewNop())
+	events, unsubscribe := bus.Subscribe("payment-1")
+
+	bus.Close()
+	_, open := <-events
+	assert.False(t, open)
+	unsubscribe() // отписка после Close не закрывает канал повторно
+
+	late, _ := bus.Subscribe("payment-1")
+	_, open = <-late
+	assert.False(t, open)
+}
+
+func TestRedisEventBus_FanOutAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newClient := func() *redis.Client {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	publisher := NewRedisEventBus(newClient(), zap.NewNop())
+	watcher := NewRedisEventBus(newClient(), zap.NewNop())
+	go watcher.Start(ctx)
+
+	events, unsubscribe := watcher.Subscribe("payment-1")
+	defer unsubscribe()
+
+	// подписка в редиске появляется асинхронно, публикуем только после нее
+	require.Eventually(t, func() bool {
+		return mr.PubSubNumPat() > 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, publisher.Publish(ctx, &models.PaymentEvent{ID: 7, PaymentID: "payment-1", OldStatus: models.StatusPending, NewStatus: models.StatusSuccess, Actor: models.ActorNotification}))
+
+	event := receiveEvent(t, events)
+	assert.Equal(t, int64(7), event.ID)
+	assert.Equal(t, models.StatusPending, event.OldStatus)
+	assert.Equal(t, models.ActorNotification, event.Actor)
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/tracing"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/utils"
 	"go.uber.org/zap"
 )
@@ -31,6 +33,7 @@ func NewPostgres(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*
 	poolConfig.MinConns = 5
 	poolConfig.MaxConnLifetime = 30 * time.Minute
 	poolConfig.MaxConnIdleTime = 15 * time.Minute
+	poolConfig.ConnConfig.Tracer = tracing.NewPostgresTracer(metrics.PostgresTracer{}) // спаны, время и ошибки запросов
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
@@ -1,26 +1,87 @@
 package db
 
 import (
-	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"context"
+	"fmt"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
+
+	"github.com/go-redis/redis/v8"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
-// Queue асинхронная очередь
+// Типы очередей, которые можно выбрать в конфигурации
+const (
+	QueueTypeMemory = "memory"
+	QueueTypeRedis  = "redis"
+)
+
+// Queue асинхронная очередь.
+// Вместе с платежом хранится контекст трассировки того, кто его поставил,
+// чтобы обработку в демоне можно было связать с исходным запросом.
 type Queue interface {
-	Enqueue(element models.Payment)
-	EnqueueList(data []models.Payment)
-	Dequeue() (models.Payment, bool)
+	Enqueue(ctx context.Context, element models.Payment) error
+	EnqueueList(ctx context.Context, data []models.Payment) error
+	Dequeue() (Delivery, bool)
+	Ack(delivery Delivery) // подтверждение обработки именно этой выдачи
+	// Requeue возврат выдачи в очередь: платеж снова выдается не раньше, чем через delay, вместе с delivery.Attempts,
+	// а текущая выдача подтверждается. Если вернуть не удалось, выдача остается неподтвержденной
+	Requeue(ctx context.Context, delivery Delivery, delay time.Duration) error
+	// Remove удаление платежей из очереди, платеж, поставленный после вызова, снова выдается
+	Remove(ctx context.Context, paymentIDs ...string) (int, error)
+	Stats() metrics.QueueStats       // глубина очереди и возраст самого старого элемента
+	Close(ctx context.Context) error // вызывается при остановке после демона
+}
+
+// Delivery платеж, выданный из очереди. Один платеж может лежать в очереди несколько раз,
+// поэтому подтверждается выдача, а не платеж: Ack одной выдачи не трогает другие
+type Delivery struct {
+	Payment  models.Payment
+	Origin   trace.SpanContext // трассировка того, кто поставил платеж в очередь
+	Attempts int               // ошибок обработки подряд, хранится в самой записи очереди
+	entryID  string            // запись в стриме, пусто для очереди в памяти
+}
+
+// NewQueue создание очереди по типу из конфигурации
+func NewQueue(cfg *config.Config, rdb *redis.Client, logger *zap.Logger) (Queue, error) {
+	switch cfg.Queue.Type {
+	case QueueTypeMemory:
+		return NewPaymentsQueue(), nil
+	case QueueTypeRedis, "":
+		return NewRedisStreamQueue(rdb, cfg.Queue, logger)
+	default:
+		return nil, fmt.Errorf("unknown queue type: %s", cfg.Queue.Type)
+	}
 }
 
 type QueueNode struct {
 	expression models.Payment
+	enqueuedAt time.Time
+	origin     trace.SpanContext
+	attempts   int
 	next       unsafe.Pointer
 }
 
+// delayedNode узел, возвращенный через Requeue и ждущий своего времени
+type delayedNode struct {
+	node  *QueueNode
+	dueAt time.Time
+}
+
 type LockFreeQueue struct {
-	head unsafe.Pointer
-	tail unsafe.Pointer
+	head    unsafe.Pointer
+	tail    unsafe.Pointer
+	depth   atomic.Int64
+	removed sync.Map // id платежа -> время Remove, узлы, добавленные раньше, пропускаются в Dequeue
+
+	delayedMu sync.Mutex
+	delayed   []delayedNode
 }
 
 func NewPaymentsQueue() *LockFreeQueue {
@@ -31,17 +92,20 @@ func NewPaymentsQueue() *LockFreeQueue {
 	}
 }
 
-func (q *LockFreeQueue) Enqueue(element models.Payment) {
-	newNode := &QueueNode{expression: element}
+func (q *LockFreeQueue) Enqueue(ctx context.Context, element models.Payment) error {
+	q.push(&QueueNode{expression: element, enqueuedAt: time.Now(), origin: trace.SpanContextFromContext(ctx)})
+	return nil
+}
 
+func (q *LockFreeQueue) push(newNode *QueueNode) {
 	for {
 		tail := atomic.LoadPointer(&q.tail)
 		next := atomic.LoadPointer(&((*QueueNode)(tail)).next)
-
 		if tail == atomic.LoadPointer(&q.tail) {
 			if next == nil {
 				if atomic.CompareAndSwapPointer(&((*QueueNode)(tail)).next, nil, unsafe.Pointer(newNode)) {
 					atomic.CompareAndSwapPointer(&q.tail, tail, unsafe.Pointer(newNode))
+					q.depth.Add(1)
 					return
 				}
 			} else {
@@ -51,24 +115,138 @@ func (q *LockFreeQueue) Enqueue(element models.Payment) {
 	}
 }
 
-func (q *LockFreeQueue) EnqueueList(data []models.Payment) {
+func (q *LockFreeQueue) EnqueueList(ctx context.Context, data []models.Payment) error {
 	for _, expr := range data {
-		q.Enqueue(expr)
+		q.Enqueue(ctx, expr)
 	}
+	return nil
 }
 
-func (q *LockFreeQueue) Dequeue() (models.Payment, bool) {
+func (q *LockFreeQueue) Dequeue() (Delivery, bool) {
+	q.promoteDue(time.Now())
 	for {
 		head := atomic.LoadPointer(&q.head)
 		next := atomic.LoadPointer(&((*QueueNode)(head)).next)
-
 		if head == atomic.LoadPointer(&q.head) {
 			if next == nil {
-				return models.Payment{}, false
+				return Delivery{}, false
 			}
 			if atomic.CompareAndSwapPointer(&q.head, head, next) {
-				return (*QueueNode)(next).expression, true
+				node := (*QueueNode)(next)
+				q.depth.Add(-1)
+				if q.isRemoved(node) {
+					continue
+				}
+				metrics.QueueWait.Observe(time.Since(node.enqueuedAt).Seconds())
+				return Delivery{Payment: node.expression, Origin: node.origin, Attempts: node.attempts}, true
 			}
 		}
 	}
 }
+
+// Requeue в памяти выдача уже удалена из очереди, поэтому платеж просто откладывается до срока
+func (q *LockFreeQueue) Requeue(ctx context.Context, delivery Delivery, delay time.Duration) error {
+	now := time.Now()
+	node := &QueueNode{expression: delivery.Payment, enqueuedAt: now, origin: trace.SpanContextFromContext(ctx), attempts: delivery.Attempts}
+	if delay <= 0 {
+		q.push(node)
+		return nil
+	}
+
+	q.delayedMu.Lock()
+	defer q.delayedMu.Unlock()
+	q.delayed = append(q.delayed, delayedNode{node: node, dueAt: now.Add(delay)})
+	return nil
+}
+
+// promoteDue перенос отложенных платежей, срок которых наступил, в конец очереди
+func (q *LockFreeQueue) promoteDue(now time.Time) {
+	q.delayedMu.Lock()
+	var due []*QueueNode
+	waiting := q.delayed[:0]
+	for _, delayed := range q.delayed {
+		if delayed.dueAt.After(now) {
+			waiting = append(waiting, delayed)
+			continue
+		}
+		due = append(due, delayed.node)
+	}
+	q.delayed = waiting
+	q.delayedMu.Unlock()
+
+	for _, node := range due {
+		node.enqueuedAt = now
+		q.push(node)
+	}
+}
+
+// Remove из середины lock-free очереди узел не вырезать, поэтому удаленные платежи помечаются
+// и пропускаются при выдаче. Возвращает число помеченных платежей, а не узлов.
+func (q *LockFreeQueue) Remove(ctx context.Context, paymentIDs ...string) (int, error) {
+	now := time.Now()
+	remove := make(map[string]bool, len(paymentIDs))
+	for _, paymentID := range paymentIDs {
+		q.removed.Store(paymentID, now)
+		remove[paymentID] = true
+	}
+	q.pruneRemoved()
+
+	q.delayedMu.Lock()
+	defer q.delayedMu.Unlock()
+	waiting := q.delayed[:0]
+	for _, delayed := range q.delayed {
+		if !remove[delayed.node.expression.ID] {
+			waiting = append(waiting, delayed)
+		}
+	}
+	q.delayed = waiting
+	return len(paymentIDs), nil
+}
+
+// isRemoved узел добавлен раньше, чем платеж удалили из очереди
+func (q *LockFreeQueue) isRemoved(node *QueueNode) bool {
+	removedAt, ok := q.removed.Load(node.expression.ID)
+	return ok && node.enqueuedAt.Before(removedAt.(time.Time))
+}
+
+// pruneRemoved пометки старше головы очереди уже не относятся ни к одному узлу
+func (q *LockFreeQueue) pruneRemoved() {
+	oldest := time.Now()
+	head := atomic.LoadPointer(&q.head)
+	if next := atomic.LoadPointer(&((*QueueNode)(head)).next); next != nil {
+		oldest = (*QueueNode)(next).enqueuedAt
+	}
+	q.removed.Range(func(paymentID, removedAt any) bool {
+		if removedAt.(time.Time).Before(oldest) {
+			q.removed.Delete(paymentID)
+		}
+		return true
+	})
+}
+
+// Ack в памяти элемент удаляется уже при Dequeue, подтверждать нечего
+func (q *LockFreeQueue) Ack(delivery Delivery) {}
+
+// Stats глубина очереди вместе с отложенными платежами и возраст элемента в голове
+func (q *LockFreeQueue) Stats() metrics.QueueStats {
+	stats := metrics.QueueStats{Depth: max(q.depth.Load(), 0) + q.delayedCount()} // счетчик меняется после CAS, поэтому может ненадолго уйти ниже нуля
+	head := atomic.LoadPointer(&q.head)
+	if next := atomic.LoadPointer(&((*QueueNode)(head)).next); next != nil {
+		stats.OldestAge = time.Since((*QueueNode)(next).enqueuedAt)
+	}
+	return stats
+}
+
+func (q *LockFreeQueue) delayedCount() int64 {
+	q.delayedMu.Lock()
+	defer q.delayedMu.Unlock()
+	return int64(len(q.delayed))
+}
+
+// Close очередь в памяти не переживает остановку, оставшиеся платежи только попадают в ошибку
+func (q *LockFreeQueue) Close(ctx context.Context) error {
+	if depth := q.depth.Load() + q.delayedCount(); depth > 0 {
+		return fmt.Errorf("memory queue dropped %d payments", depth)
+	}
+	return nil
+}
@@ -1,58 +1,135 @@
 package db
 
 import (
+	"context"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
 	"testing"
+	"time"
 )
 
 func TestLockFreeQueue_EnqueueDequeue(t *testing.T) {
 	queue := NewPaymentsQueue()
 	payment := models.Payment{
 		ID:     "1234",
-		Amount: 100.0,
+		Amount: models.Money{MinorUnits: 10000, Currency: "RUB"},
 	}
-	queue.Enqueue(payment)
-	dequeuedPayment, ok := queue.Dequeue()
+	queue.Enqueue(context.Background(), payment)
+	delivery, ok := queue.Dequeue()
 	if !ok {
 		t.Errorf("Dequeue returned false, expected true")
 	}
-	if dequeuedPayment.ID != payment.ID {
-		t.Errorf("Expected payment ID %s, but got %s", payment.ID, dequeuedPayment.ID)
+	if delivery.Payment.ID != payment.ID {
+		t.Errorf("Expected payment ID %s, but got %s", payment.ID, delivery.Payment.ID)
 	}
-	if dequeuedPayment.Amount != payment.Amount {
-		t.Errorf("Expected payment amount %f, but got %f", payment.Amount, dequeuedPayment.Amount)
+	if delivery.Payment.Amount != payment.Amount {
+		t.Errorf("Expected payment amount %v, but got %v", payment.Amount, delivery.Payment.Amount)
 	}
 }
 
 func TestLockFreeQueue_EnqueueListDequeue(t *testing.T) {
 	queue := NewPaymentsQueue()
 	payments := []models.Payment{
-		{ID: "1234", Amount: 100.0},
-		{ID: "5678", Amount: 200.0},
-		{ID: "9101", Amount: 300.0},
+		{ID: "1234", Amount: models.Money{MinorUnits: 10000, Currency: "RUB"}},
+		{ID: "5678", Amount: models.Money{MinorUnits: 20000, Currency: "RUB"}},
+		{ID: "9101", Amount: models.Money{MinorUnits: 30000, Currency: "RUB"}},
 	}
-	queue.EnqueueList(payments)
+	queue.EnqueueList(context.Background(), payments)
 	for _, payment := range payments {
-		dequeuedPayment, ok := queue.Dequeue()
+		delivery, ok := queue.Dequeue()
 		if !ok {
 			t.Errorf("Dequeue returned false, expected true")
 		}
-		if dequeuedPayment.ID != payment.ID {
-			t.Errorf("Expected payment ID %s, but got %s", payment.ID, dequeuedPayment.ID)
+		if delivery.Payment.ID != payment.ID {
+			t.Errorf("Expected payment ID %s, but got %s", payment.ID, delivery.Payment.ID)
 		}
-		if dequeuedPayment.Amount != payment.Amount {
-			t.Errorf("Expected payment amount %f, but got %f", payment.Amount, dequeuedPayment.Amount)
+		if delivery.Payment.Amount != payment.Amount {
+			t.Errorf("Expected payment amount %v, but got %v", payment.Amount, delivery.Payment.Amount)
 		}
 	}
 }
 
 func TestLockFreeQueue_DequeueFromEmptyQueue(t *testing.T) {
 	queue := NewPaymentsQueue()
-	dequeuedPayment, ok := queue.Dequeue()
+	delivery, ok := queue.Dequeue()
 	if ok {
 		t.Errorf("Dequeue returned true, expected false when queue is empty")
 	}
-	if dequeuedPayment != (models.Payment{}) {
-		t.Errorf("Expected empty payment, but got %+v", dequeuedPayment)
+	if delivery.Payment != (models.Payment{}) {
+		t.Errorf("Expected empty payment, but got %+v", delivery.Payment)
+	}
+}
+
+func TestLockFreeQueue_Stats(t *testing.T) {
+	queue := NewPaymentsQueue()
+	if stats := queue.Stats(); stats.Depth != 0 || stats.OldestAge != 0 {
+		t.Errorf("Expected empty stats, but got %+v", stats)
+	}
+
+	queue.EnqueueList(context.Background(), []models.Payment{{ID: "1234"}, {ID: "5678"}})
+	time.Sleep(10 * time.Millisecond)
+	stats := queue.Stats()
+	if stats.Depth != 2 {
+		t.Errorf("Expected depth 2, but got %d", stats.Depth)
+	}
+	if stats.OldestAge < 10*time.Millisecond {
+		t.Errorf("Expected oldest age at least 10ms, but got %s", stats.OldestAge)
+	}
+
+	queue.Dequeue()
+	if stats := queue.Stats(); stats.Depth != 1 {
+		t.Errorf("Expected depth 1, but got %d", stats.Depth)
+	}
+}
+
+func TestLockFreeQueue_RemoveSkipsEarlierEntries(t *testing.T) {
+	queue := NewPaymentsQueue()
+	queue.EnqueueList(context.Background(), []models.Payment{{ID: "1234"}, {ID: "5678"}})
+
+	removed, err := queue.Remove(context.Background(), "1234")
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 removed payment, but got %d, %v", removed, err)
+	}
+	queue.Enqueue(context.Background(), models.Payment{ID: "1234"})
+
+	for _, expected := range []string{"5678", "1234"} {
+		delivery, ok := queue.Dequeue()
+		if !ok {
+			t.Fatalf("Dequeue returned false, expected true")
+		}
+		if delivery.Payment.ID != expected {
+			t.Errorf("Expected payment ID %s, but got %s", expected, delivery.Payment.ID)
+		}
+	}
+	if _, ok := queue.Dequeue(); ok {
+		t.Errorf("Dequeue returned true, expected false when queue is empty")
+	}
+}
+
+func TestLockFreeQueue_RequeueHoldsPaymentUntilDue(t *testing.T) {
+	queue := NewPaymentsQueue()
+	queue.Enqueue(context.Background(), models.Payment{ID: "1234"})
+
+	delivery, ok := queue.Dequeue()
+	if !ok {
+		t.Fatalf("Dequeue returned false, expected true")
+	}
+	delivery.Attempts = 3
+	if err := queue.Requeue(context.Background(), delivery, time.Hour); err != nil {
+		t.Fatalf("Requeue failed: %v", err)
+	}
+	if _, ok := queue.Dequeue(); ok {
+		t.Errorf("Requeued payment was handed out before it was due")
+	}
+	if depth := queue.Stats().Depth; depth != 1 {
+		t.Errorf("Expected delayed payment to be counted in depth, got %d", depth)
+	}
+
+	queue.promoteDue(time.Now().Add(2 * time.Hour))
+	redelivered, ok := queue.Dequeue()
+	if !ok {
+		t.Fatalf("Requeued payment was not handed out after it was due")
+	}
+	if redelivered.Payment.ID != "1234" || redelivered.Attempts != 3 {
+		t.Errorf("Expected payment 1234 with 3 attempts, but got %s with %d", redelivered.Payment.ID, redelivered.Attempts)
 	}
 }
@@ -3,6 +3,8 @@ package db
 import (
 	"github.com/go-redis/redis/v8"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/tracing"
 	"go.uber.org/zap"
 )
 
@@ -11,6 +13,8 @@ func InitRedis(cfg *config.Config, logger *zap.Logger) *redis.Client {
 	rdb := redis.NewClient(&redis.Options{
 		Addr: cfg.Redis.URL,
 	})
+	rdb.AddHook(metrics.RedisHook{}) // время и ошибки команд
+	rdb.AddHook(tracing.RedisHook{}) // спаны команд внутри трассируемых запросов
 	logger.Info("Redis connected")
 	return rdb
 }
@@ -0,0 +1,490 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	queuePaymentField  = "payment"
+	queueAttemptsField = "attempts"
+	queueOpTimeout     = 5 * time.Second
+	queueRemoveBatch   = 500 // сколько записей читается за раз при поиске удаляемых
+	queuePromoteBatch  = 100 // сколько отложенных записей переносится в стрим за один Dequeue
+)
+
+// promoteScript атомарный перенос отложенных записей, срок которых наступил, из ZSET в стрим:
+// запись не теряется и не дублируется, даже если Dequeue вызывают несколько потребителей сразу
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, member in ipairs(due) do
+	local entry = cjson.decode(member)
+	local fields = {}
+	for key, value in pairs(entry.values) do
+		fields[#fields + 1] = key
+		fields[#fields + 1] = value
+	end
+	redis.call('XADD', KEYS[1], '*', unpack(fields))
+	redis.call('ZREM', KEYS[2], member)
+end
+return #due
+`)
+
+// delayedEntry отложенная запись в ZSET, id делает одинаковые записи разными элементами множества
+type delayedEntry struct {
+	ID     string            `json:"id"`
+	Values map[string]string `json:"values"`
+}
+
+// RedisStreamQueue надежная очередь на Redis Streams с группой потребителей.
+// Элемент остается в списке ожидающих группы до вызова Ack, а если воркер упал,
+// то после VisibilityTimeout элемент снова выдается из Dequeue.
+// Записи, возвращенные через Requeue с паузой, ждут в ZSET <stream>:delayed со сроком в score.
+type RedisStreamQueue struct {
+	client            *redis.Client
+	logger            *zap.Logger
+	stream            string
+	delayed           string
+	group             string
+	consumer          string
+	visibilityTimeout time.Duration
+
+	inFlight sync.Map // id записи в стриме, выданной и еще не подтвержденной -> id платежа
+}
+
+// NewRedisStreamQueue создание очереди и группы потребителей, если ее еще нет
+func NewRedisStreamQueue(client *redis.Client, cfg config.Queue, logger *zap.Logger) (*RedisStreamQueue, error) {
+	consumer := cfg.Consumer
+	if consumer == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve consumer name: %w", err)
+		}
+		consumer = hostname
+	}
+
+	q := &RedisStreamQueue{
+		client:            client,
+		logger:            logger,
+		stream:            cfg.Stream,
+		delayed:           cfg.Stream + ":delayed",
+		group:             cfg.Group,
+		consumer:          consumer,
+		visibilityTimeout: cfg.VisibilityTimeout,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queueOpTimeout)
+	defer cancel()
+
+	err := client.XGroupCreateMkStream(ctx, q.stream, q.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	return q, nil
+}
+
+// Enqueue контекст трассировки кладется в запись рядом с платежом в заголовках W3C (traceparent)
+func (q *RedisStreamQueue) Enqueue(ctx context.Context, element models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), queueOpTimeout)
+	defer cancel()
+
+	values, err := entryValues(ctx, element, 0)
+	if err != nil {
+		return err
+	}
+
+	err = q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.stream,
+		Values: values,
+	}).Err()
+	if err != nil {
+		q.logger.Error("Failed to enqueue payment", zap.String("payment_id", element.ID), zap.Error(err))
+		return fmt.Errorf("failed to enqueue payment %s: %w", element.ID, err)
+	}
+	return nil
+}
+
+func (q *RedisStreamQueue) EnqueueList(ctx context.Context, data []models.Payment) error {
+	var errs []error
+	for _, element := range data {
+		if err := q.Enqueue(ctx, element); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// entryValues поля записи: платеж, число ошибок подряд и контекст трассировки в заголовках W3C
+func entryValues(ctx context.Context, element models.Payment, attempts int) (map[string]string, error) {
+	data, err := json.Marshal(element)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payment for queue: %w", err)
+	}
+
+	values := map[string]string{queuePaymentField: string(data)}
+	if attempts > 0 {
+		values[queueAttemptsField] = strconv.Itoa(attempts)
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for key, value := range carrier {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Dequeue сначала переносит в стрим отложенные записи, срок которых наступил,
+// затем забирает зависшие у других потребителей записи, потом новые
+func (q *RedisStreamQueue) Dequeue() (Delivery, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueOpTimeout)
+	defer cancel()
+
+	if err := q.promoteDue(ctx, time.Now()); err != nil {
+		q.logger.Warn("Failed to promote delayed queue entries", zap.Error(err))
+	}
+
+	message, ok, err := q.claimStale(ctx)
+	if err == nil && !ok {
+		message, ok, err = q.readNew(ctx)
+	}
+	if err != nil {
+		q.logger.Error("Failed to dequeue payment", zap.Error(err))
+		return Delivery{}, false
+	}
+	if !ok {
+		return Delivery{}, false
+	}
+
+	payment, err := decodeQueueMessage(message)
+	if err != nil { // битую запись подтверждаем, иначе она будет выдаваться бесконечно
+		q.logger.Error("Dropping malformed queue entry", zap.String("entry_id", message.ID), zap.Error(err))
+		q.ackEntry(ctx, message.ID)
+		return Delivery{}, false
+	}
+
+	q.inFlight.Store(message.ID, payment.ID)
+	if enqueuedAt, ok := entryTime(message.ID); ok {
+		metrics.QueueWait.Observe(time.Since(enqueuedAt).Seconds())
+	}
+	return Delivery{Payment: payment, Origin: messageOrigin(message), Attempts: messageAttempts(message), entryID: message.ID}, true
+}
+
+// Requeue копия записи откладывается в ZSET (или сразу в стрим, если паузы нет),
+// а исходная запись подтверждается и удаляется в той же транзакции
+func (q *RedisStreamQueue) Requeue(ctx context.Context, delivery Delivery, delay time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), queueOpTimeout)
+	defer cancel()
+
+	values, err := entryValues(ctx, delivery.Payment, delivery.Attempts)
+	if err != nil {
+		return err
+	}
+	var member []byte
+	if delay > 0 {
+		member, err = json.Marshal(delayedEntry{ID: delivery.entryID, Values: values})
+		if err != nil {
+			return fmt.Errorf("failed to marshal delayed entry: %w", err)
+		}
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if delay > 0 {
+			pipe.ZAdd(ctx, q.delayed, &redis.Z{Score: float64(time.Now().Add(delay).UnixMilli()), Member: string(member)})
+		} else {
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: q.stream, Values: values})
+		}
+		if delivery.entryID != "" {
+			pipe.XAck(ctx, q.stream, q.group, delivery.entryID)
+			pipe.XDel(ctx, q.stream, delivery.entryID)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to requeue payment %s: %w", delivery.Payment.ID, err)
+	}
+	q.inFlight.Delete(delivery.entryID)
+	return nil
+}
+
+// promoteDue перенос в стрим отложенных записей со сроком не позже now
+func (q *RedisStreamQueue) promoteDue(ctx context.Context, now time.Time) error {
+	return promoteScript.Run(ctx, q.client, []string{q.stream, q.delayed}, now.UnixMilli(), queuePromoteBatch).Err()
+}
+
+// Ack подтверждает обработку и удаляет из стрима запись этой выдачи
+func (q *RedisStreamQueue) Ack(delivery Delivery) {
+	if _, ok := q.inFlight.LoadAndDelete(delivery.entryID); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queueOpTimeout)
+	defer cancel()
+
+	q.ackEntry(ctx, delivery.entryID)
+}
+
+func (q *RedisStreamQueue) ackEntry(ctx context.Context, entryID string) {
+	if err := q.client.XAck(ctx, q.stream, q.group, entryID).Err(); err != nil {
+		q.logger.Error("Failed to ack queue entry", zap.String("entry_id", entryID), zap.Error(err))
+		return
+	}
+	if err := q.client.XDel(ctx, q.stream, entryID).Err(); err != nil {
+		q.logger.Warn("Failed to delete acked queue entry", zap.String("entry_id", entryID), zap.Error(err))
+	}
+}
+
+// claimStale переназначает себе запись, которую не подтвердили дольше VisibilityTimeout
+func (q *RedisStreamQueue) claimStale(ctx context.Context) (redis.XMessage, bool, error) {
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: q.stream,
+		Group:  q.group,
+		Idle:   q.visibilityTimeout,
+		Start:  "-",
+		End:    "+",
+		Count:  1,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return redis.XMessage{}, false, nil
+	}
+	if err != nil {
+		return redis.XMessage{}, false, fmt.Errorf("failed to list pending entries: %w", err)
+	}
+	if len(pending) == 0 {
+		return redis.XMessage{}, false, nil
+	}
+
+	messages, err := q.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   q.stream,
+		Group:    q.group,
+		Consumer: q.consumer,
+		MinIdle:  q.visibilityTimeout,
+		Messages: []string{pending[0].ID},
+	}).Result()
+	if err != nil {
+		return redis.XMessage{}, false, fmt.Errorf("failed to claim pending entry: %w", err)
+	}
+	if len(messages) == 0 { // запись успел забрать другой потребитель
+		return redis.XMessage{}, false, nil
+	}
+
+	q.logger.Warn("Redelivering unacknowledged payment", zap.String("entry_id", messages[0].ID), zap.Int64("retry_count", pending[0].RetryCount))
+	return messages[0], true, nil
+}
+
+func (q *RedisStreamQueue) readNew(ctx context.Context) (redis.XMessage, bool, error) {
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.group,
+		Consumer: q.consumer,
+		Streams:  []string{q.stream, ">"},
+		Count:    1,
+		Block:    -1,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return redis.XMessage{}, false, nil
+	}
+	if err != nil {
+		return redis.XMessage{}, false, fmt.Errorf("failed to read from stream: %w", err)
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return redis.XMessage{}, false, nil
+	}
+
+	return streams[0].Messages[0], true, nil
+}
+
+// Remove подтверждение и удаление записей с платежами paymentIDs, в том числе выданных другим потребителям.
+// Стрим просматривается целиком, поэтому удалять лучше сразу пачкой.
+func (q *RedisStreamQueue) Remove(ctx context.Context, paymentIDs ...string) (int, error) {
+	if len(paymentIDs) == 0 {
+		return 0, nil
+	}
+	remove := make(map[string]bool, len(paymentIDs))
+	for _, paymentID := range paymentIDs {
+		remove[paymentID] = true
+	}
+
+	var entryIDs []string
+	start := "-"
+	for {
+		entries, err := q.client.XRangeN(ctx, q.stream, start, "+", queueRemoveBatch).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read queue entries: %w", err)
+		}
+		for _, entry := range entries {
+			if payment, err := decodeQueueMessage(entry); err == nil && remove[payment.ID] {
+				entryIDs = append(entryIDs, entry.ID)
+			}
+		}
+		if len(entries) < queueRemoveBatch {
+			break
+		}
+		start = "(" + entries[len(entries)-1].ID
+	}
+
+	removed, err := q.removeDelayed(ctx, remove)
+	if err != nil {
+		return 0, err
+	}
+	if len(entryIDs) == 0 {
+		return removed, nil
+	}
+
+	if err := q.client.XAck(ctx, q.stream, q.group, entryIDs...).Err(); err != nil {
+		return 0, fmt.Errorf("failed to ack removed entries: %w", err)
+	}
+	deleted, err := q.client.XDel(ctx, q.stream, entryIDs...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete removed entries: %w", err)
+	}
+	return removed + int(deleted), nil
+}
+
+// removeDelayed удаление отложенных записей с платежами из remove
+func (q *RedisStreamQueue) removeDelayed(ctx context.Context, remove map[string]bool) (int, error) {
+	members, err := q.client.ZRange(ctx, q.delayed, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read delayed entries: %w", err)
+	}
+
+	var matched []interface{}
+	for _, member := range members {
+		var entry delayedEntry
+		if err := json.Unmarshal([]byte(member), &entry); err != nil {
+			continue
+		}
+		var payment models.Payment
+		if err := json.Unmarshal([]byte(entry.Values[queuePaymentField]), &payment); err == nil && remove[payment.ID] {
+			matched = append(matched, member)
+		}
+	}
+	if len(matched) == 0 {
+		return 0, nil
+	}
+
+	removed, err := q.client.ZRem(ctx, q.delayed, matched...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete delayed entries: %w", err)
+	}
+	return int(removed), nil
+}
+
+// Close возвращает в стрим выданные этому потребителю и не подтвержденные записи,
+// чтобы другой экземпляр забрал их сразу, а не через VisibilityTimeout
+func (q *RedisStreamQueue) Close(ctx context.Context) error {
+	var errs []error
+	q.inFlight.Range(func(entryID, paymentID any) bool {
+		if err := q.release(ctx, entryID.(string)); err != nil {
+			errs = append(errs, fmt.Errorf("payment %s: %w", paymentID, err))
+			return true
+		}
+		q.inFlight.Delete(entryID)
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+// release копия записи в конец стрима и подтверждение исходной
+func (q *RedisStreamQueue) release(ctx context.Context, entryID string) error {
+	entries, err := q.client.XRange(ctx, q.stream, entryID, entryID).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read queue entry: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := q.client.XAdd(ctx, &redis.XAddArgs{Stream: q.stream, Values: entries[0].Values}).Err(); err != nil {
+		return fmt.Errorf("failed to requeue entry: %w", err)
+	}
+	q.ackEntry(ctx, entryID)
+	return nil
+}
+
+// Stats длина стрима вместе с отложенными записями и возраст самой старой записи в стриме,
+// включая выданные и еще не подтвержденные
+func (q *RedisStreamQueue) Stats() metrics.QueueStats {
+	ctx, cancel := context.WithTimeout(context.Background(), queueOpTimeout)
+	defer cancel()
+
+	var stats metrics.QueueStats
+	depth, err := q.client.XLen(ctx, q.stream).Result()
+	if err != nil {
+		q.logger.Warn("Failed to read queue length", zap.Error(err))
+		return stats
+	}
+	delayed, err := q.client.ZCard(ctx, q.delayed).Result()
+	if err != nil {
+		q.logger.Warn("Failed to read delayed queue length", zap.Error(err))
+	}
+	stats.Depth = depth + delayed
+
+	oldest, err := q.client.XRangeN(ctx, q.stream, "-", "+", 1).Result()
+	if err != nil {
+		q.logger.Warn("Failed to read oldest queue entry", zap.Error(err))
+		return stats
+	}
+	if len(oldest) > 0 {
+		if enqueuedAt, ok := entryTime(oldest[0].ID); ok {
+			stats.OldestAge = time.Since(enqueuedAt)
+		}
+	}
+	return stats
+}
+
+// entryTime время добавления записи из ее id вида <миллисекунды>-<номер>
+func entryTime(entryID string) (time.Time, bool) {
+	millis, _, _ := strings.Cut(entryID, "-")
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// messageOrigin контекст трассировки, с которым платеж поставили в очередь
+func messageOrigin(message redis.XMessage) trace.SpanContext {
+	carrier := propagation.MapCarrier{}
+	for key, value := range message.Values {
+		if raw, ok := value.(string); ok && key != queuePaymentField && key != queueAttemptsField {
+			carrier[key] = raw
+		}
+	}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(ctx)
+}
+
+// messageAttempts число ошибок обработки подряд, сохраненное в записи
+func messageAttempts(message redis.XMessage) int {
+	raw, _ := message.Values[queueAttemptsField].(string)
+	attempts, _ := strconv.Atoi(raw)
+	return attempts
+}
+
+func decodeQueueMessage(message redis.XMessage) (models.Payment, error) {
+	raw, ok := message.Values[queuePaymentField].(string)
+	if !ok {
+		return models.Payment{}, fmt.Errorf("missing %q field", queuePaymentField)
+	}
+
+	var payment models.Payment
+	if err := json.Unmarshal([]byte(raw), &payment); err != nil {
+		return models.Payment{}, fmt.Errorf("failed to unmarshal payment: %w", err)
+	}
+	return payment, nil
+}
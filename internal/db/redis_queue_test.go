@@ -0,0 +1,252 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestRedisQueue(t *testing.T, mockRedis *miniredis.Miniredis, consumer string) *RedisStreamQueue {
+	rdb := redis.NewClient(&redis.Options{Addr: mockRedis.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	queue, err := NewRedisStreamQueue(rdb, config.Queue{
+		Stream:            "payments:check",
+		Group:             "payment-demon",
+		Consumer:          consumer,
+		VisibilityTimeout: time.Minute,
+	}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create redis queue: %v", err)
+	}
+	return queue
+}
+
+func TestRedisStreamQueue_EnqueueDequeueAck(t *testing.T) {
+	mockRedis := miniredis.RunT(t)
+	queue := newTestRedisQueue(t, mockRedis, "worker-1")
+
+	payments := []models.Payment{
+		{ID: "1234", Amount: models.Money{MinorUnits: 10000, Currency: "RUB"}},
+		{ID: "5678", Amount: models.Money{MinorUnits: 20000, Currency: "RUB"}},
+	}
+	queue.EnqueueList(context.Background(), payments)
+
+	for _, payment := range payments {
+		delivery, ok := queue.Dequeue()
+		if !ok {
+			t.Fatalf("Dequeue returned false, expected true")
+		}
+		if delivery.Payment.ID != payment.ID {
+			t.Errorf("Expected payment ID %s, but got %s", payment.ID, delivery.Payment.ID)
+		}
+		if delivery.Payment.Amount != payment.Amount {
+			t.Errorf("Expected payment amount %v, but got %v", payment.Amount, delivery.Payment.Amount)
+		}
+		queue.Ack(delivery)
+	}
+
+	if _, ok := queue.Dequeue(); ok {
+		t.Errorf("Dequeue returned true, expected false when queue is empty")
+	}
+	if length, _ := queue.client.XLen(queue.client.Context(), queue.stream).Result(); length != 0 {
+		t.Errorf("Expected acked entries to be removed, stream length %d", length)
+	}
+}
+
+func TestRedisStreamQueue_AcksDeliveriesOfSamePaymentIndependently(t *testing.T) {
+	mockRedis := miniredis.RunT(t)
+	first := newTestRedisQueue(t, mockRedis, "worker-1")
+	second := newTestRedisQueue(t, mockRedis, "worker-2")
+
+	first.EnqueueList(context.Background(), []models.Payment{{ID: "1234"}, {ID: "1234"}})
+
+	delivered, ok := first.Dequeue()
+	if !ok {
+		t.Fatalf("Dequeue returned false, expected true")
+	}
+	redelivered, ok := second.Dequeue()
+	if !ok {
+		t.Fatalf("Dequeue returned false, expected true")
+	}
+
+	first.Ack(delivered)
+	if length, _ := first.client.XLen(first.client.Context(), first.stream).Result(); length != 1 {
+		t.Fatalf("Expected the second delivery to stay in the stream, stream length %d", length)
+	}
+
+	second.Ack(redelivered)
+	if length, _ := second.client.XLen(second.client.Context(), second.stream).Result(); length != 0 {
+		t.Errorf("Expected acked entries to be removed, stream length %d", length)
+	}
+}
+
+func TestRedisStreamQueue_RequeueHoldsEntryUntilDue(t *testing.T) {
+	mockRedis := miniredis.RunT(t)
+	queue := newTestRedisQueue(t, mockRedis, "worker-1")
+	queue.Enqueue(context.Background(), models.Payment{ID: "1234"})
+
+	delivery, ok := queue.Dequeue()
+	if !ok {
+		t.Fatalf("Dequeue returned false, expected true")
+	}
+	delivery.Attempts = 3
+	if err := queue.Requeue(context.Background(), delivery, time.Hour); err != nil {
+		t.Fatalf("Requeue failed: %v", err)
+	}
+	if length, _ := queue.client.XLen(queue.client.Context(), queue.stream).Result(); length != 0 {
+		t.Errorf("Expected requeued entry to leave the stream, stream length %d", length)
+	}
+	if _, ok := queue.Dequeue(); ok {
+		t.Errorf("Requeued payment was handed out before it was due")
+	}
+	if depth := queue.Stats().Depth; depth != 1 {
+		t.Errorf("Expected delayed entry to be counted in depth, got %d", depth)
+	}
+
+	if err := queue.promoteDue(context.Background(), time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("promoteDue failed: %v", err)
+	}
+	redelivered, ok := queue.Dequeue()
+	if !ok {
+		t.Fatalf("Requeued payment was not handed out after it was due")
+	}
+	if redelivered.Payment.ID != "1234" || redelivered.Attempts != 3 {
+		t.Errorf("Expected payment 1234 with 3 attempts, but got %s with %d", redelivered.Payment.ID, redelivered.Attempts)
+	}
+}
+
+func TestRedisStreamQueue_RemoveDropsDelayedEntries(t *testing.T) {
+	mockRedis := miniredis.RunT(t)
+	queue := newTestRedisQueue(t, mockRedis, "worker-1")
+	queue.Enqueue(context.Background(), models.Payment{ID: "1234"})
+
+	delivery, _ := queue.Dequeue()
+	if err := queue.Requeue(context.Background(), delivery, time.Hour); err != nil {
+		t.Fatalf("Requeue failed: %v", err)
+	}
+	removed, err := queue.Remove(context.Background(), "1234")
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 removed entry, but got %d, %v", removed, err)
+	}
+	if depth := queue.Stats().Depth; depth != 0 {
+		t.Errorf("Expected empty queue after remove, depth %d", depth)
+	}
+}
+
+func TestRedisStreamQueue_RedeliversUnackedAfterVisibilityTimeout(t *testing.T) {
+	mockRedis := miniredis.RunT(t)
+	now := time.Now()
+	mockRedis.SetTime(now)
+
+	crashed := newTestRedisQueue(t, mockRedis, "worker-1")
+	crashed.Enqueue(context.Background(), models.Payment{ID: "1234", Amount: models.Money{MinorUnits: 10000, Currency: "RUB"}})
+
+	if _, ok := crashed.Dequeue(); !ok {
+		t.Fatalf("Dequeue returned false, expected true")
+	}
+
+	survivor := newTestRedisQueue(t, mockRedis, "worker-2")
+	if _, ok := survivor.Dequeue(); ok {
+		t.Errorf("Unacked payment redelivered before visibility timeout")
+	}
+
+	mockRedis.SetTime(now.Add(2 * time.Minute))
+	redelivered, ok := survivor.Dequeue()
+	if !ok {
+		t.Fatalf("Unacked payment was not redelivered after visibility timeout")
+	}
+	if redelivered.Payment.ID != "1234" {
+		t.Errorf("Expected payment ID 1234, but got %s", redelivered.Payment.ID)
+	}
+}
+
+func TestNewQueue_SelectsTypeFromConfig(t *testing.T) {
+	mockRedis := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mockRedis.Addr()})
+	defer rdb.Close()
+	logger := zaptest.NewLogger(t)
+
+	cfg := &config.Config{Queue: config.Queue{Type: QueueTypeMemory}}
+	queue, err := NewQueue(cfg, rdb, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := queue.(*LockFreeQueue); !ok {
+		t.Errorf("expected *LockFreeQueue, got %T", queue)
+	}
+
+	cfg.Queue = config.Queue{Type: QueueTypeRedis, Stream: "s", Group: "g", Consumer: "c", VisibilityTimeout: time.Minute}
+	queue, err = NewQueue(cfg, rdb, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := queue.(*RedisStreamQueue); !ok {
+		t.Errorf("expected *RedisStreamQueue, got %T", queue)
+	}
+
+	cfg.Queue.Type = "kafka"
+	if _, err := NewQueue(cfg, rdb, logger); err == nil {
+		t.Errorf("expected error for unknown queue type")
+	}
+}
+
+func TestRedisStreamQueue_PropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	mockRedis := miniredis.RunT(t)
+	queue := newTestRedisQueue(t, mockRedis, "worker-1")
+
+	origin := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03},
+		SpanID:     trace.SpanID{0x04, 0x05},
+		TraceFlags: trace.FlagsSampled,
+	})
+	queue.Enqueue(trace.ContextWithSpanContext(context.Background(), origin), models.Payment{ID: "1234"})
+
+	delivery, ok := queue.Dequeue()
+	if !ok {
+		t.Fatalf("Dequeue returned false, expected true")
+	}
+	if delivery.Payment.ID != "1234" {
+		t.Errorf("Expected payment ID 1234, but got %s", delivery.Payment.ID)
+	}
+	dequeuedOrigin := delivery.Origin
+	if dequeuedOrigin.TraceID() != origin.TraceID() || dequeuedOrigin.SpanID() != origin.SpanID() {
+		t.Errorf("Expected origin %s/%s, but got %s/%s", origin.TraceID(), origin.SpanID(), dequeuedOrigin.TraceID(), dequeuedOrigin.SpanID())
+	}
+	if !dequeuedOrigin.IsRemote() {
+		t.Errorf("Expected origin to be remote")
+	}
+}
+
+func TestRedisStreamQueue_Remove(t *testing.T) {
+	mockRedis := miniredis.RunT(t)
+	queue := newTestRedisQueue(t, mockRedis, "worker-1")
+
+	queue.EnqueueList(context.Background(), []models.Payment{{ID: "1234"}, {ID: "5678"}, {ID: "1234"}})
+	removed, err := queue.Remove(context.Background(), "1234", "unknown")
+	if err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 removed entries, but got %d", removed)
+	}
+
+	delivery, ok := queue.Dequeue()
+	if !ok || delivery.Payment.ID != "5678" {
+		t.Fatalf("Expected payment 5678, but got %+v, %v", delivery.Payment, ok)
+	}
+	queue.Ack(delivery)
+	if _, ok := queue.Dequeue(); ok {
+		t.Errorf("Dequeue returned true, expected false after remove")
+	}
+}
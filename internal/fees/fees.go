@@ -0,0 +1,113 @@
+package fees
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+// settlementCurrency валюта основного счета, в нее конвертируются платежи при оплате
+const settlementCurrency = "RUB"
+
+// Calculator расчет комиссии платформы по правилам из конфигурации
+type Calculator struct {
+	rules []config.FeeRule
+}
+
+// NewCalculator создание калькулятора, правила проверяются сразу, чтобы ошибка в конфигурации не всплыла на платеже
+func NewCalculator(cfg config.Fees) (*Calculator, error) {
+	for i, rule := range cfg.Rules {
+		if err := validateRule(rule); err != nil {
+			return nil, fmt.Errorf("fee rule %d: %w", i, err)
+		}
+	}
+	return &Calculator{rules: cfg.Rules}, nil
+}
+
+// Fee комиссия за платеж amount в его валюте. Без подходящего правила комиссия нулевая,
+// и она никогда не больше самого платежа
+func (c *Calculator) Fee(amount models.Money) (models.Money, error) {
+	fee := models.Money{Currency: amount.Currency}
+	rule, ok := c.match(amount)
+	if !ok {
+		return fee, nil
+	}
+
+	percent, err := percentOf(amount.MinorUnits, rule.Percent)
+	if err != nil {
+		return models.Money{}, err
+	}
+	fee.MinorUnits = rule.Fixed + percent
+	if rule.MinFee > 0 && fee.MinorUnits < rule.MinFee {
+		fee.MinorUnits = rule.MinFee
+	}
+	if rule.MaxFee > 0 && fee.MinorUnits > rule.MaxFee {
+		fee.MinorUnits = rule.MaxFee
+	}
+
+	if amount.Currency != settlementCurrency { // наценка за конвертацию не ограничивается MinFee и MaxFee
+		markup, err := percentOf(amount.MinorUnits, rule.FXMarkup)
+		if err != nil {
+			return models.Money{}, err
+		}
+		fee.MinorUnits += markup
+	}
+
+	fee.MinorUnits = min(fee.MinorUnits, amount.MinorUnits)
+	return fee, nil
+}
+
+// match первое правило для валюты и суммы платежа
+func (c *Calculator) match(amount models.Money) (config.FeeRule, bool) {
+	for _, rule := range c.rules {
+		if rule.Currency != "" && rule.Currency != amount.Currency {
+			continue
+		}
+		if amount.MinorUnits < rule.MinAmount || rule.MaxAmount > 0 && amount.MinorUnits >= rule.MaxAmount {
+			continue
+		}
+		return rule, true
+	}
+	return config.FeeRule{}, false
+}
+
+func validateRule(rule config.FeeRule) error {
+	if rule.Currency != "" {
+		if _, err := models.LookupCurrency(rule.Currency); err != nil {
+			return err
+		}
+	}
+	switch {
+	case rule.MinAmount < 0 || rule.MaxAmount < 0 || rule.Fixed < 0 || rule.MinFee < 0 || rule.MaxFee < 0:
+		return fmt.Errorf("amounts must not be negative")
+	case rule.MaxAmount > 0 && rule.MaxAmount <= rule.MinAmount:
+		return fmt.Errorf("max amount must be greater than min amount")
+	case rule.MaxFee > 0 && rule.MaxFee < rule.MinFee:
+		return fmt.Errorf("max fee must not be less than min fee")
+	case !validPercent(rule.Percent) || !validPercent(rule.FXMarkup):
+		return fmt.Errorf("percent must be in [0, 100)")
+	}
+	return nil
+}
+
+func validPercent(percent float64) bool {
+	return !math.IsNaN(percent) && percent >= 0 && percent < 100
+}
+
+// percentOf percent процентов от minorUnits с округлением до минорной единицы, половина - вверх
+func percentOf(minorUnits int64, percent float64) (int64, error) {
+	if percent == 0 {
+		return 0, nil
+	}
+	value := new(big.Rat).SetFloat64(percent)
+	value.Mul(value, big.NewRat(minorUnits, 100))
+	result, err := strconv.ParseInt(value.FloatString(0), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("fee out of range: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package fees
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+func rub(minorUnits int64) models.Money {
+	return models.Money{MinorUnits: minorUnits, Currency: "RUB"}
+}
+
+func TestCalculator_Fee(t *testing.T) {
+	calculator, err := NewCalculator(config.Fees{Rules: config.FeeRules{
+		{Currency: "RUB", MaxAmount: 100000, Fixed: 1000},                    // до 1000 рублей - 10 рублей
+		{Currency: "RUB", MinAmount: 100000, Percent: 2.5, MaxFee: 500000},   // дальше 2.5%, не больше 5000 рублей
+		{Fixed: 30, Percent: 2.9, MinFee: 100, MaxFee: 10000, FXMarkup: 1.5}, // остальные валюты
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		amount models.Money
+		fee    models.Money
+	}{
+		{"fixed band", rub(50000), rub(1000)},
+		{"fee capped by amount", rub(500), rub(500)},
+		{"percent band lower bound", rub(100000), rub(2500)},
+		{"percent rounded to minor units", rub(100002), rub(2500)},
+		{"max fee", rub(100000000), rub(500000)},
+		{"min fee with fx markup", models.Money{MinorUnits: 1000, Currency: "USD"}, models.Money{MinorUnits: 115, Currency: "USD"}},
+		{"percent with fx markup", models.Money{MinorUnits: 100000, Currency: "EUR"}, models.Money{MinorUnits: 2930 + 1500, Currency: "EUR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := calculator.Fee(tt.amount)
+			require.NoError(t, err)
+			assert.Equal(t, tt.fee, fee)
+		})
+	}
+}
+
+func TestCalculator_NoRules(t *testing.T) {
+	calculator, err := NewCalculator(config.Fees{})
+	require.NoError(t, err)
+
+	fee, err := calculator.Fee(rub(100000))
+	require.NoError(t, err)
+	assert.Equal(t, rub(0), fee)
+}
+
+func TestNewCalculator_InvalidRules(t *testing.T) {
+	invalid := []config.FeeRule{
+		{Currency: "XXX"},
+		{Percent: 100},
+		{Percent: -1},
+		{MinAmount: 1000, MaxAmount: 500},
+		{MinFee: 1000, MaxFee: 500},
+		{Fixed: -1},
+	}
+	for _, rule := range invalid {
+		_, err := NewCalculator(config.Fees{Rules: config.FeeRules{rule}})
+		assert.Error(t, err, "%+v", rule)
+	}
+}
@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/payment-service/proto"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NewHandler REST/JSON-API поверх gRPC-сервера, маршруты описаны в proto/payment_gateway.yaml.
+// Запросы идут через conn в обычный gRPC-сервер, поэтому проверка токена, метрики и коды ошибок общие:
+// заголовок Authorization передается как метаданные, gRPC-коды переводятся в HTTP-статусы (NotFound -> 404 и т.д.)
+func NewHandler(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}, // имена полей как в proto
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+	)
+	if err := proto.RegisterPaymentServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// Dial подключение шлюза к gRPC-серверу этого же сервиса, трассировка продолжает спан HTTP-запроса
+func Dial(address string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+}
+
+// OpenAPIHandler отдача OpenAPI-документа, сгенерированного protoc-gen-openapiv2
+func OpenAPIHandler(spec []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+}
@@ -0,0 +1,171 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/payment-service/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakePaymentServer struct {
+	proto.UnimplementedPaymentServiceServer
+	created       *proto.CreatePaymentRequest
+	authorization []string
+}
+
+func (f *fakePaymentServer) CreatePayment(ctx context.Context, req *proto.CreatePaymentRequest) (*proto.CreatePaymentResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.created, f.authorization = req, md.Get("authorization")
+	return &proto.CreatePaymentResponse{PaymentId: "p-1"}, nil
+}
+
+func (f *fakePaymentServer) GetPaymentByID(_ context.Context, req *proto.GetPaymentByIDRequest) (*proto.GetPaymentByIDResponse, error) {
+	if req.PaymentId != "p-1" {
+		return nil, status.Error(codes.NotFound, "payment not found")
+	}
+	return &proto.GetPaymentByIDResponse{Id: "p-1", FromUserId: "u-1", ToUserId: "u-2", Status: "PENDING",
+		Money: &proto.Money{MinorUnits: 1050, Currency: "RUB"}}, nil
+}
+
+func (f *fakePaymentServer) GetPaymentLink(_ context.Context, req *proto.GetPaymentLinkRequest) (*proto.GetPaymentLinkResponse, error) {
+	return &proto.GetPaymentLinkResponse{PaymentLink: "https://pay/" + req.PaymentId, Amount: 10.5, Currency: "RUB",
+		Money: &proto.Money{MinorUnits: 1050, Currency: "RUB"}}, nil
+}
+
+func (f *fakePaymentServer) GetPaymentHistory(_ context.Context, req *proto.GetPaymentHistoryRequest) (*proto.GetPaymentHistoryResponse, error) {
+	if req.Limit <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be positive")
+	}
+	return &proto.GetPaymentHistoryResponse{Payment: []*proto.Payment{{Id: req.FromUserId + "-" + string(rune('0'+req.Page))}}}, nil
+}
+
+func newTestGateway(t *testing.T) (*fakePaymentServer, http.Handler) {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	fake := &fakePaymentServer{}
+	proto.RegisterPaymentServiceServer(server, fake)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	handler, err := NewHandler(context.Background(), conn)
+	require.NoError(t, err)
+	return fake, handler
+}
+
+func serve(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGatewayCreatePayment(t *testing.T) {
+	fake, handler := newTestGateway(t)
+
+	rec := serve(handler, http.MethodPost, "/v1/payments",
+		`{"from_user_id":"u-1","to_user_id":"u-2","money":{"minor_units":"1050","currency":"RUB"},"idempotency_key":"k-1"}`)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"payment_id":"p-1"}`, rec.Body.String())
+	require.NotNil(t, fake.created)
+	assert.Equal(t, "u-1", fake.created.FromUserId)
+	assert.Equal(t, int64(1050), fake.created.Money.MinorUnits)
+	assert.Equal(t, "k-1", fake.created.IdempotencyKey)
+	assert.Equal(t, []string{"Bearer token"}, fake.authorization)
+}
+
+func TestGatewayProtoFieldNames(t *testing.T) {
+	_, handler := newTestGateway(t)
+
+	rec := serve(handler, http.MethodGet, "/v1/payments/p-1", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "u-1", body["from_user_id"])
+	assert.Equal(t, map[string]interface{}{"minor_units": "1050", "currency": "RUB"}, body["money"])
+	assert.Contains(t, body, "provider") // пустые поля тоже отдаются
+}
+
+func TestGatewayPaymentLinkKeepsLegacyAmount(t *testing.T) {
+	_, handler := newTestGateway(t)
+
+	rec := serve(handler, http.MethodGet, "/v1/payments/p-1/link", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 10.5, body["amount"]) // старые клиенты читают сумму числом
+	assert.Equal(t, "RUB", body["currency"])
+	assert.Equal(t, map[string]interface{}{"minor_units": "1050", "currency": "RUB"}, body["money"])
+}
+
+func TestGatewayQueryParameters(t *testing.T) {
+	_, handler := newTestGateway(t)
+
+	rec := serve(handler, http.MethodGet, "/v1/users/u-1/payments?page=2&limit=10", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"u-1-2"`)
+}
+
+func TestGatewayStatusCodes(t *testing.T) {
+	_, handler := newTestGateway(t)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{"not found", http.MethodGet, "/v1/payments/missing", http.StatusNotFound},
+		{"invalid argument", http.MethodGet, "/v1/users/u-1/payments", http.StatusBadRequest},
+		{"unimplemented", http.MethodGet, "/v1/payments/p-1/status", http.StatusNotImplemented},
+		{"unknown route", http.MethodGet, "/v1/unknown", http.StatusNotFound},
+		{"wrong method", http.MethodDelete, "/v1/payments/p-1", http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handler, tt.method, tt.target, "")
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	handler := OpenAPIHandler([]byte(`{"swagger":"2.0"}`))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	body, _ := io.ReadAll(rec.Body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"swagger":"2.0"}`, string(body))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/auth"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authorizePayment доступ к платежу есть только у его отправителя, получателя и администратора
+func (h *PaymentHandler) authorizePayment(ctx context.Context, paymentID string) (*models.Payment, error) {
+	payment, err := h.service.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, grpcError(err, "error getting payment")
+	}
+	if !auth.CanAccess(ctx, payment.FromUserID, payment.ToUserID) {
+		return nil, status.Error(codes.PermissionDenied, "access to payment denied")
+	}
+	return payment, nil
+}
+
+// authorizeWebhookEndpoint адрес вебхука и его доставки видны только владельцу и администратору
+func (h *PaymentHandler) authorizeWebhookEndpoint(ctx context.Context, endpointID string) (*models.WebhookEndpoint, error) {
+	endpoint, err := h.service.GetWebhookEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, grpcError(err, "error getting webhook endpoint")
+	}
+	if !auth.CanAccess(ctx, endpoint.UserID) {
+		return nil, status.Error(codes.PermissionDenied, "access to webhook endpoint denied")
+	}
+	return endpoint, nil
+}
+
+// authorizeUser запрос от имени userID разрешен только самому пользователю и администратору.
+// Пустой userID означает текущего пользователя.
+func authorizeUser(ctx context.Context, userID string) (string, error) {
+	user, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	if userID == "" {
+		return user.ID, nil
+	}
+	if !auth.CanAccess(ctx, userID) {
+		return "", status.Error(codes.PermissionDenied, "access to another user's payments denied")
+	}
+	return userID, nil
+}
+
+// authorizeAdmin служебные методы доступны только администраторам
+func authorizeAdmin(ctx context.Context) error {
+	user, ok := auth.UserFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	if !user.Admin {
+		return status.Error(codes.PermissionDenied, "admin access required")
+	}
+	return nil
+}
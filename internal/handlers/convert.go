@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/payment-service/proto"
+)
+
+// moneyToProto сумма в прото-сообщение
+func moneyToProto(money models.Money) *proto.Money {
+	return &proto.Money{
+		MinorUnits: money.MinorUnits,
+		Currency:   money.Currency,
+	}
+}
+
+// moneyFromProto сумма из прото-сообщения с проверкой валюты
+func moneyFromProto(money *proto.Money) (models.Money, error) {
+	return models.NewMoney(money.GetMinorUnits(), money.GetCurrency())
+}
+
+// moneyFromLegacyAmount сумма из устаревшего float-поля, берется кратчайшая запись float32,
+// чтобы 100.1 не превращалось в 100.09999847
+func moneyFromLegacyAmount(amount float32, currency string) (models.Money, error) {
+	return models.ParseMoney(strconv.FormatFloat(float64(amount), 'f', -1, 32), currency)
+}
+
+// paymentToProto платеж в прото-сообщение
+func paymentToProto(payment *models.Payment) *proto.Payment {
+	return &proto.Payment{
+		Id:         payment.ID,
+		FromUserId: payment.FromUserID,
+		ToUserId:   payment.ToUserID,
+		Amount:     float32(payment.Amount.Float64()),
+		Currency:   payment.Amount.Currency,
+		Status:     string(payment.Status),
+		CreatedAt:  payment.CreatedAt.String(),
+		UpdatedAt:  payment.UpdatedAt.String(),
+		Provider:   payment.Provider,
+		Money:      moneyToProto(payment.Amount),
+		Fee:        moneyToProto(payment.Fee),
+	}
+}
+
+// refundToProto возврат в прото-сообщение
+func refundToProto(refund *models.Refund) *proto.Refund {
+	return &proto.Refund{
+		Id:                refund.ID,
+		PaymentId:         refund.PaymentID,
+		Amount:            moneyToProto(refund.Amount),
+		Reason:            refund.Reason,
+		Status:            string(refund.Status),
+		InitiatorId:       refund.InitiatorID,
+		ProviderReference: refund.ProviderRef,
+		CreatedAt:         refund.CreatedAt.String(),
+		UpdatedAt:         refund.UpdatedAt.String(),
+	}
+}
+
+// paymentEventToProto событие истории статусов в прото-сообщение
+func paymentEventToProto(event *models.PaymentEvent) *proto.PaymentEvent {
+	return &proto.PaymentEvent{
+		Id:               event.ID,
+		PaymentId:        event.PaymentID,
+		OldStatus:        string(event.OldStatus),
+		NewStatus:        string(event.NewStatus),
+		Actor:            event.Actor,
+		Reason:           event.Reason,
+		ProviderResponse: event.ProviderResponse,
+		CreatedAt:        event.CreatedAt.String(),
+	}
+}
+
+// historyQueryFromProto фильтры истории из запроса, userID уже проверен на доступ
+func historyQueryFromProto(userID string, req *proto.GetPaymentHistoryRequest) (models.HistoryQuery, error) {
+	cursor, err := models.DecodeCursor(req.Cursor)
+	if err != nil {
+		return models.HistoryQuery{}, err
+	}
+	query := models.HistoryQuery{
+		UserID:    userID,
+		Direction: models.Direction(req.Direction),
+		Currency:  req.Currency,
+		MinAmount: req.MinAmount,
+		MaxAmount: req.MaxAmount,
+		Cursor:    cursor,
+		Page:      int(req.Page),
+		Limit:     int(req.Limit),
+		WithTotal: req.IncludeTotal,
+	}
+	for _, status := range req.Statuses {
+		query.Statuses = append(query.Statuses, models.PaymentStatus(status))
+	}
+	if query.From, err = parseTimeFilter(req.CreatedFrom); err != nil {
+		return models.HistoryQuery{}, err
+	}
+	if query.To, err = parseTimeFilter(req.CreatedTo); err != nil {
+		return models.HistoryQuery{}, err
+	}
+	return query, nil
+}
+
+// parseTimeFilter время в RFC 3339, пустая строка без ограничения
+func parseTimeFilter(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: time must be in RFC 3339 format: %q", models.ErrInvalidHistoryQuery, value)
+	}
+	return parsed, nil
+}
+
+// deadLetterToProto dead letter в прото-сообщение
+func deadLetterToProto(letter *models.DeadLetter) *proto.DeadLetter {
+	return &proto.DeadLetter{
+		PaymentId: letter.PaymentID,
+		Stage:     letter.Stage,
+		LastError: letter.LastError,
+		Attempts:  int32(letter.Attempts),
+		CreatedAt: letter.CreatedAt.String(),
+		UpdatedAt: letter.UpdatedAt.String(),
+	}
+}
+
+// reconciliationReportToProto отчет сверки в прото-сообщение
+func reconciliationReportToProto(report *models.ReconciliationReport) *proto.ReconciliationReport {
+	mismatches := make([]*proto.ReconciliationMismatch, 0, len(report.Mismatches))
+	for _, mismatch := range report.Mismatches {
+		protoMismatch := &proto.ReconciliationMismatch{
+			Kind:          string(mismatch.Kind),
+			PaymentId:     mismatch.PaymentID,
+			OperationId:   mismatch.OperationID,
+			Label:         mismatch.Label,
+			PaymentStatus: string(mismatch.PaymentStatus),
+			Details:       mismatch.Details,
+			Fixed:         mismatch.Fixed,
+		}
+		if mismatch.Expected != nil {
+			protoMismatch.Expected = moneyToProto(*mismatch.Expected)
+		}
+		if mismatch.Actual != nil {
+			protoMismatch.Actual = moneyToProto(*mismatch.Actual)
+		}
+		mismatches = append(mismatches, protoMismatch)
+	}
+
+	return &proto.ReconciliationReport{
+		Id:         report.ID,
+		Provider:   report.Provider,
+		From:       report.From.Format(time.RFC3339),
+		To:         report.To.Format(time.RFC3339),
+		Operations: int32(report.Operations),
+		AutoFix:    report.AutoFix,
+		Fixed:      int32(report.Fixed()),
+		Mismatches: mismatches,
+		CreatedAt:  report.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// webhookEndpointToProto адрес вебхука в прото-сообщение, ключ подписи не заполняется
+func webhookEndpointToProto(endpoint *models.WebhookEndpoint) *proto.WebhookEndpoint {
+	return &proto.WebhookEndpoint{
+		Id:         endpoint.ID,
+		UserId:     endpoint.UserID,
+		Url:        endpoint.URL,
+		EventTypes: endpoint.EventTypes,
+		CreatedAt:  endpoint.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// webhookDeliveryToProto доставка вебхука в прото-сообщение
+func webhookDeliveryToProto(delivery *models.WebhookDelivery) *proto.WebhookDelivery {
+	protoDelivery := &proto.WebhookDelivery{
+		Id:             delivery.ID,
+		EndpointId:     delivery.EndpointID,
+		EventId:        delivery.Event.ID,
+		EventType:      delivery.Event.Type,
+		PaymentId:      delivery.Event.PaymentID,
+		Status:         string(delivery.Status),
+		Attempts:       int32(delivery.Attempts),
+		LastStatusCode: int32(delivery.LastStatusCode),
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt.Format(time.RFC3339),
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		protoDelivery.NextAttemptAt = delivery.NextAttemptAt.Format(time.RFC3339)
+	}
+	if delivery.DeliveredAt != nil {
+		protoDelivery.DeliveredAt = delivery.DeliveredAt.Format(time.RFC3339)
+	}
+	return protoDelivery
+}
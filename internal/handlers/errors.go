@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcError перевод ошибки сервиса в gRPC статус с понятным клиенту кодом
+func grpcError(err error, message string) error {
+	var riskErr *models.RiskError
+	if errors.As(err, &riskErr) {
+		return riskStatus(riskErr.Decision, message)
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, models.ErrUnknownCurrency), errors.Is(err, service.ErrInvalidAmount), errors.Is(err, service.ErrIdempotencyKeyReused),
+		errors.Is(err, models.ErrCurrencyMismatch), errors.Is(err, models.ErrRefundExceedsPayment), errors.Is(err, models.ErrInvalidHistoryQuery),
+		errors.Is(err, service.ErrInvalidReconciliationWindow), errors.Is(err, models.ErrInvalidWebhookEndpoint):
+		code = codes.InvalidArgument
+	case errors.Is(err, repository.ErrPaymentNotFound), errors.Is(err, repository.ErrDeadLetterNotFound), errors.Is(err, repository.ErrReconciliationReportNotFound),
+		errors.Is(err, repository.ErrWebhookEndpointNotFound), errors.Is(err, repository.ErrWebhookDeliveryNotFound):
+		code = codes.NotFound
+	case errors.Is(err, repository.ErrStatusConflict), errors.Is(err, service.ErrIdempotencyInProgress):
+		code = codes.Aborted
+	case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, service.ErrPaymentExpired),
+		errors.Is(err, service.ErrReconciliationUnsupported), errors.Is(err, repository.ErrWebhookDeliveryNotFailed):
+		code = codes.FailedPrecondition
+	case errors.Is(err, service.ErrTooManyWebhookEndpoints):
+		code = codes.ResourceExhausted
+	}
+	return status.Errorf(code, "%s: %v", message, err)
+}
+
+// riskStatus решение правил риска: deny - PermissionDenied, review - FailedPrecondition.
+// Правило и id решения передаются в ErrorInfo, чтобы клиент не разбирал текст ошибки
+func riskStatus(decision *models.RiskDecision, message string) error {
+	code, reason := codes.PermissionDenied, "RISK_DENIED"
+	if decision.Decision == models.RiskReview {
+		code, reason = codes.FailedPrecondition, "RISK_REVIEW"
+	}
+	st := status.Newf(code, "%s: %v", message, &models.RiskError{Decision: decision})
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: "payment.risk",
+		Metadata: map[string]string{
+			"rule":        decision.Rule,
+			"decision":    string(decision.Decision),
+			"decision_id": decision.ID,
+		},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
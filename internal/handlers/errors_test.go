@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcError_RiskDecision(t *testing.T) {
+	cases := []struct {
+		decision models.RiskDecisionKind
+		code     codes.Code
+		reason   string
+	}{
+		{models.RiskDeny, codes.PermissionDenied, "RISK_DENIED"},
+		{models.RiskReview, codes.FailedPrecondition, "RISK_REVIEW"},
+	}
+	for _, c := range cases {
+		decision := &models.RiskDecision{ID: "decision-1", Decision: c.decision, Rule: models.RiskRuleMaxAmount, Reason: "amount above limit"}
+		err := grpcError(fmt.Errorf("wrapped: %w", &models.RiskError{Decision: decision}), "failed to create payment")
+
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, c.code, st.Code())
+		require.Len(t, st.Details(), 1)
+		info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, c.reason, info.Reason)
+		assert.Equal(t, models.RiskRuleMaxAmount, info.Metadata["rule"])
+		assert.Equal(t, "decision-1", info.Metadata["decision_id"])
+	}
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"context"
-	"fmt"
-	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"time"
 
+	"gitlab.crja72.ru/gospec/go8/payment/internal/auth"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/payment-service/proto"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // PaymentHandler структура для ручек оплаты
@@ -24,23 +27,36 @@ func NewPaymentHandler(service *service.PaymentService, logger *zap.Logger) *Pay
 
 // GetPaymentLink ручка получение ссылки на оплату
 func (h *PaymentHandler) GetPaymentLink(ctx context.Context, req *proto.GetPaymentLinkRequest) (*proto.GetPaymentLinkResponse, error) {
-	paymentLink, err := h.service.GetPaymentLink(ctx, req.PaymentId)
+	if _, err := h.authorizePayment(ctx, req.PaymentId); err != nil {
+		return nil, err
+	}
 
+	paymentLink, conversion, err := h.service.GetPaymentLink(ctx, req.PaymentId)
 	if err != nil {
-		return nil, fmt.Errorf("error generating link for payment: %w", err)
+		return nil, grpcError(err, "error generating link for payment")
 	}
 
 	return &proto.GetPaymentLinkResponse{
-		PaymentLink: paymentLink,
+		PaymentLink:   paymentLink,
+		Amount:        conversion.Amount.Float64(),
+		Currency:      conversion.Amount.Currency,
+		Money:         moneyToProto(conversion.Amount),
+		ExchangeRate:  conversion.Rate.Rate,
+		RateSource:    conversion.Rate.Source,
+		RateTimestamp: conversion.Rate.Timestamp.Format(time.RFC3339),
 	}, nil
 }
 
 // GetPayment ручка получения статуса оплаты
 func (h *PaymentHandler) GetPayment(ctx context.Context, req *proto.GetPaymentRequest) (*proto.GetPaymentResponse, error) {
+	if _, err := h.authorizePayment(ctx, req.PaymentId); err != nil {
+		return nil, err
+	}
+
 	paymentStatus, err := h.service.GetPayment(ctx, req.PaymentId)
 
 	if err != nil {
-		return nil, fmt.Errorf("error paying for payment: %w", err)
+		return nil, grpcError(err, "error paying for payment")
 	}
 
 	return &proto.GetPaymentResponse{
@@ -50,9 +66,24 @@ func (h *PaymentHandler) GetPayment(ctx context.Context, req *proto.GetPaymentRe
 
 // CreatePayment Ручка создания оплаты
 func (h *PaymentHandler) CreatePayment(ctx context.Context, req *proto.CreatePaymentRequest) (*proto.CreatePaymentResponse, error) {
-	paymentID, err := h.service.CreatePayment(ctx, req.FromUserId, req.ToUserId, float64(req.Amount), req.Currency)
+	fromUserID, err := authorizeUser(ctx, req.FromUserId) // платить можно только от своего имени
+	if err != nil {
+		return nil, err
+	}
+
+	var amount models.Money
+	if req.Money != nil {
+		amount, err = moneyFromProto(req.Money)
+	} else {
+		amount, err = moneyFromLegacyAmount(req.Amount, req.Currency) // старые клиенты присылают float
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	paymentID, err := h.service.CreatePayment(ctx, fromUserID, req.ToUserId, amount, req.Provider, req.IdempotencyKey)
 	if err != nil {
-		return nil, fmt.Errorf("error creating payment: %w", err)
+		return nil, grpcError(err, "error creating payment")
 	}
 
 	return &proto.CreatePaymentResponse{
@@ -60,85 +91,398 @@ func (h *PaymentHandler) CreatePayment(ctx context.Context, req *proto.CreatePay
 	}, nil
 }
 
-// RefundPayment Ручка создания возврата оплаты
+// RefundPayment Ручка создания возврата оплаты, полного или частичного
 func (h *PaymentHandler) RefundPayment(ctx context.Context, req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error) {
-	err := h.service.RefundPayment(ctx, req.PaymentId)
+	if _, err := h.authorizePayment(ctx, req.PaymentId); err != nil {
+		return nil, err
+	}
+
+	var amount models.Money // без суммы возвращается весь остаток
+	if req.Amount != nil {
+		var err error
+		amount, err = moneyFromProto(req.Amount)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	user, _ := auth.UserFromContext(ctx)
+	refund, err := h.service.RefundPayment(ctx, req.PaymentId, amount, req.Reason, user.ID, req.IdempotencyKey)
+	if err != nil {
+		return nil, grpcError(err, "error refunding payment")
+	}
+
+	payment, err := h.service.GetPaymentByID(ctx, req.PaymentId)
 	if err != nil {
-		return nil, fmt.Errorf("error refunding payment: %w", err)
+		return nil, grpcError(err, "error getting payment")
 	}
 
 	return &proto.RefundPaymentResponse{
-		Status: string(models.StatusRefunded),
+		Status: string(payment.Status),
+		Refund: refundToProto(refund),
+	}, nil
+}
+
+// ListRefunds Ручка получения возвратов по платежу
+func (h *PaymentHandler) ListRefunds(ctx context.Context, req *proto.ListRefundsRequest) (*proto.ListRefundsResponse, error) {
+	payment, err := h.authorizePayment(ctx, req.PaymentId)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds, refunded, err := h.service.ListRefunds(ctx, req.PaymentId)
+	if err != nil {
+		return nil, grpcError(err, "error getting refunds")
+	}
+
+	refundable := models.Money{Currency: payment.Amount.Currency} // после выплаты получателю вернуть уже нечего
+	if payment.Status.IsRefundable() {
+		refundable, err = payment.Amount.Sub(refunded)
+		if err != nil {
+			return nil, grpcError(err, "error calculating refundable amount")
+		}
+	}
+
+	var protoRefunds []*proto.Refund
+	for _, refund := range refunds {
+		protoRefunds = append(protoRefunds, refundToProto(refund))
+	}
+
+	return &proto.ListRefundsResponse{
+		Refunds:    protoRefunds,
+		Refunded:   moneyToProto(refunded),
+		Refundable: moneyToProto(refundable),
 	}, nil
 }
 
 // GetPaymentByID Ручка получения данных оплаты по id
 func (h *PaymentHandler) GetPaymentByID(ctx context.Context, req *proto.GetPaymentByIDRequest) (*proto.GetPaymentByIDResponse, error) {
-	payment, err := h.service.GetPaymentByID(ctx, req.PaymentId)
+	payment, err := h.authorizePayment(ctx, req.PaymentId)
 	if err != nil {
-		return nil, fmt.Errorf("error getting payment: %w", err)
+		return nil, err
 	}
 
 	return &proto.GetPaymentByIDResponse{
 		Id:         payment.ID,
 		FromUserId: payment.FromUserID,
 		ToUserId:   payment.ToUserID,
-		Amount:     float32(payment.Amount),
-		Currency:   payment.Currency,
+		Amount:     float32(payment.Amount.Float64()),
+		Currency:   payment.Amount.Currency,
 		Status:     string(payment.Status),
 		CreatedAt:  payment.CreatedAt.String(),
 		UpdatedAt:  payment.UpdatedAt.String(),
+		Provider:   payment.Provider,
+		Money:      moneyToProto(payment.Amount),
+		Fee:        moneyToProto(payment.Fee),
 	}, nil
 }
 
 // GetPaymentHistory получение истории оплат
 func (h *PaymentHandler) GetPaymentHistory(ctx context.Context, req *proto.GetPaymentHistoryRequest) (*proto.GetPaymentHistoryResponse, error) {
-	payments, err := h.service.GetPaymentHistory(ctx, req.FromUserId, int(req.Page), int(req.Limit))
+	userID, err := authorizeUser(ctx, req.FromUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := historyQueryFromProto(userID, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := h.service.GetPaymentHistory(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("error getting payment history: %w", err)
+		return nil, grpcError(err, "error getting payment history")
 	}
 
 	var protoPayments []*proto.Payment
-	for _, payment := range payments {
-		protoPayments = append(protoPayments, &proto.Payment{
-			Id:         payment.ID,
-			FromUserId: payment.FromUserID,
-			ToUserId:   payment.ToUserID,
-			Amount:     float32(payment.Amount),
-			Currency:   payment.Currency,
-			Status:     string(payment.Status),
-			CreatedAt:  payment.CreatedAt.String(),
-			UpdatedAt:  payment.UpdatedAt.String(),
-		})
+	for _, payment := range page.Payments {
+		protoPayments = append(protoPayments, paymentToProto(payment))
 	}
 
 	return &proto.GetPaymentHistoryResponse{
-		Payment: protoPayments,
+		Payment:    protoPayments,
+		NextCursor: models.EncodeCursor(page.NextCursor),
+		TotalCount: page.Total,
 	}, nil
 }
 
 // GetActivePayments получение активных счетов оплаты
 func (h *PaymentHandler) GetActivePayments(ctx context.Context, req *proto.GetActivePaymentsRequest) (*proto.GetActivePaymentsResponse, error) {
-	payments, err := h.service.GetActivePayments(ctx, req.UserId)
+	userID, err := authorizeUser(ctx, req.UserId)
 	if err != nil {
-		return nil, fmt.Errorf("error getting active payments: %w", err)
+		return nil, err
+	}
+
+	payments, err := h.service.GetActivePayments(ctx, userID)
+	if err != nil {
+		return nil, grpcError(err, "error getting active payments")
 	}
 
 	var protoPayments []*proto.Payment
 	for _, payment := range payments {
-		protoPayments = append(protoPayments, &proto.Payment{
-			Id:         payment.ID,
-			FromUserId: payment.FromUserID,
-			ToUserId:   payment.ToUserID,
-			Amount:     float32(payment.Amount),
-			Currency:   payment.Currency,
-			Status:     string(payment.Status),
-			CreatedAt:  payment.CreatedAt.String(),
-			UpdatedAt:  payment.UpdatedAt.String(),
-		})
+		protoPayments = append(protoPayments, paymentToProto(payment))
 	}
 
 	return &proto.GetActivePaymentsResponse{
 		Payments: protoPayments,
 	}, nil
 }
+
+// GetPaymentEvents Ручка получения истории статусов платежа
+func (h *PaymentHandler) GetPaymentEvents(ctx context.Context, req *proto.GetPaymentEventsRequest) (*proto.GetPaymentEventsResponse, error) {
+	if _, err := h.authorizePayment(ctx, req.PaymentId); err != nil {
+		return nil, err
+	}
+
+	events, err := h.service.GetPaymentEvents(ctx, req.PaymentId)
+	if err != nil {
+		return nil, grpcError(err, "error getting payment events")
+	}
+
+	var protoEvents []*proto.PaymentEvent
+	for _, event := range events {
+		protoEvents = append(protoEvents, paymentEventToProto(event))
+	}
+
+	return &proto.GetPaymentEventsResponse{
+		Events: protoEvents,
+	}, nil
+}
+
+// WatchPayment Ручка потока смен статуса платежа
+func (h *PaymentHandler) WatchPayment(req *proto.WatchPaymentRequest, stream proto.PaymentService_WatchPaymentServer) error {
+	ctx := stream.Context()
+	if _, err := h.authorizePayment(ctx, req.PaymentId); err != nil {
+		return err
+	}
+
+	err := h.service.WatchPayment(ctx, req.PaymentId, func(event *models.PaymentEvent) error {
+		return stream.Send(paymentEventToProto(event))
+	})
+	if err != nil {
+		return grpcError(err, "error watching payment")
+	}
+	return nil
+}
+
+// ListDeadLetters Ручка получения платежей, на которых демон исчерпал попытки
+func (h *PaymentHandler) ListDeadLetters(ctx context.Context, req *proto.ListDeadLettersRequest) (*proto.ListDeadLettersResponse, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	letters, err := h.service.ListDeadLetters(ctx, int(req.Limit))
+	if err != nil {
+		return nil, grpcError(err, "error listing dead letters")
+	}
+
+	var protoLetters []*proto.DeadLetter
+	for _, letter := range letters {
+		protoLetters = append(protoLetters, deadLetterToProto(letter))
+	}
+
+	return &proto.ListDeadLettersResponse{
+		DeadLetters: protoLetters,
+	}, nil
+}
+
+// RequeueDeadLetter Ручка возврата платежа из dead letter в очередь демона
+func (h *PaymentHandler) RequeueDeadLetter(ctx context.Context, req *proto.RequeueDeadLetterRequest) (*proto.RequeueDeadLetterResponse, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	payment, err := h.service.RequeueDeadLetter(ctx, req.PaymentId)
+	if err != nil {
+		return nil, grpcError(err, "error requeueing dead letter")
+	}
+
+	return &proto.RequeueDeadLetterResponse{
+		Status: string(payment.Status),
+	}, nil
+}
+
+// RunReconciliation Ручка сверки платежей с историей операций шлюза
+func (h *PaymentHandler) RunReconciliation(ctx context.Context, req *proto.RunReconciliationRequest) (*proto.ReconciliationReport, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	from, err := time.Parse(time.RFC3339, req.From)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "from must be in RFC 3339 format: %q", req.From)
+	}
+	to := time.Now()
+	if req.To != "" {
+		if to, err = time.Parse(time.RFC3339, req.To); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "to must be in RFC 3339 format: %q", req.To)
+		}
+	}
+
+	report, err := h.service.Reconcile(ctx, req.Provider, from, to, req.AutoFix)
+	if err != nil {
+		return nil, grpcError(err, "error reconciling payments")
+	}
+	return reconciliationReportToProto(report), nil
+}
+
+// GetReconciliationReport Ручка получения отчета сверки
+func (h *PaymentHandler) GetReconciliationReport(ctx context.Context, req *proto.GetReconciliationReportRequest) (*proto.ReconciliationReport, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	report, err := h.service.GetReconciliationReport(ctx, req.ReportId)
+	if err != nil {
+		return nil, grpcError(err, "error getting reconciliation report")
+	}
+	return reconciliationReportToProto(report), nil
+}
+
+// ListReconciliationReports Ручка получения последних отчетов сверки
+func (h *PaymentHandler) ListReconciliationReports(ctx context.Context, req *proto.ListReconciliationReportsRequest) (*proto.ListReconciliationReportsResponse, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	reports, err := h.service.ListReconciliationReports(ctx, int(req.Limit))
+	if err != nil {
+		return nil, grpcError(err, "error listing reconciliation reports")
+	}
+
+	var protoReports []*proto.ReconciliationReport
+	for _, report := range reports {
+		protoReports = append(protoReports, reconciliationReportToProto(report))
+	}
+
+	return &proto.ListReconciliationReportsResponse{
+		Reports: protoReports,
+	}, nil
+}
+
+// CreateWebhookEndpoint Ручка регистрации адреса вебхука, ключ подписи возвращается только здесь
+func (h *PaymentHandler) CreateWebhookEndpoint(ctx context.Context, req *proto.CreateWebhookEndpointRequest) (*proto.WebhookEndpoint, error) {
+	userID, err := authorizeUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := h.service.CreateWebhookEndpoint(ctx, userID, req.Url, req.EventTypes)
+	if err != nil {
+		return nil, grpcError(err, "error creating webhook endpoint")
+	}
+
+	protoEndpoint := webhookEndpointToProto(endpoint)
+	protoEndpoint.Secret = endpoint.Secret
+	return protoEndpoint, nil
+}
+
+// ListWebhookEndpoints Ручка получения адресов вебхуков пользователя
+func (h *PaymentHandler) ListWebhookEndpoints(ctx context.Context, req *proto.ListWebhookEndpointsRequest) (*proto.ListWebhookEndpointsResponse, error) {
+	userID, err := authorizeUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := h.service.ListWebhookEndpoints(ctx, userID)
+	if err != nil {
+		return nil, grpcError(err, "error listing webhook endpoints")
+	}
+
+	var protoEndpoints []*proto.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		protoEndpoints = append(protoEndpoints, webhookEndpointToProto(endpoint))
+	}
+
+	return &proto.ListWebhookEndpointsResponse{
+		Endpoints: protoEndpoints,
+	}, nil
+}
+
+// DeleteWebhookEndpoint Ручка удаления адреса вебхука
+func (h *PaymentHandler) DeleteWebhookEndpoint(ctx context.Context, req *proto.DeleteWebhookEndpointRequest) (*proto.DeleteWebhookEndpointResponse, error) {
+	if _, err := h.authorizeWebhookEndpoint(ctx, req.EndpointId); err != nil {
+		return nil, err
+	}
+
+	if err := h.service.DeleteWebhookEndpoint(ctx, req.EndpointId); err != nil {
+		return nil, grpcError(err, "error deleting webhook endpoint")
+	}
+	return &proto.DeleteWebhookEndpointResponse{}, nil
+}
+
+// ListWebhookDeliveries Ручка получения журнала доставок адреса вебхука
+func (h *PaymentHandler) ListWebhookDeliveries(ctx context.Context, req *proto.ListWebhookDeliveriesRequest) (*proto.ListWebhookDeliveriesResponse, error) {
+	if _, err := h.authorizeWebhookEndpoint(ctx, req.EndpointId); err != nil {
+		return nil, err
+	}
+
+	deliveryStatus := models.WebhookDeliveryStatus(req.Status)
+	switch deliveryStatus {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown webhook delivery status: %q", req.Status)
+	}
+
+	deliveries, err := h.service.ListWebhookDeliveries(ctx, req.EndpointId, deliveryStatus, int(req.Limit))
+	if err != nil {
+		return nil, grpcError(err, "error listing webhook deliveries")
+	}
+
+	var protoDeliveries []*proto.WebhookDelivery
+	for _, delivery := range deliveries {
+		protoDeliveries = append(protoDeliveries, webhookDeliveryToProto(delivery))
+	}
+
+	return &proto.ListWebhookDeliveriesResponse{
+		Deliveries: protoDeliveries,
+	}, nil
+}
+
+// RedeliverWebhook Ручка повторной отправки доставки, исчерпавшей попытки
+func (h *PaymentHandler) RedeliverWebhook(ctx context.Context, req *proto.RedeliverWebhookRequest) (*proto.WebhookDelivery, error) {
+	delivery, err := h.service.GetWebhookDelivery(ctx, req.DeliveryId)
+	if err != nil {
+		return nil, grpcError(err, "error getting webhook delivery")
+	}
+	if _, err := h.authorizeWebhookEndpoint(ctx, delivery.EndpointID); err != nil {
+		return nil, err
+	}
+
+	delivery, err = h.service.RedeliverWebhook(ctx, req.DeliveryId)
+	if err != nil {
+		return nil, grpcError(err, "error redelivering webhook")
+	}
+	return webhookDeliveryToProto(delivery), nil
+}
+
+// GetBalance Ручка получения остатка счета по главной книге
+func (h *PaymentHandler) GetBalance(ctx context.Context, req *proto.GetBalanceRequest) (*proto.GetBalanceResponse, error) {
+	account := req.Account
+	if account != "" {
+		if err := authorizeAdmin(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		userID, err := authorizeUser(ctx, req.UserId)
+		if err != nil {
+			return nil, err
+		}
+		account = models.UserAccount(userID)
+	}
+
+	balances, err := h.service.GetBalance(ctx, account)
+	if err != nil {
+		return nil, grpcError(err, "error getting balance")
+	}
+
+	protoBalances := make([]*proto.Money, 0, len(balances))
+	for _, balance := range balances {
+		protoBalances = append(protoBalances, moneyToProto(balance.Balance))
+	}
+
+	return &proto.GetBalanceResponse{
+		Account:  account,
+		Balances: protoBalances,
+	}, nil
+}
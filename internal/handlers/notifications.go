@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
+	"go.uber.org/zap"
+)
+
+// NotificationHandler HTTP-ручки для уведомлений платежных шлюзов
+type NotificationHandler struct {
+	service        *service.PaymentService
+	yoomoneySecret string
+	logger         *zap.Logger
+}
+
+// NewNotificationHandler создание ручек уведомлений
+func NewNotificationHandler(service *service.PaymentService, yoomoneySecret string, logger *zap.Logger) *NotificationHandler {
+	return &NotificationHandler{service: service, yoomoneySecret: yoomoneySecret, logger: logger}
+}
+
+// Register подключение ручек к роутеру
+func (h *NotificationHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /notifications/yoomoney", h.YooMoney)
+}
+
+// YooMoney ручка уведомлений YooMoney о входящих переводах.
+// На любой ответ кроме 200 YooMoney повторяет уведомление, поэтому 200 отдаем и тем уведомлениям,
+// которые обработать невозможно в принципе (тестовые, чужие метки).
+func (h *NotificationHandler) YooMoney(w http.ResponseWriter, r *http.Request) {
+	if h.yoomoneySecret == "" { // без секрета подпись не проверить
+		http.Error(w, "notifications are disabled", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	notification, err := clients.ParseYooMoneyNotification(r.PostForm, h.yoomoneySecret)
+	if errors.Is(err, clients.ErrInvalidSignature) {
+		h.logger.Warn("Rejected YooMoney notification with invalid signature", zap.String("operation_id", r.PostForm.Get("operation_id")), zap.String("label", r.PostForm.Get("label")))
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if notification.TestNotification || notification.Label == "" {
+		h.logger.Info("Skipping YooMoney notification without payment", zap.String("operation_id", notification.OperationID), zap.Bool("test", notification.TestNotification))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	paid, err := notification.Paid()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	details := fmt.Sprintf("operation_id=%s amount=%s withdraw_amount=%s codepro=%t unaccepted=%t",
+		notification.OperationID, notification.Amount, notification.WithdrawAmount, notification.Codepro, notification.Unaccepted)
+	status, err := h.service.HandleProviderNotification(r.Context(), clients.YooMoneyProviderName, notification.Label, notification.Status(), paid, details)
+	switch {
+	case errors.Is(err, repository.ErrPaymentNotFound), errors.Is(err, service.ErrProviderMismatch):
+		h.logger.Warn("YooMoney notification for unknown payment", zap.String("label", notification.Label), zap.String("operation_id", notification.OperationID), zap.Error(err))
+	case errors.Is(err, service.ErrPaidAmountMismatch): // повтор уведомления не поможет, платеж разбирается вручную
+		h.logger.Warn("YooMoney notification amount does not match payment", zap.String("label", notification.Label), zap.String("operation_id", notification.OperationID), zap.Error(err))
+	case err != nil: // временная ошибка, YooMoney пришлет уведомление повторно
+		h.logger.Error("Failed to handle YooMoney notification", zap.String("label", notification.Label), zap.Error(err))
+		http.Error(w, "failed to handle notification", http.StatusInternalServerError)
+		return
+	default:
+		h.logger.Info("YooMoney notification handled",
+			zap.String("payment_id", notification.Label),
+			zap.String("operation_id", notification.OperationID),
+			zap.String("withdraw_amount", notification.WithdrawAmount),
+			zap.String("status", string(status)),
+		)
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// checkTimeout сколько ждать ответа одной зависимости
+const checkTimeout = 2 * time.Second
+
+// Check проверка одной зависимости, nil если она доступна
+type Check func(ctx context.Context) error
+
+// Checker периодически проверяет зависимости и выставляет статус стандартного gRPC health-сервиса:
+// NOT_SERVING, пока хотя бы одна зависимость недоступна
+type Checker struct {
+	server   *health.Server
+	services []string
+	checks   map[string]Check
+	interval time.Duration
+	logger   *zap.Logger
+}
+
+// NewChecker создание проверки для сервисов services (пустое имя - общий статус сервера)
+func NewChecker(server *health.Server, interval time.Duration, logger *zap.Logger, services ...string) *Checker {
+	return &Checker{
+		server:   server,
+		services: append([]string{""}, services...),
+		checks:   map[string]Check{},
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Add добавление зависимости
+func (c *Checker) Add(name string, check Check) {
+	c.checks[name] = check
+}
+
+// Run проверка зависимостей до отмены ctx
+func (c *Checker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	healthy := true // чтобы первое падение попало в лог
+	for {
+		err := c.checkAll(ctx)
+		status := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		for _, service := range c.services {
+			c.server.SetServingStatus(service, status)
+		}
+		if (err == nil) != healthy {
+			healthy = err == nil
+			if healthy {
+				c.logger.Info("Dependencies recovered")
+			} else {
+				c.logger.Warn("Dependency unavailable", zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown все сервисы переводятся в NOT_SERVING, чтобы балансировщик перестал слать запросы
+func (c *Checker) Shutdown(context.Context) error {
+	c.server.Shutdown()
+	return nil
+}
+
+func (c *Checker) checkAll(ctx context.Context) error {
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := c.checks[name](checkCtx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
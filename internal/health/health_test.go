@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, server *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	assert.NoError(t, err)
+	return resp.GetStatus()
+}
+
+func TestChecker_ReportsDependencyOutage(t *testing.T) {
+	server := health.NewServer()
+	checker := NewChecker(server, 10*time.Millisecond, zap.NewNop(), "payment.PaymentService")
+
+	var redisDown error
+	checker.Add("postgres", func(context.Context) error { return nil })
+	checker.Add("redis", func(context.Context) error { return redisDown })
+	assert.NoError(t, checker.checkAll(context.Background()))
+
+	redisDown = errors.New("connection refused")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		checker.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return servingStatus(t, server, "payment.PaymentService") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, server, ""))
+
+	cancel()
+	<-done
+}
+
+func TestChecker_ShutdownStopsServing(t *testing.T) {
+	server := health.NewServer()
+	checker := NewChecker(server, time.Hour, zap.NewNop(), "payment.PaymentService")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx)
+	assert.Eventually(t, func() bool {
+		return servingStatus(t, server, "payment.PaymentService") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, checker.Shutdown(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, server, "payment.PaymentService"))
+}
@@ -0,0 +1,125 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// component часть приложения: run работает до остановки, stop останавливает ее и освобождает ресурсы
+type component struct {
+	name    string
+	run     func(ctx context.Context) error
+	stop    func(ctx context.Context) error
+	cancel  context.CancelFunc
+	done    chan struct{}
+	started bool
+}
+
+// Lifecycle запуск компонентов приложения и их остановка по сигналу.
+// Компоненты останавливаются в обратном порядке добавления, как defer:
+// сначала серверы и демон, которые пользуются зависимостями, потом сами зависимости.
+type Lifecycle struct {
+	logger     *zap.Logger
+	timeout    time.Duration
+	components []*component
+	errs       chan error
+}
+
+// New создание менеджера, timeout ограничивает всю остановку
+func New(logger *zap.Logger, timeout time.Duration) *Lifecycle {
+	return &Lifecycle{
+		logger:  logger,
+		timeout: timeout,
+		errs:    make(chan error, 1),
+	}
+}
+
+// Add компонент с фоновой работой. Контекст run отменяется, когда до компонента доходит очередь остановки,
+// после чего вызывается stop (может быть nil) и менеджер ждет возврата из run.
+// Ошибка из run останавливает все приложение.
+func (l *Lifecycle) Add(name string, run func(ctx context.Context) error, stop func(ctx context.Context) error) {
+	l.components = append(l.components, &component{name: name, run: run, stop: stop, done: make(chan struct{})})
+}
+
+// OnStop ресурс без фоновой работы, который нужно закрыть при остановке
+func (l *Lifecycle) OnStop(name string, stop func(ctx context.Context) error) {
+	l.Add(name, nil, stop)
+}
+
+// Run запуск компонентов и ожидание SIGINT/SIGTERM или ошибки одного из них, затем остановка
+func (l *Lifecycle) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	for _, c := range l.components {
+		if c.run == nil {
+			continue
+		}
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c.cancel, c.started = cancel, true
+		go func(c *component) {
+			defer close(c.done)
+			if err := c.run(runCtx); err != nil {
+				select {
+				case l.errs <- fmt.Errorf("%s: %w", c.name, err):
+				default:
+				}
+			}
+		}(c)
+	}
+
+	var cause error
+	select {
+	case <-ctx.Done():
+		l.logger.Info("Shutdown signal received")
+	case cause = <-l.errs:
+		l.logger.Error("Component failed, shutting down", zap.Error(cause))
+	}
+
+	return errors.Join(cause, l.shutdown())
+}
+
+// shutdown остановка компонентов в обратном порядке
+func (l *Lifecycle) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(l.components) - 1; i >= 0; i-- {
+		c := l.components[i]
+		started := time.Now()
+		if err := l.stopComponent(ctx, c); err != nil {
+			l.logger.Error("Failed to stop component", zap.String("component", c.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		l.logger.Info("Component stopped", zap.String("component", c.name), zap.Duration("took", time.Since(started)))
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Lifecycle) stopComponent(ctx context.Context, c *component) error {
+	if c.started {
+		c.cancel()
+	}
+	if c.stop != nil {
+		if err := c.stop(ctx); err != nil {
+			return err
+		}
+	}
+	if !c.started {
+		return nil
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("did not stop in time: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,65 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestLifecycle_StopsInReverseOrderAfterFailure(t *testing.T) {
+	app := New(zap.NewNop(), time.Second)
+	var stopped []string
+
+	app.OnStop("database", func(context.Context) error {
+		stopped = append(stopped, "database")
+		return nil
+	})
+	app.Add("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // дорабатывает текущую задачу
+		stopped = append(stopped, "worker finished")
+		return nil
+	}, nil)
+	app.Add("server", func(context.Context) error {
+		return errors.New("port in use")
+	}, func(context.Context) error {
+		stopped = append(stopped, "server")
+		return nil
+	})
+
+	err := app.Run(context.Background())
+	assert.ErrorContains(t, err, "server: port in use")
+	assert.Equal(t, []string{"server", "worker finished", "database"}, stopped)
+}
+
+func TestLifecycle_StopsOnContextCancel(t *testing.T) {
+	app := New(zap.NewNop(), time.Second)
+	ran := make(chan struct{})
+	app.Add("worker", func(ctx context.Context) error {
+		close(ran)
+		<-ctx.Done()
+		return nil
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-ran
+		cancel()
+	}()
+	assert.NoError(t, app.Run(ctx))
+}
+
+func TestLifecycle_ReportsStuckComponent(t *testing.T) {
+	app := New(zap.NewNop(), 20*time.Millisecond)
+	app.Add("stuck", func(context.Context) error {
+		select {} // не реагирует на отмену
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorContains(t, app.Run(ctx), "stuck: did not stop in time")
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor учет входящих unary-вызовов по методу и коду ответа
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		started := time.Now()
+		resp, err := handler(ctx, req)
+		observeGRPC(info.FullMethod, started, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor учет входящих потоков, время считается до закрытия потока
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		started := time.Now()
+		err := handler(srv, ss)
+		observeGRPC(info.FullMethod, started, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor учет исходящих вызовов к сервису service
+func UnaryClientInterceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		started := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		ObserveExternal(service, method, started, err)
+		return err
+	}
+}
+
+func observeGRPC(method string, started time.Time, err error) {
+	code := status.Code(err).String()
+	GRPCRequests.WithLabelValues(method, code).Inc()
+	GRPCDuration.WithLabelValues(method, code).Observe(time.Since(started).Seconds())
+}
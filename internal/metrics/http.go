@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// roundTripper учет HTTP-запросов к внешнему API
+type roundTripper struct {
+	service string
+	next    http.RoundTripper
+}
+
+// NewRoundTripper транспорт с учетом запросов к сервису service, nil означает http.DefaultTransport
+func NewRoundTripper(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &roundTripper{service: service, next: next}
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	observed := err
+	if err == nil && resp.StatusCode >= http.StatusBadRequest {
+		observed = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	ObserveExternal(t.service, req.URL.Path, started, observed)
+	return resp, err
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "payment"
+
+// Результаты вызовов внешних сервисов
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+var (
+	// GRPCRequests число gRPC-вызовов по методу и коду ответа
+	GRPCRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "Number of handled gRPC calls.",
+	}, []string{"method", "code"})
+
+	// GRPCDuration время обработки gRPC-вызовов
+	GRPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Duration of handled gRPC calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// PaymentsCreated число созданных платежей
+	PaymentsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_created_total",
+		Help:      "Number of created payments.",
+	}, []string{"currency", "provider"})
+
+	// StatusTransitions число смен статуса платежей
+	StatusTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payment_status_transitions_total",
+		Help:      "Number of payment status transitions.",
+	}, []string{"from", "to", "currency"})
+
+	// QueueWait сколько платеж пролежал в очереди до выдачи демону
+	QueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_wait_seconds",
+		Help:      "Time a payment spent in the check queue before being dequeued.",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600},
+	})
+
+	// DemonIterations число обработанных демоном платежей
+	DemonIterations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "demon_iterations_total",
+		Help:      "Number of payments processed by the payment demon.",
+	})
+
+	// DemonFailures число ошибок демона по этапу обработки
+	DemonFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "demon_failures_total",
+		Help:      "Number of payment demon failures by processing stage.",
+	}, []string{"stage"})
+
+	// DemonDeadLetters число платежей, переданных демоном в dead letter
+	DemonDeadLetters = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "demon_dead_letters_total",
+		Help:      "Number of payments moved to dead letters after exhausting retries.",
+	})
+
+	// ReconciliationMismatches число расхождений, найденных сверкой с историей шлюза
+	ReconciliationMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconciliation_mismatches_total",
+		Help:      "Number of mismatches found by reconciliation against provider operation history.",
+	}, []string{"provider", "kind"})
+
+	// OutboxPublished число доменных событий, опубликованных в брокер
+	OutboxPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_published_total",
+		Help:      "Number of domain events published from the outbox to the broker.",
+	}, []string{"type"})
+
+	// OutboxFailures число неудачных публикаций событий outbox
+	OutboxFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_publish_failures_total",
+		Help:      "Number of failed attempts to publish an outbox event.",
+	})
+
+	// WebhookDeliveries число попыток доставки вебхуков по результату: delivered, retry, failed
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of webhook delivery attempts by result.",
+	}, []string{"result"})
+
+	// PaidAmountMismatches оплаты из уведомлений и опроса шлюзов, сумма которых не совпала с платежом
+	PaidAmountMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "paid_amount_mismatches_total",
+		Help:      "Number of provider payments, notified or polled, whose credited amount does not match the payment.",
+	}, []string{"provider"})
+
+	// RiskDecisions число решений правил риска по решению и сработавшему правилу
+	RiskDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "risk_decisions_total",
+		Help:      "Number of pre-payment risk decisions by decision and rule.",
+	}, []string{"decision", "rule"})
+
+	// ExternalRequests число вызовов внешних сервисов и хранилищ
+	ExternalRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "external_requests_total",
+		Help:      "Number of calls to external services and storages.",
+	}, []string{"service", "operation", "result"})
+
+	// ExternalDuration время вызовов внешних сервисов и хранилищ
+	ExternalDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "external_request_duration_seconds",
+		Help:      "Duration of calls to external services and storages.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+)
+
+// Handler обработчик /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveExternal учет одного вызова внешнего сервиса
+func ObserveExternal(service, operation string, started time.Time, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultError
+	}
+	ExternalRequests.WithLabelValues(service, operation, result).Inc()
+	ExternalDuration.WithLabelValues(service, operation).Observe(time.Since(started).Seconds())
+}
+
+// PaymentCreated учет созданного платежа
+func PaymentCreated(currency, provider string) {
+	PaymentsCreated.WithLabelValues(currency, provider).Inc()
+}
+
+// StatusChanged учет смены статуса платежа
+func StatusChanged(from, to, currency string) {
+	StatusTransitions.WithLabelValues(from, to, currency).Inc()
+}
+
+// DemonFailed учет ошибки демона на этапе stage
+func DemonFailed(stage string) {
+	DemonFailures.WithLabelValues(stage).Inc()
+}
+
+// ReconciliationMismatch учет расхождения сверки вида kind
+func ReconciliationMismatch(provider, kind string) {
+	ReconciliationMismatches.WithLabelValues(provider, kind).Inc()
+}
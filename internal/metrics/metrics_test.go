@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor_CountsByMethodAndCode(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/payment.PaymentService/GetPayment"}
+
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "payment not found")
+	})
+	assert.Error(t, err)
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(GRPCRequests.WithLabelValues(info.FullMethod, codes.NotFound.String())))
+	assert.Equal(t, 1.0, testutil.ToFloat64(GRPCRequests.WithLabelValues(info.FullMethod, codes.OK.String())))
+}
+
+func TestRoundTripper_CountsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewRoundTripper("test-api", nil)}
+	for _, path := range []string{"/ok", "/broken"} {
+		resp, err := client.Get(server.URL + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(ExternalRequests.WithLabelValues("test-api", "/ok", ResultSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(ExternalRequests.WithLabelValues("test-api", "/broken", ResultError)))
+}
+
+func TestSQLOperation(t *testing.T) {
+	assert.Equal(t, "UPDATE", sqlOperation("\n\tupdate payments SET status = $1"))
+	assert.Equal(t, "SELECT", sqlOperation("SELECT id FROM payments"))
+	assert.Equal(t, "UNKNOWN", sqlOperation("  "))
+}
+
+func TestRedisError_IgnoresMissingKey(t *testing.T) {
+	assert.NoError(t, redisError(redis.Nil))
+	assert.Error(t, redisError(errors.New("connection refused")))
+}
+
+func TestQueueCollector(t *testing.T) {
+	collector := &queueCollector{stats: func() QueueStats { return QueueStats{Depth: 3} }}
+	assert.Equal(t, 2, testutil.CollectAndCount(collector))
+}
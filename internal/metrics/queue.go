@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// QueueStats текущее состояние очереди проверки платежей
+type QueueStats struct {
+	Depth     int64         // сколько платежей ждут выдачи
+	OldestAge time.Duration // сколько ждет самый старый из них
+}
+
+var (
+	queueDepthDesc = prometheus.NewDesc(namespace+"_queue_depth", "Number of payments waiting in the check queue.", nil, nil)
+	queueAgeDesc   = prometheus.NewDesc(namespace+"_queue_oldest_item_age_seconds", "Age of the oldest payment waiting in the check queue.", nil, nil)
+)
+
+// queueCollector снимает состояние очереди при каждом сборе метрик
+type queueCollector struct {
+	stats func() QueueStats
+}
+
+// RegisterQueue регистрация метрик глубины очереди и возраста самого старого платежа
+func RegisterQueue(stats func() QueueStats) error {
+	return prometheus.Register(&queueCollector{stats: stats})
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- queueAgeDesc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(stats.Depth))
+	ch <- prometheus.MustNewConstMetric(queueAgeDesc, prometheus.GaugeValue, stats.OldestAge.Seconds())
+}
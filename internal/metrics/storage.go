@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+)
+
+// Имена хранилищ в метриках внешних вызовов
+const (
+	ServicePostgres = "postgres"
+	ServiceRedis    = "redis"
+)
+
+type (
+	queryKey   struct{}
+	startedKey struct{}
+)
+
+type query struct {
+	started   time.Time
+	operation string
+}
+
+// PostgresTracer учет запросов pgx по первому слову запроса (SELECT, INSERT, ...)
+type PostgresTracer struct{}
+
+func (PostgresTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryKey{}, query{started: time.Now(), operation: sqlOperation(data.SQL)})
+}
+
+func (PostgresTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(queryKey{}).(query)
+	if !ok {
+		return
+	}
+	err := data.Err
+	if errors.Is(err, pgx.ErrNoRows) { // пустой результат не ошибка хранилища
+		err = nil
+	}
+	ObserveExternal(ServicePostgres, q.operation, q.started, err)
+}
+
+// sqlOperation операция по первому слову запроса: "update payments ..." -> UPDATE
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "UNKNOWN"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// RedisHook учет команд go-redis по имени команды
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startedKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if started, ok := ctx.Value(startedKey{}).(time.Time); ok {
+		ObserveExternal(ServiceRedis, cmd.Name(), started, redisError(cmd.Err()))
+	}
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startedKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	started, ok := ctx.Value(startedKey{}).(time.Time)
+	if !ok {
+		return nil
+	}
+	var err error
+	for _, cmd := range cmds {
+		if err = redisError(cmd.Err()); err != nil {
+			break
+		}
+	}
+	ObserveExternal(ServiceRedis, "pipeline", started, err)
+	return nil
+}
+
+// redisError промах по ключу не считается ошибкой
+func redisError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
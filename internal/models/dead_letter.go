@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// DeadLetter платеж, который демон перестал обрабатывать после нескольких ошибок подряд.
+// Возвращается в очередь вручную, после устранения причины.
+type DeadLetter struct {
+	PaymentID string    `json:"payment_id" db:"payment_id"`
+	Stage     string    `json:"stage" db:"stage"` // этап последней ошибки: fetch, poll, provider, receiver, status, payout
+	LastError string    `json:"last_error" db:"last_error"`
+	Attempts  int       `json:"attempts" db:"attempts"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
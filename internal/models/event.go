@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Компоненты, которые меняют статус платежа
+const (
+	ActorAPI            = "api"            // запрос клиента без аутентифицированного пользователя
+	ActorPoller         = "poller"         // опрос шлюза
+	ActorNotification   = "notification"   // уведомление шлюза
+	ActorDemon          = "payment-demon"  // выплата получателю
+	ActorRefund         = "refund"         // возврат средств
+	ActorExpiration     = "expiration"     // истечение срока оплаты
+	ActorReconciliation = "reconciliation" // сверка с историей операций шлюза
+)
+
+// maxProviderResponse сколько символов ответа шлюза сохраняется в событии
+const maxProviderResponse = 1024
+
+// StatusChange смена статуса платежа из Expected в Status с описанием причины
+type StatusChange struct {
+	Expected         PaymentStatus
+	Status           PaymentStatus
+	Actor            string
+	Reason           string
+	ProviderResponse string
+}
+
+// PaymentEvent запись в истории статусов платежа
+type PaymentEvent struct {
+	ID               int64         `json:"id" db:"id"`
+	PaymentID        string        `json:"payment_id" db:"payment_id"`
+	OldStatus        PaymentStatus `json:"old_status" db:"old_status"` // пустой у события создания платежа
+	NewStatus        PaymentStatus `json:"new_status" db:"new_status"`
+	Actor            string        `json:"actor" db:"actor"`
+	Reason           string        `json:"reason" db:"reason"`
+	ProviderResponse string        `json:"provider_response" db:"provider_response"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+}
+
+// ProviderResponseSnippet обрезанный ответ шлюза для истории статусов
+func ProviderResponseSnippet(response string) string {
+	runes := []rune(response)
+	if len(runes) <= maxProviderResponse {
+		return response
+	}
+	return string(runes[:maxProviderResponse]) + "..."
+}
+
+// UserActor актор для изменений по запросу пользователя
+func UserActor(userID string) string {
+	if userID == "" {
+		return ActorAPI
+	}
+	return "user:" + userID
+}
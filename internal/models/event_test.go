@@ -0,0 +1,22 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderResponseSnippet(t *testing.T) {
+	assert.Equal(t, "status=success", ProviderResponseSnippet("status=success"))
+
+	long := strings.Repeat("я", maxProviderResponse+10)
+	snippet := ProviderResponseSnippet(long)
+	assert.Equal(t, maxProviderResponse+3, len([]rune(snippet)))
+	assert.True(t, strings.HasSuffix(snippet, "..."))
+}
+
+func TestUserActor(t *testing.T) {
+	assert.Equal(t, "user:42", UserActor("42"))
+	assert.Equal(t, ActorAPI, UserActor(""))
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidHistoryQuery недопустимые фильтры или курсор истории платежей
+var ErrInvalidHistoryQuery = errors.New("invalid payment history query")
+
+// Лимиты страницы истории
+const (
+	DefaultHistoryLimit = 20
+	MaxHistoryLimit     = 100
+)
+
+// Direction какие платежи пользователя попадают в историю
+type Direction string
+
+const (
+	DirectionBoth     Direction = "both"     // отправленные и полученные
+	DirectionOutgoing Direction = "outgoing" // пользователь отправитель
+	DirectionIncoming Direction = "incoming" // пользователь получатель
+)
+
+// HistoryCursor позиция в истории: последний платеж предыдущей страницы.
+// История отсортирована по (created_at, id) от новых к старым, следующая страница начинается строго после курсора.
+type HistoryCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// HistoryQuery выборка истории платежей пользователя, пустые поля фильтров не ограничивают выборку
+type HistoryQuery struct {
+	UserID    string
+	Direction Direction
+	Statuses  []PaymentStatus
+	Currency  string
+	MinAmount int64 // в минорных единицах Currency, 0 без ограничения
+	MaxAmount int64
+	From      time.Time // created_at >= From
+	To        time.Time // created_at < To
+	Cursor    *HistoryCursor
+	Page      int // устаревшая постраничная выборка через OFFSET, только без курсора
+	Limit     int
+	WithTotal bool // посчитать число платежей по фильтрам без учета курсора
+}
+
+// HistoryPage страница истории
+type HistoryPage struct {
+	Payments   []*Payment
+	NextCursor *HistoryCursor // nil на последней странице
+	Total      int64          // только при HistoryQuery.WithTotal
+}
+
+// Normalize значения по умолчанию и проверка фильтров
+func (q *HistoryQuery) Normalize() error {
+	switch q.Direction {
+	case "": // раньше история отдавала только отправленные платежи, полученные запрашиваются явно
+		q.Direction = DirectionOutgoing
+	case DirectionBoth, DirectionOutgoing, DirectionIncoming:
+	default:
+		return fmt.Errorf("%w: unknown direction %q", ErrInvalidHistoryQuery, q.Direction)
+	}
+
+	switch {
+	case q.Limit <= 0:
+		q.Limit = DefaultHistoryLimit
+	case q.Limit > MaxHistoryLimit:
+		q.Limit = MaxHistoryLimit
+	}
+
+	for _, status := range q.Statuses {
+		if !status.IsKnown() {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidHistoryQuery, status)
+		}
+	}
+	if q.Currency != "" {
+		if _, err := LookupCurrency(q.Currency); err != nil {
+			return err
+		}
+	}
+	if q.MinAmount < 0 || q.MaxAmount < 0 || q.MaxAmount != 0 && q.MinAmount > q.MaxAmount {
+		return fmt.Errorf("%w: invalid amount range", ErrInvalidHistoryQuery)
+	}
+	if (q.MinAmount != 0 || q.MaxAmount != 0) && q.Currency == "" { // минорные единицы разных валют не сравнимы
+		return fmt.Errorf("%w: amount filter requires currency", ErrInvalidHistoryQuery)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return fmt.Errorf("%w: invalid date range", ErrInvalidHistoryQuery)
+	}
+	if q.Page > 1 && q.Cursor != nil {
+		return fmt.Errorf("%w: page cannot be combined with cursor", ErrInvalidHistoryQuery)
+	}
+	return nil
+}
+
+// Offset сколько платежей пропустить для устаревшего параметра Page
+func (q HistoryQuery) Offset() int {
+	if q.Page <= 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.Limit
+}
+
+// EncodeCursor непрозрачная строка курсора для клиента
+func EncodeCursor(cursor *HistoryCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбор курсора, полученного от EncodeCursor, пустая строка - первая страница
+func DecodeCursor(value string) (*HistoryCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidHistoryQuery)
+	}
+	var cursor HistoryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" || cursor.CreatedAt.IsZero() {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidHistoryQuery)
+	}
+	return &cursor, nil
+}
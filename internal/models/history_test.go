@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryQueryNormalizeDefaults(t *testing.T) {
+	query := HistoryQuery{UserID: "alice"}
+	assert.NoError(t, query.Normalize())
+	assert.Equal(t, DirectionOutgoing, query.Direction) // старые клиенты без направления получают только отправленные
+	assert.Equal(t, DefaultHistoryLimit, query.Limit)
+
+	query = HistoryQuery{UserID: "alice", Limit: 1000, Page: 3}
+	assert.NoError(t, query.Normalize())
+	assert.Equal(t, MaxHistoryLimit, query.Limit)
+	assert.Equal(t, 2*MaxHistoryLimit, query.Offset())
+}
+
+func TestHistoryQueryNormalizeRejectsInvalidFilters(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		query HistoryQuery
+	}{
+		{"unknown direction", HistoryQuery{Direction: "sideways"}},
+		{"unknown status", HistoryQuery{Statuses: []PaymentStatus{"LOST"}}},
+		{"amount without currency", HistoryQuery{MinAmount: 100}},
+		{"negative amount", HistoryQuery{Currency: "RUB", MinAmount: -1}},
+		{"inverted amount range", HistoryQuery{Currency: "RUB", MinAmount: 200, MaxAmount: 100}},
+		{"inverted date range", HistoryQuery{From: now, To: now.Add(-time.Hour)}},
+		{"page with cursor", HistoryQuery{Page: 2, Cursor: &HistoryCursor{CreatedAt: now, ID: "p"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Normalize()
+			assert.True(t, errors.Is(err, ErrInvalidHistoryQuery), "got %v", err)
+		})
+	}
+
+	query := HistoryQuery{Currency: "XXX"}
+	assert.ErrorIs(t, query.Normalize(), ErrUnknownCurrency)
+}
+
+func TestHistoryCursorRoundTrip(t *testing.T) {
+	cursor := &HistoryCursor{CreatedAt: time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC), ID: "3f0c1f9e-5b1e-4d59-9c3c-1a2b3c4d5e6f"}
+
+	decoded, err := DecodeCursor(EncodeCursor(cursor))
+	assert.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+
+	empty, err := DecodeCursor("")
+	assert.NoError(t, err)
+	assert.Nil(t, empty)
+	assert.Equal(t, "", EncodeCursor(nil))
+
+	for _, malformed := range []string{"not base64!", "e30", "bnVsbA"} {
+		_, err := DecodeCursor(malformed)
+		assert.ErrorIs(t, err, ErrInvalidHistoryQuery, malformed)
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "encoding/json"
+
+// IdempotencyRecord сохраненный результат запроса с ключом идемпотентности
+type IdempotencyRecord struct {
+	Operation   string          `json:"operation"`
+	UserID      string          `json:"user_id"` // ключи разных пользователей не пересекаются
+	Key         string          `json:"key"`
+	Fingerprint string          `json:"fingerprint"` // хэш параметров запроса, чтобы отличить повтор от другого запроса с тем же ключом
+	Response    json.RawMessage `json:"response"`
+	Completed   bool            `json:"completed"`
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUnbalancedEntry сумма дебетов проводки не равна сумме кредитов
+var ErrUnbalancedEntry = errors.New("unbalanced ledger entry")
+
+// Счета платформы в главной книге
+const (
+	HoldingAccount = "platform:holding" // собственные средства платформы на основном счете, уходят в минус, когда она возвращает уже выплаченное
+	FeesAccount    = "platform:fees"    // заработанные комиссии
+)
+
+// UserAccount счет пользователя: сколько на основном счете лежит от его имени
+func UserAccount(userID string) string {
+	return "user:" + userID
+}
+
+// ProviderClearingAccount счет шлюза: деньги на основном счете в шлюзе
+func ProviderClearingAccount(provider string) string {
+	return "provider:" + provider + ":clearing"
+}
+
+// AccountKind вид счета по его коду: user, platform или provider
+func AccountKind(account string) string {
+	kind, _, _ := strings.Cut(account, ":")
+	return kind
+}
+
+// CreditNormal кредитовый счет: обязательства перед пользователями и доход платформы.
+// Остаток такого счета показывается как кредит минус дебет.
+func CreditNormal(account string) bool {
+	return AccountKind(account) == "user" || account == FeesAccount
+}
+
+type LedgerEntryKind string
+
+const (
+	EntryFundsReceived  LedgerEntryKind = "funds_received"  // плательщик оплатил, деньги числятся за получателем
+	EntryPayout         LedgerEntryKind = "payout"          // перевод получателю
+	EntryPayoutReversal LedgerEntryKind = "payout_reversal" // перевод получателю не прошел
+	EntryRefund         LedgerEntryKind = "refund"          // возврат плательщику
+)
+
+// LedgerPosting строка проводки: положительная сумма - дебет счета, отрицательная - кредит
+type LedgerPosting struct {
+	Account string `json:"account"`
+	Amount  Money  `json:"amount"`
+}
+
+// LedgerEntry неизменяемая проводка главной книги, сумма строк в каждой валюте равна нулю
+type LedgerEntry struct {
+	ID        string          `json:"id"`
+	Kind      LedgerEntryKind `json:"kind"`
+	PaymentID string          `json:"payment_id"`
+	RefundID  string          `json:"refund_id,omitempty"`
+	Postings  []LedgerPosting `json:"postings"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Validate проверка, что в проводке есть строки без нулевых сумм и она сбалансирована
+func (e *LedgerEntry) Validate() error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: at least two postings required", ErrUnbalancedEntry)
+	}
+	sums := map[string]int64{}
+	for _, posting := range e.Postings {
+		if posting.Amount.MinorUnits == 0 {
+			return fmt.Errorf("%w: zero posting to %s", ErrUnbalancedEntry, posting.Account)
+		}
+		sums[posting.Amount.Currency] += posting.Amount.MinorUnits
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("%w: %s is off by %d minor units", ErrUnbalancedEntry, currency, sum)
+		}
+	}
+	return nil
+}
+
+// AccountBalance остаток счета в одной валюте со стороны его нормального сальдо
+type AccountBalance struct {
+	Account string `json:"account"`
+	Balance Money  `json:"balance"`
+}
+
+// FundsReceivedEntry оплата получена: деньги пришли в шлюз и числятся за получателем до выплаты
+func FundsReceivedEntry(payment *Payment) *LedgerEntry {
+	return transferEntry(EntryFundsReceived, payment, ProviderClearingAccount(payment.Provider), UserAccount(payment.ToUserID), payment.Amount)
+}
+
+// PayoutEntry выплата получателю суммы payout, удержанная комиссия fee переходит платформе
+func PayoutEntry(payment *Payment, payout, fee Money) *LedgerEntry {
+	entry := transferEntry(EntryPayout, payment, UserAccount(payment.ToUserID), ProviderClearingAccount(payment.Provider), payout)
+	return withFee(entry, UserAccount(payment.ToUserID), FeesAccount, fee)
+}
+
+// PayoutReversalEntry отмена выплаты payout, которая не дошла до получателя, вместе с комиссией fee
+func PayoutReversalEntry(payment *Payment, payout, fee Money) *LedgerEntry {
+	entry := transferEntry(EntryPayoutReversal, payment, ProviderClearingAccount(payment.Provider), UserAccount(payment.ToUserID), payout)
+	return withFee(entry, FeesAccount, UserAccount(payment.ToUserID), fee)
+}
+
+// RefundEntry возврат плательщику. Сначала списываются деньги, которые еще числятся за получателем по этому платежу (held),
+// остаток уже выплачен и возвращается из средств платформы
+func RefundEntry(payment *Payment, refund *Refund, held Money) *LedgerEntry {
+	entry := &LedgerEntry{Kind: EntryRefund, PaymentID: payment.ID, RefundID: refund.ID}
+	fromUser := min(max(held.MinorUnits, 0), refund.Amount.MinorUnits)
+	if fromUser > 0 {
+		entry.Postings = append(entry.Postings, LedgerPosting{Account: UserAccount(payment.ToUserID), Amount: Money{MinorUnits: fromUser, Currency: refund.Amount.Currency}})
+	}
+	if fromPlatform := refund.Amount.MinorUnits - fromUser; fromPlatform > 0 {
+		entry.Postings = append(entry.Postings, LedgerPosting{Account: HoldingAccount, Amount: Money{MinorUnits: fromPlatform, Currency: refund.Amount.Currency}})
+	}
+	entry.Postings = append(entry.Postings, LedgerPosting{Account: ProviderClearingAccount(payment.Provider), Amount: Money{MinorUnits: -refund.Amount.MinorUnits, Currency: refund.Amount.Currency}})
+	return entry
+}
+
+// transferEntry проводка на amount по дебету debit и кредиту credit
+func transferEntry(kind LedgerEntryKind, payment *Payment, debit, credit string, amount Money) *LedgerEntry {
+	return &LedgerEntry{
+		Kind:      kind,
+		PaymentID: payment.ID,
+		Postings: []LedgerPosting{
+			{Account: debit, Amount: amount},
+			{Account: credit, Amount: Money{MinorUnits: -amount.MinorUnits, Currency: amount.Currency}},
+		},
+	}
+}
+
+// withFee добавление комиссии fee по дебету debit и кредиту credit, нулевые строки отбрасываются
+func withFee(entry *LedgerEntry, debit, credit string, fee Money) *LedgerEntry {
+	if fee.MinorUnits != 0 {
+		entry.Postings = append(entry.Postings,
+			LedgerPosting{Account: debit, Amount: fee},
+			LedgerPosting{Account: credit, Amount: Money{MinorUnits: -fee.MinorUnits, Currency: fee.Currency}},
+		)
+	}
+	postings := entry.Postings[:0]
+	for _, posting := range entry.Postings {
+		if posting.Amount.MinorUnits != 0 {
+			postings = append(postings, posting)
+		}
+	}
+	entry.Postings = postings
+	return entry
+}
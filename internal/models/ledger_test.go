@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedgerEntry_Validate(t *testing.T) {
+	payment := &Payment{ID: "payment-1", ToUserID: "user-2", Provider: "yoomoney", Amount: Money{MinorUnits: 10000, Currency: "RUB"}}
+	assert.NoError(t, FundsReceivedEntry(payment).Validate())
+	assert.NoError(t, PayoutEntry(payment, Money{MinorUnits: 7000, Currency: "RUB"}, Money{Currency: "RUB"}).Validate())
+
+	unbalanced := &LedgerEntry{Postings: []LedgerPosting{
+		{Account: HoldingAccount, Amount: Money{MinorUnits: 100, Currency: "RUB"}},
+		{Account: FeesAccount, Amount: Money{MinorUnits: -90, Currency: "RUB"}},
+	}}
+	assert.ErrorIs(t, unbalanced.Validate(), ErrUnbalancedEntry)
+
+	mixed := &LedgerEntry{Postings: []LedgerPosting{
+		{Account: HoldingAccount, Amount: Money{MinorUnits: 100, Currency: "RUB"}},
+		{Account: FeesAccount, Amount: Money{MinorUnits: -100, Currency: "USD"}},
+	}}
+	assert.ErrorIs(t, mixed.Validate(), ErrUnbalancedEntry)
+
+	assert.ErrorIs(t, PayoutEntry(payment, Money{Currency: "RUB"}, Money{Currency: "RUB"}).Validate(), ErrUnbalancedEntry)
+}
+
+func TestPayoutEntry_WithFee(t *testing.T) {
+	payment := &Payment{ID: "payment-1", ToUserID: "user-2", Provider: "yoomoney", Amount: Money{MinorUnits: 10000, Currency: "RUB"}}
+
+	entry := PayoutEntry(payment, Money{MinorUnits: 9700, Currency: "RUB"}, Money{MinorUnits: 300, Currency: "RUB"})
+	assert.NoError(t, entry.Validate())
+	assert.Len(t, entry.Postings, 4)
+
+	// вся сумма ушла в комиссию: перевода нет, остается только строка комиссии
+	entry = PayoutEntry(payment, Money{Currency: "RUB"}, Money{MinorUnits: 200, Currency: "RUB"})
+	assert.NoError(t, entry.Validate())
+	assert.Equal(t, []LedgerPosting{
+		{Account: "user:user-2", Amount: Money{MinorUnits: 200, Currency: "RUB"}},
+		{Account: FeesAccount, Amount: Money{MinorUnits: -200, Currency: "RUB"}},
+	}, entry.Postings)
+
+	reversal := PayoutReversalEntry(payment, Money{MinorUnits: 9700, Currency: "RUB"}, Money{MinorUnits: 300, Currency: "RUB"})
+	assert.NoError(t, reversal.Validate())
+	assert.Equal(t, FeesAccount, reversal.Postings[2].Account)
+	assert.Equal(t, int64(300), reversal.Postings[2].Amount.MinorUnits)
+}
+
+func TestRefundEntry_SplitsBetweenRecipientAndPlatform(t *testing.T) {
+	payment := &Payment{ID: "payment-1", ToUserID: "user-2", Provider: "yoomoney", Amount: Money{MinorUnits: 10000, Currency: "RUB"}}
+	refund := &Refund{ID: "refund-1", Amount: Money{MinorUnits: 5000, Currency: "RUB"}}
+
+	entry := RefundEntry(payment, refund, Money{MinorUnits: 3000, Currency: "RUB"})
+	assert.NoError(t, entry.Validate())
+	assert.Equal(t, []LedgerPosting{
+		{Account: "user:user-2", Amount: Money{MinorUnits: 3000, Currency: "RUB"}},
+		{Account: HoldingAccount, Amount: Money{MinorUnits: 2000, Currency: "RUB"}},
+		{Account: "provider:yoomoney:clearing", Amount: Money{MinorUnits: -5000, Currency: "RUB"}},
+	}, entry.Postings)
+
+	entry = RefundEntry(payment, refund, Money{MinorUnits: 10000, Currency: "RUB"})
+	assert.NoError(t, entry.Validate())
+	assert.Len(t, entry.Postings, 2)
+	assert.Equal(t, "user:user-2", entry.Postings[0].Account)
+}
+
+func TestCreditNormal(t *testing.T) {
+	assert.True(t, CreditNormal(UserAccount("user-1")))
+	assert.True(t, CreditNormal(FeesAccount))
+	assert.False(t, CreditNormal(HoldingAccount))
+	assert.False(t, CreditNormal(ProviderClearingAccount("yoomoney")))
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownCurrency валюта не поддерживается сервисом
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ErrCurrencyMismatch операция над суммами в разных валютах
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// UnknownCurrencyError ошибка с кодом неизвестной валюты
+type UnknownCurrencyError struct {
+	Currency string
+}
+
+func (e *UnknownCurrencyError) Error() string {
+	return fmt.Sprintf("unknown currency: %s", e.Currency)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrUnknownCurrency)
+func (e *UnknownCurrencyError) Is(target error) bool {
+	return target == ErrUnknownCurrency
+}
+
+// Currency валюта ISO 4217 и число знаков минорной единицы, до которых округляются суммы
+type Currency struct {
+	Code     string
+	Exponent int
+}
+
+// currencies поддерживаемые валюты, по умолчанию копейки/центы (2 знака)
+var currencies = func() map[string]Currency {
+	result := make(map[string]Currency)
+	for _, code := range []string{
+		"RUB", "USD", "EUR", "GBP", "CNY", "CHF", "CAD", "AUD", "NZD", "HKD", "SGD",
+		"SEK", "NOK", "DKK", "PLN", "CZK", "HUF", "RON", "BGN", "TRY", "ILS", "AED",
+		"SAR", "INR", "THB", "MYR", "PHP", "IDR", "MXN", "BRL", "ZAR", "KZT", "BYN",
+		"UAH", "UZS", "AMD", "AZN", "GEL", "KGS", "TJS", "MDL", "EGP", "RSD",
+	} {
+		result[code] = Currency{Code: code, Exponent: 2}
+	}
+	for _, code := range []string{"JPY", "KRW", "VND", "CLP", "ISK", "PYG", "UGX"} { // без минорных единиц
+		result[code] = Currency{Code: code, Exponent: 0}
+	}
+	for _, code := range []string{"KWD", "BHD", "OMR", "JOD", "TND", "IQD", "LYD"} { // тысячные доли
+		result[code] = Currency{Code: code, Exponent: 3}
+	}
+	return result
+}()
+
+// LookupCurrency правила валюты по коду
+func LookupCurrency(code string) (Currency, error) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, &UnknownCurrencyError{Currency: code}
+	}
+	return currency, nil
+}
+
+// Money точная сумма в минорных единицах валюты (копейках, центах)
+type Money struct {
+	MinorUnits int64  `json:"minor_units"`
+	Currency   string `json:"currency"`
+}
+
+// NewMoney сумма из минорных единиц
+func NewMoney(minorUnits int64, currency string) (Money, error) {
+	c, err := LookupCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{MinorUnits: minorUnits, Currency: c.Code}, nil
+}
+
+// ParseMoney сумма из десятичной строки ("1234567.89"), лишние знаки округляются по правилам валюты
+func ParseMoney(value, currency string) (Money, error) {
+	c, err := LookupCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	amount, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount: %q", value)
+	}
+
+	minorUnits, err := roundToMinorUnits(amount.Mul(amount, pow10(c.Exponent)))
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{MinorUnits: minorUnits, Currency: c.Code}, nil
+}
+
+// MoneyFromFloat сумма из числа с плавающей точкой, берется его кратчайшее десятичное представление
+func MoneyFromFloat(value float64, currency string) (Money, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Money{}, fmt.Errorf("invalid amount: %v", value)
+	}
+	return ParseMoney(strconv.FormatFloat(value, 'f', -1, 64), currency)
+}
+
+// String десятичная запись суммы без валюты, например "1234567.89"
+func (m Money) String() string {
+	c, err := LookupCurrency(m.Currency)
+	if err != nil || c.Exponent == 0 {
+		return strconv.FormatInt(m.MinorUnits, 10)
+	}
+
+	sign := ""
+	units := m.MinorUnits
+	if units < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absInt64(units), 10)
+	if len(digits) <= c.Exponent {
+		digits = strings.Repeat("0", c.Exponent-len(digits)+1) + digits
+	}
+	point := len(digits) - c.Exponent
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Float64 приблизительное значение, только для логов и внешних API с числами
+func (m Money) Float64() float64 {
+	value, _ := strconv.ParseFloat(m.String(), 64)
+	return value
+}
+
+// IsPositive сумма больше нуля
+func (m Money) IsPositive() bool {
+	return m.MinorUnits > 0
+}
+
+// IsZero сумма равна нулю
+func (m Money) IsZero() bool {
+	return m.MinorUnits == 0
+}
+
+// Add сложение сумм в одной валюте
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return Money{MinorUnits: m.MinorUnits + other.MinorUnits, Currency: m.Currency}, nil
+}
+
+// Sub вычитание сумм в одной валюте
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return Money{MinorUnits: m.MinorUnits - other.MinorUnits, Currency: m.Currency}, nil
+}
+
+// Convert перевод в другую валюту по курсу с округлением по правилам целевой валюты
+func (m Money) Convert(rate float64, to string) (Money, error) {
+	from, err := LookupCurrency(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	target, err := LookupCurrency(to)
+	if err != nil {
+		return Money{}, err
+	}
+	if math.IsNaN(rate) || math.IsInf(rate, 0) || rate <= 0 {
+		return Money{}, fmt.Errorf("invalid exchange rate: %v", rate)
+	}
+
+	value := new(big.Rat).SetInt64(m.MinorUnits)
+	value.Mul(value, new(big.Rat).SetFloat64(rate))
+	value.Mul(value, pow10(target.Exponent))
+	value.Quo(value, pow10(from.Exponent))
+
+	minorUnits, err := roundToMinorUnits(value)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{MinorUnits: minorUnits, Currency: target.Code}, nil
+}
+
+// roundToMinorUnits округление половины от нуля (0.005 -> 0.01)
+func roundToMinorUnits(value *big.Rat) (int64, error) {
+	remainder := new(big.Int)
+	quotient, _ := new(big.Int).QuoRem(value.Num(), value.Denom(), remainder)
+
+	remainder.Abs(remainder).Lsh(remainder, 1)
+	if remainder.Cmp(value.Denom()) >= 0 {
+		if value.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("amount out of range")
+	}
+	return quotient.Int64(), nil
+}
+
+func pow10(exponent int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil))
+}
+
+func absInt64(value int64) uint64 {
+	if value < 0 {
+		return uint64(-value)
+	}
+	return uint64(value)
+}
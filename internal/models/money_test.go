@@ -0,0 +1,98 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney_CurrencyExponents(t *testing.T) {
+	money, err := ParseMoney("1234567.89", "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, Money{MinorUnits: 123456789, Currency: "RUB"}, money)
+	assert.Equal(t, "1234567.89", money.String())
+
+	money, err = ParseMoney("1500", "jpy")
+	assert.NoError(t, err)
+	assert.Equal(t, Money{MinorUnits: 1500, Currency: "JPY"}, money)
+	assert.Equal(t, "1500", money.String())
+
+	money, err = ParseMoney("1.2345", "KWD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1235), money.MinorUnits)
+	assert.Equal(t, "1.235", money.String())
+}
+
+func TestParseMoney_RoundsHalfAwayFromZero(t *testing.T) {
+	cases := map[string]int64{
+		"0.005":  1,
+		"0.004":  0,
+		"-0.005": -1,
+		"10.125": 1013,
+		"0.01":   1,
+	}
+	for value, expected := range cases {
+		money, err := ParseMoney(value, "USD")
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, money.MinorUnits, value)
+	}
+
+	money, err := ParseMoney("99.5", "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), money.MinorUnits)
+}
+
+func TestParseMoney_Errors(t *testing.T) {
+	_, err := ParseMoney("abc", "RUB")
+	assert.Error(t, err)
+
+	_, err = ParseMoney("10", "XXX")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestMoneyFromFloat_KeepsKopecks(t *testing.T) {
+	money, err := MoneyFromFloat(1234567.89, "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123456789), money.MinorUnits)
+
+	money, err = MoneyFromFloat(0.1+0.2, "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), money.MinorUnits)
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "0.05", Money{MinorUnits: 5, Currency: "RUB"}.String())
+	assert.Equal(t, "-0.05", Money{MinorUnits: -5, Currency: "RUB"}.String())
+	assert.Equal(t, "0.000", Money{MinorUnits: 0, Currency: "BHD"}.String())
+}
+
+func TestMoney_AddSub(t *testing.T) {
+	a := Money{MinorUnits: 1050, Currency: "RUB"}
+	b := Money{MinorUnits: 25, Currency: "RUB"}
+
+	sum, err := a.Add(b)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1075), sum.MinorUnits)
+
+	diff, err := a.Sub(b)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1025), diff.MinorUnits)
+
+	_, err = a.Add(Money{MinorUnits: 1, Currency: "USD"})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
+
+func TestMoney_Convert(t *testing.T) {
+	usd := Money{MinorUnits: 1999, Currency: "USD"} // 19.99 USD
+
+	rub, err := usd.Convert(92.3456, "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, Money{MinorUnits: 184599, Currency: "RUB"}, rub) // 1845.988544 -> 1845.99
+
+	jpy, err := usd.Convert(150.5, "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, Money{MinorUnits: 3008, Currency: "JPY"}, jpy) // 3008.495 -> 3008, у иены нет минорных единиц
+
+	_, err = usd.Convert(0, "RUB")
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Типы доменных событий, которые публикуются в брокер
+const (
+	EventPaymentCreated      = "payment.created"
+	EventPaymentPending      = "payment.pending" // повторная ссылка на оплату после неудачи
+	EventPaymentPaid         = "payment.paid"
+	EventPaymentFailed       = "payment.failed"
+	EventPaymentCompleted    = "payment.completed" // выплата получателю начата
+	EventPaymentPayoutFailed = "payment.payout_failed"
+	EventPaymentRefunded     = "payment.refunded"
+	EventPaymentExpired      = "payment.expired"
+	EventRefundPrefix        = "refund." // refund.succeeded, refund.failed
+)
+
+// DomainEventTypes все типы доменных событий, на них можно подписать вебхук
+var DomainEventTypes = []string{
+	EventPaymentCreated, EventPaymentPending, EventPaymentPaid, EventPaymentFailed, EventPaymentCompleted,
+	EventPaymentPayoutFailed, EventPaymentRefunded, EventPaymentExpired,
+	RefundEventType(RefundStatusSucceeded), RefundEventType(RefundStatusFailed),
+}
+
+// OutboxEvent доменное событие, записанное в той же транзакции, что и изменение платежа.
+// ID не меняется при повторной публикации, по нему потребители отбрасывают дубли.
+type OutboxEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	PaymentID string          `json:"payment_id"`
+	Payload   json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"occurred_at"`
+}
+
+// PaymentEventData данные события о смене статуса платежа
+type PaymentEventData struct {
+	Payment   *Payment      `json:"payment"` // платеж после смены статуса
+	OldStatus PaymentStatus `json:"old_status,omitempty"`
+	Actor     string        `json:"actor"`
+	Reason    string        `json:"reason"`
+}
+
+// RefundEventData данные события о завершении возврата
+type RefundEventData struct {
+	Refund *Refund `json:"refund"`
+}
+
+// PaymentEventType тип доменного события для смены статуса from -> to
+func PaymentEventType(from, to PaymentStatus) string {
+	switch {
+	case from == "":
+		return EventPaymentCreated
+	case from == StatusComplete && to == StatusSuccess:
+		return EventPaymentPayoutFailed
+	}
+	switch to {
+	case StatusPending:
+		return EventPaymentPending
+	case StatusSuccess:
+		return EventPaymentPaid
+	case StatusFailed:
+		return EventPaymentFailed
+	case StatusComplete:
+		return EventPaymentCompleted
+	case StatusRefunded:
+		return EventPaymentRefunded
+	case StatusExpired:
+		return EventPaymentExpired
+	}
+	return "payment." + strings.ToLower(string(to))
+}
+
+// RefundEventType тип доменного события для завершенного возврата
+func RefundEventType(status RefundStatus) string {
+	return EventRefundPrefix + strings.ToLower(string(status))
+}
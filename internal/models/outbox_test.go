@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentEventType(t *testing.T) {
+	assert.Equal(t, EventPaymentCreated, PaymentEventType("", StatusPending))
+	assert.Equal(t, EventPaymentPending, PaymentEventType(StatusFailed, StatusPending))
+	assert.Equal(t, EventPaymentPaid, PaymentEventType(StatusPending, StatusSuccess))
+	assert.Equal(t, EventPaymentCompleted, PaymentEventType(StatusSuccess, StatusComplete))
+	assert.Equal(t, EventPaymentPayoutFailed, PaymentEventType(StatusComplete, StatusSuccess))
+	assert.Equal(t, EventPaymentRefunded, PaymentEventType(StatusComplete, StatusRefunded))
+	assert.Equal(t, EventPaymentExpired, PaymentEventType(StatusPending, StatusExpired))
+	assert.Equal(t, "refund.succeeded", RefundEventType(RefundStatusSucceeded))
+}
@@ -11,6 +11,7 @@ const (
 	StatusFailed   PaymentStatus = "FAILED"
 	StatusRefunded PaymentStatus = "REFUNDED"
 	StatusComplete PaymentStatus = "COMPLETE"
+	StatusExpired  PaymentStatus = "EXPIRED" // не оплачен за отведенное время
 	CoreAccount                  = "4100118177295897"
 )
 
@@ -19,9 +20,25 @@ type Payment struct {
 	ID         string        `json:"id" db:"id"`
 	FromUserID string        `json:"from_user_id" db:"from_user_id"`
 	ToUserID   string        `json:"to_user_id" db:"to_user_id"`
-	Amount     float64       `json:"amount" db:"amount"`
-	Currency   string        `json:"currency" db:"currency"`
+	Amount     Money         `json:"amount" db:"amount_minor"`
+	Fee        Money         `json:"fee" db:"fee_minor"` // комиссия платформы в валюте платежа, удерживается из выплаты
 	Status     PaymentStatus `json:"status" db:"status"`
+	Provider   string        `json:"provider" db:"provider"`
 	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time     `json:"updated_at" db:"updated_at"`
 }
+
+// PayoutSplit сколько перевести получателю и сколько оставить комиссией после возвратов refunded.
+// Комиссия удерживается из оставшейся суммы и не больше нее
+func (p *Payment) PayoutSplit(refunded Money) (payout, fee Money, err error) {
+	remaining, err := p.Amount.Sub(refunded)
+	if err != nil {
+		return Money{}, Money{}, err
+	}
+	fee = Money{MinorUnits: min(max(p.Fee.MinorUnits, 0), max(remaining.MinorUnits, 0)), Currency: remaining.Currency}
+	payout, err = remaining.Sub(fee)
+	if err != nil {
+		return Money{}, Money{}, err
+	}
+	return payout, fee, nil
+}
@@ -13,8 +13,7 @@ func TestPaymentInitialization(t *testing.T) {
 		ID:         "payment-id",
 		FromUserID: "user1",
 		ToUserID:   "user2",
-		Amount:     100.50,
-		Currency:   "RUB",
+		Amount:     Money{MinorUnits: 10050, Currency: "RUB"},
 		Status:     StatusPending,
 		CreatedAt:  now,
 		UpdatedAt:  now,
@@ -23,8 +22,8 @@ func TestPaymentInitialization(t *testing.T) {
 	assert.Equal(t, "payment-id", payment.ID)
 	assert.Equal(t, "user1", payment.FromUserID)
 	assert.Equal(t, "user2", payment.ToUserID)
-	assert.Equal(t, 100.50, payment.Amount)
-	assert.Equal(t, "RUB", payment.Currency)
+	assert.Equal(t, "100.50", payment.Amount.String())
+	assert.Equal(t, "RUB", payment.Amount.Currency)
 	assert.Equal(t, StatusPending, payment.Status)
 	assert.Equal(t, now, payment.CreatedAt)
 	assert.Equal(t, now, payment.UpdatedAt)
@@ -47,8 +46,7 @@ func TestPaymentStatusTransitions(t *testing.T) {
 		ID:         "payment-id",
 		FromUserID: "user1",
 		ToUserID:   "user2",
-		Amount:     150.0,
-		Currency:   "USD",
+		Amount:     Money{MinorUnits: 15000, Currency: "USD"},
 		Status:     StatusPending,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
@@ -72,19 +70,36 @@ func TestPaymentEdgeCases(t *testing.T) {
 		ID:         "zero-amount-id",
 		FromUserID: "user1",
 		ToUserID:   "user2",
-		Amount:     0.0,
-		Currency:   "USD",
+		Amount:     Money{MinorUnits: 0, Currency: "USD"},
 		Status:     StatusPending,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
-	assert.Equal(t, 0.0, payment.Amount)
+	assert.True(t, payment.Amount.IsZero())
+	assert.False(t, payment.Amount.IsPositive())
 
-	payment.Amount = -50.0
-	assert.Equal(t, -50.0, payment.Amount)
+	payment.Amount.MinorUnits = -5000
+	assert.Equal(t, "-50.00", payment.Amount.String())
 
 	payment.FromUserID = ""
 	payment.ToUserID = ""
 	assert.Equal(t, "", payment.FromUserID)
 	assert.Equal(t, "", payment.ToUserID)
 }
+
+func TestPayment_PayoutSplit(t *testing.T) {
+	payment := &Payment{Amount: Money{MinorUnits: 10000, Currency: "RUB"}, Fee: Money{MinorUnits: 300, Currency: "RUB"}}
+
+	payout, fee, err := payment.PayoutSplit(Money{Currency: "RUB"})
+	assert.NoError(t, err)
+	assert.Equal(t, Money{MinorUnits: 9700, Currency: "RUB"}, payout)
+	assert.Equal(t, Money{MinorUnits: 300, Currency: "RUB"}, fee)
+
+	payout, fee, err = payment.PayoutSplit(Money{MinorUnits: 9800, Currency: "RUB"})
+	assert.NoError(t, err)
+	assert.True(t, payout.IsZero())
+	assert.Equal(t, Money{MinorUnits: 200, Currency: "RUB"}, fee)
+
+	_, _, err = payment.PayoutSplit(Money{MinorUnits: 100, Currency: "USD"})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
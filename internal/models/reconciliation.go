@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// MismatchKind вид расхождения между платежами и операциями шлюза
+type MismatchKind string
+
+const (
+	MismatchPaidNotSettled        MismatchKind = "paid_not_settled"        // оплата прошла, а платеж все еще ждет оплаты
+	MismatchCompleteWithoutPayout MismatchKind = "complete_without_payout" // платеж закрыт, а выплаты получателю нет
+	MismatchAmount                MismatchKind = "amount_mismatch"         // сумма операции не совпадает с суммой платежа
+	MismatchUnknownLabel          MismatchKind = "unknown_label"           // метка операции не указывает ни на платеж, ни на возврат
+)
+
+// ReconciliationMismatch одно расхождение сверки
+type ReconciliationMismatch struct {
+	Kind          MismatchKind  `json:"kind"`
+	PaymentID     string        `json:"payment_id,omitempty"`
+	OperationID   string        `json:"operation_id,omitempty"`
+	Label         string        `json:"label,omitempty"`
+	PaymentStatus PaymentStatus `json:"payment_status,omitempty"`
+	Expected      *Money        `json:"expected,omitempty"` // сумма по платежу
+	Actual        *Money        `json:"actual,omitempty"`   // сумма операции в шлюзе
+	Details       string        `json:"details,omitempty"`
+	Fixed         bool          `json:"fixed"` // исправлено автоматически
+}
+
+// ReconciliationReport результат сверки платежей с историей операций шлюза за окно [From, To)
+type ReconciliationReport struct {
+	ID         string                   `json:"id"`
+	Provider   string                   `json:"provider"`
+	From       time.Time                `json:"from"`
+	To         time.Time                `json:"to"`
+	Operations int                      `json:"operations"` // сколько операций шлюза проверено
+	AutoFix    bool                     `json:"auto_fix"`
+	Mismatches []ReconciliationMismatch `json:"mismatches"`
+	CreatedAt  time.Time                `json:"created_at"`
+}
+
+// Fixed сколько расхождений исправлено автоматически
+func (r *ReconciliationReport) Fixed() int {
+	fixed := 0
+	for _, mismatch := range r.Mismatches {
+		if mismatch.Fixed {
+			fixed++
+		}
+	}
+	return fixed
+}
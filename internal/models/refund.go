@@ -0,0 +1,30 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRefundExceedsPayment сумма возвратов превышает сумму оплаты
+var ErrRefundExceedsPayment = errors.New("refund amount exceeds refundable amount")
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "PENDING"
+	RefundStatusSucceeded RefundStatus = "SUCCEEDED"
+	RefundStatusFailed    RefundStatus = "FAILED"
+)
+
+// Refund возврат части или всей суммы платежа отправителю
+type Refund struct {
+	ID          string       `json:"id" db:"id"`
+	PaymentID   string       `json:"payment_id" db:"payment_id"`
+	Amount      Money        `json:"amount" db:"amount_minor"`
+	Reason      string       `json:"reason" db:"reason"`
+	Status      RefundStatus `json:"status" db:"status"`
+	InitiatorID string       `json:"initiator_id" db:"initiator_id"`             // кто запросил возврат
+	ProviderRef string       `json:"provider_reference" db:"provider_reference"` // идентификатор операции в шлюзе
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
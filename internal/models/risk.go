@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// RiskDecisionKind решение правил риска по платежу
+type RiskDecisionKind string
+
+const (
+	RiskAllow  RiskDecisionKind = "allow"
+	RiskDeny   RiskDecisionKind = "deny"
+	RiskReview RiskDecisionKind = "review" // платеж не создается, пока его не проверит человек
+)
+
+// Правила проверки платежа, сработавшее правило записывается в решение
+const (
+	RiskRuleBlocklist    = "blocklist"
+	RiskRuleSelfPayment  = "self_payment"
+	RiskRuleMaxAmount    = "max_amount"
+	RiskRuleHourlyCount  = "velocity_hourly_count"
+	RiskRuleDailyCount   = "velocity_daily_count"
+	RiskRuleHourlyAmount = "velocity_hourly_amount"
+	RiskRuleDailyAmount  = "velocity_daily_amount"
+	RiskRuleNewRecipient = "new_recipient"
+)
+
+// RiskDecision решение по платежу перед его созданием
+type RiskDecision struct {
+	ID         string           `json:"id"`
+	FromUserID string           `json:"from_user_id"`
+	ToUserID   string           `json:"to_user_id"`
+	Amount     Money            `json:"amount"`
+	Decision   RiskDecisionKind `json:"decision"`
+	Rule       string           `json:"rule"` // пусто для allow
+	Reason     string           `json:"reason"`
+	PaymentID  string           `json:"payment_id"` // созданный платеж, только для allow
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// RiskError платеж отклонен или отправлен на проверку правилами риска
+type RiskError struct {
+	Decision *RiskDecision
+}
+
+func (e *RiskError) Error() string {
+	if e.Decision.Decision == RiskReview {
+		return fmt.Sprintf("payment held for review by risk rule %s: %s", e.Decision.Rule, e.Decision.Reason)
+	}
+	return fmt.Sprintf("payment denied by risk rule %s: %s", e.Decision.Rule, e.Decision.Reason)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition переход между статусами запрещен
+var ErrInvalidTransition = errors.New("invalid payment status transition")
+
+// TransitionError запрещенный переход с исходным и целевым статусом
+type TransitionError struct {
+	From PaymentStatus
+	To   PaymentStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid payment status transition: %s -> %s", e.From, e.To)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrInvalidTransition)
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// transitions разрешенные переходы между статусами платежа, все остальные запрещены
+var transitions = map[PaymentStatus][]PaymentStatus{
+	StatusPending:  {StatusSuccess, StatusFailed, StatusExpired},
+	StatusFailed:   {StatusPending, StatusSuccess, StatusExpired}, // повторная ссылка на оплату или поздняя оплата
+	StatusSuccess:  {StatusComplete, StatusRefunded},
+	StatusComplete: {StatusSuccess, StatusRefunded}, // в SUCCESS откатываемся, если выплата получателю не прошла
+	StatusRefunded: {},
+	StatusExpired:  {StatusSuccess}, // оплата по ссылке, выданной до истечения срока, все равно принимается
+}
+
+// CanTransition разрешен ли переход from -> to
+func CanTransition(from, to PaymentStatus) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition ошибка TransitionError, если переход запрещен
+func ValidateTransition(from, to PaymentStatus) error {
+	if !CanTransition(from, to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// IsPaid деньги от плательщика получены
+func (s PaymentStatus) IsPaid() bool {
+	return s == StatusSuccess || s == StatusComplete
+}
+
+// IsRefundable возврат возможен только до выплаты получателю: после нее деньги уже ушли со счета платформы
+func (s PaymentStatus) IsRefundable() bool {
+	return s == StatusSuccess
+}
+
+// IsTerminal на обычном пути платежа дальше ничего не происходит: выплачен, истек или возвращен.
+// Из COMPLETE и EXPIRED возможны только редкие переходы: откат выплаты и поздняя оплата
+func (s PaymentStatus) IsTerminal() bool {
+	return s == StatusComplete || s == StatusExpired || s == StatusRefunded
+}
+
+// IsFinal из статуса нет переходов
+func (s PaymentStatus) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// IsUnpaid платеж еще ждет оплаты
+func (s PaymentStatus) IsUnpaid() bool {
+	return s == StatusPending || s == StatusFailed
+}
+
+// IsKnown статус из списка статусов платежа
+func (s PaymentStatus) IsKnown() bool {
+	_, ok := transitions[s]
+	return ok
+}
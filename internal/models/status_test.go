@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(StatusPending, StatusSuccess))
+	assert.True(t, CanTransition(StatusPending, StatusFailed))
+	assert.True(t, CanTransition(StatusFailed, StatusPending))
+	assert.True(t, CanTransition(StatusSuccess, StatusComplete))
+	assert.True(t, CanTransition(StatusComplete, StatusSuccess))
+	assert.True(t, CanTransition(StatusSuccess, StatusRefunded))
+	assert.True(t, CanTransition(StatusComplete, StatusRefunded))
+
+	assert.False(t, CanTransition(StatusComplete, StatusPending))
+	assert.False(t, CanTransition(StatusSuccess, StatusPending))
+	assert.False(t, CanTransition(StatusPending, StatusRefunded))
+	assert.False(t, CanTransition(StatusFailed, StatusRefunded))
+	assert.False(t, CanTransition(StatusRefunded, StatusSuccess))
+	assert.False(t, CanTransition(StatusPending, StatusPending))
+
+	assert.True(t, CanTransition(StatusPending, StatusExpired))
+	assert.True(t, CanTransition(StatusFailed, StatusExpired))
+	assert.True(t, CanTransition(StatusExpired, StatusSuccess))
+	assert.False(t, CanTransition(StatusExpired, StatusPending))
+	assert.False(t, CanTransition(StatusSuccess, StatusExpired))
+}
+
+func TestValidateTransition(t *testing.T) {
+	assert.NoError(t, ValidateTransition(StatusPending, StatusSuccess))
+
+	err := ValidateTransition(StatusComplete, StatusPending)
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	assert.Contains(t, err.Error(), "COMPLETE -> PENDING")
+}
+
+func TestPaymentStatusFlags(t *testing.T) {
+	assert.True(t, StatusSuccess.IsPaid())
+	assert.True(t, StatusComplete.IsPaid())
+	assert.False(t, StatusPending.IsPaid())
+	assert.False(t, StatusExpired.IsPaid())
+
+	assert.True(t, StatusSuccess.IsRefundable())
+	assert.False(t, StatusComplete.IsRefundable(), "after the payout the money has left the platform account")
+	assert.False(t, StatusPending.IsRefundable())
+
+	assert.True(t, StatusPending.IsUnpaid())
+	assert.True(t, StatusFailed.IsUnpaid())
+	assert.False(t, StatusExpired.IsUnpaid())
+
+	assert.True(t, StatusRefunded.IsFinal())
+	assert.False(t, StatusComplete.IsFinal())
+
+	assert.True(t, StatusComplete.IsTerminal())
+	assert.True(t, StatusExpired.IsTerminal())
+	assert.True(t, StatusRefunded.IsTerminal())
+	assert.False(t, StatusSuccess.IsTerminal())
+	assert.False(t, StatusFailed.IsTerminal())
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidWebhookEndpoint недопустимый адрес или типы событий вебхука
+var ErrInvalidWebhookEndpoint = errors.New("invalid webhook endpoint")
+
+// WebhookEndpoint адрес интегратора, на который отправляются события его платежей
+type WebhookEndpoint struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"` // владелец: события приходят по платежам, где он отправитель или получатель
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`           // ключ подписи HMAC-SHA256, показывается только при создании
+	EventTypes []string  `json:"event_types"` // пустой список - все события
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// nonPublicNetworks служебные диапазоны, которые не покрывают проверки net.IP
+var nonPublicNetworks = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),     // "этот" хост
+	mustCIDR("100.64.0.0/10"), // CGNAT
+	mustCIDR("192.0.0.0/24"),  // протокольные назначения IETF
+	mustCIDR("198.18.0.0/15"), // тестирование сетей
+	mustCIDR("64:ff9b::/96"),  // NAT64 ведет на IPv4 внутри сети
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// PublicIP адрес из публичной сети. Вебхуки не отправляются на loopback, link-local
+// (в том числе метаданные облака 169.254.169.254), частные и служебные адреса
+func PublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate проверка адреса и типов событий. Адрес должен быть https (http только при allowHTTP)
+// и не указывать на внутреннюю сеть; имя хоста еще раз проверяется при подключении
+func (e *WebhookEndpoint) Validate(allowHTTP bool) error {
+	parsed, err := url.Parse(e.URL)
+	if err != nil || parsed.Hostname() == "" || parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhookEndpoint)
+	}
+	if parsed.Scheme == "http" && !allowHTTP {
+		return fmt.Errorf("%w: url must use https", ErrInvalidWebhookEndpoint)
+	}
+	if parsed.User != nil {
+		return fmt.Errorf("%w: url must not contain credentials", ErrInvalidWebhookEndpoint)
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: url must point to a public host", ErrInvalidWebhookEndpoint)
+	}
+	if ip := net.ParseIP(host); ip != nil && !PublicIP(ip) {
+		return fmt.Errorf("%w: url must point to a public host", ErrInvalidWebhookEndpoint)
+	}
+	for _, eventType := range e.EventTypes {
+		if !slices.Contains(DomainEventTypes, eventType) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhookEndpoint, eventType)
+		}
+	}
+	return nil
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "PENDING" // ждет первой или повторной попытки
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "FAILED" // попытки исчерпаны, повторяется только вручную
+)
+
+// WebhookDelivery доставка одного события на один адрес, заодно журнал попыток
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	EndpointID     string                `json:"endpoint_id"`
+	Event          OutboxEvent           `json:"event"` // id события одинаков во всех попытках
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastStatusCode int                   `json:"last_status_code"` // 0, если ответа не было
+	LastError      string                `json:"last_error"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+
+	URL    string `json:"-"` // адрес и ключ на момент отправки
+	Secret string `json:"-"`
+}
+
+// Body тело запроса вебхука: тот же конверт, что уходит в брокер
+func (d *WebhookDelivery) Body() ([]byte, error) {
+	return json.Marshal(d.Event)
+}
@@ -2,13 +2,21 @@ package payments_demon
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/db"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
-	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,81 +24,260 @@ import (
 type PaymentDemon struct {
 	service       service.PaymentService
 	repo          repository.PaymentRepository
-	paymentClient *clients.YooMoneyClient
-	paymentsQueue *db.LockFreeQueue
+	deadLetters   repository.DeadLetterRepository
+	providers     *clients.ProviderRegistry
+	paymentsQueue db.Queue
 	authClient    *clients.AuthClient
 	logger        *zap.Logger
+	pollFallback  time.Duration
+	pollInterval  time.Duration
+	workers       int
+	retryInitial  time.Duration
+	retryMax      time.Duration
+	maxAttempts   int
 }
 
 // NewPaymentDemon Создание экземпляра демона
-func NewPaymentDemon(service service.PaymentService, repo repository.PaymentRepository, paymentClient *clients.YooMoneyClient, paymentQueue *db.LockFreeQueue, logger *zap.Logger, authClient *clients.AuthClient) *PaymentDemon {
+func NewPaymentDemon(service service.PaymentService, repo repository.PaymentRepository, deadLetters repository.DeadLetterRepository, providers *clients.ProviderRegistry, paymentQueue db.Queue, logger *zap.Logger, authClient *clients.AuthClient, cfg config.Demon) *PaymentDemon {
 	return &PaymentDemon{
 		service:       service,
-		paymentClient: paymentClient,
+		repo:          repo,
+		deadLetters:   deadLetters,
+		providers:     providers,
 		paymentsQueue: paymentQueue,
 		logger:        logger,
 		authClient:    authClient,
+		pollFallback:  cfg.PollFallback,
+		pollInterval:  cfg.PollInterval,
+		workers:       max(cfg.Workers, 1),
+		retryInitial:  cfg.RetryInitial,
+		retryMax:      cfg.RetryMax,
+		maxAttempts:   max(cfg.MaxAttempts, 1),
 	}
 }
 
-// Start Бесконечный цикл проверки счетов
+// Start Проверка счетов в workers параллельных обработчиках до отмены ctx.
+// Платеж, взятый в работу, обрабатывается до конца, даже если ctx отменили посреди перевода.
 func (d PaymentDemon) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	wg.Wait()
+	d.logger.Info("Payment demon stopped", zap.Int("workers", d.workers))
+}
+
+// work цикл одного обработчика: медленный ответ шлюза по одному платежу не задерживает остальные
+func (d PaymentDemon) work(ctx context.Context) {
+	work := context.WithoutCancel(ctx)
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Payment demon stopped")
 			return
 		default:
-			payment, ok := d.paymentsQueue.Dequeue()
+			delivery, ok := d.paymentsQueue.Dequeue()
 			if !ok {
-				time.Sleep(1 * time.Second)
+				select {
+				case <-ctx.Done():
+				case <-time.After(1 * time.Second):
+				}
 				continue
 			}
 
-			status, err := d.service.GetPayment(ctx, payment.ID) // получаем данные по статусу оплаты
-			if err != nil {
-				d.paymentsQueue.Enqueue(payment) // если ошибка, то добавляем в очередь снова
-				d.logger.Error("Failed to check payment status", zap.String("payment_id", payment.ID), zap.Error(err))
-				continue
-			}
+			d.processPayment(work, delivery) // выдача подтверждается только после обработки, иначе платеж будет выдан повторно
+		}
+	}
+}
 
-			switch status {
-			case "success":
-				receiverData, err := d.authClient.GetUserById(ctx, payment.ToUserID) // если успешно, запрашиваем счет для перевода средств
-				if err != nil {
-					d.paymentsQueue.Enqueue(payment) // если ошибка, то добавляем в очередь снова
-					d.logger.Error("Failed to get receiver", zap.String("user_id", payment.ToUserID), zap.Error(err))
-				}
+// processPayment проверка одного платежа из очереди.
+// Статус обычно приходит уведомлением шлюза, а сам шлюз опрашивается только для платежей,
+// по которым уведомления нет дольше pollFallback, и не чаще раза в pollInterval.
+// Каждый путь обработки заканчивается подтверждением выдачи или возвратом платежа в очередь,
+// причем очередь не выдает отложенный платеж раньше срока.
+func (d PaymentDemon) processPayment(ctx context.Context, delivery db.Delivery) {
+	payment, origin := delivery.Payment, delivery.Origin
+	originCtx := trace.ContextWithRemoteSpanContext(ctx, origin) // пока ничего не делали, платеж возвращается в очередь с исходной трассировкой
 
-				receiver := receiverData.YoomoneyId // получаем идентификатор получателя средств
+	metrics.DemonIterations.Inc()
+	current, err := d.service.GetPaymentByID(ctx, payment.ID) // статус мог уже прийти уведомлением
+	if err != nil {
+		d.logger.Error("Failed to fetch payment", zap.String("payment_id", payment.ID), zap.Error(err))
+		d.retry(originCtx, delivery, "fetch", err)
+		return
+	}
 
-				err = d.repo.UpdatePaymentStatus(ctx, payment.ID, models.StatusComplete)
-				if err != nil {
-					d.paymentsQueue.Enqueue(payment) // если ошибка, то добавляем в очередь снова
-					d.logger.Error("Failed to update payment status", zap.String("payment_id", payment.ID), zap.Error(err))
-				}
+	status := strings.ToLower(string(current.Status))
+	unpaid := current.Status.IsUnpaid()
+	if wait := pollDelay(current.CreatedAt, time.Now(), d.pollFallback); unpaid && wait > 0 { // ждем уведомления
+		delivery.Attempts = 0
+		d.requeue(originCtx, delivery, wait)
+		return
+	}
 
-				paymentStatus, err := d.paymentClient.CreateTransfer(&payment, receiver)
-				if err != nil { // если ошибка перевода, то возвращаем статус платежа на success
-					err = d.repo.UpdatePaymentStatus(ctx, payment.ID, models.StatusSuccess)
-					if err != nil {
-						d.logger.Error("Failed to update payment status", zap.String("payment_id", payment.ID), zap.Error(err))
-					}
-					d.paymentsQueue.Enqueue(payment) // если ошибка, то добавляем в очередь снова
-					d.logger.Error("Failed to create new transfer", zap.String("original_payment_id", payment.ID), zap.Error(err))
-				} else {
-					d.logger.Info("New transfer created", zap.String("status", paymentStatus))
-				}
+	// у фоновой обработки своя трасса, связанная с запросом, который поставил платеж в очередь
+	ctx, span := tracing.Tracer().Start(ctx, "PaymentDemon.processPayment",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{SpanContext: origin}),
+		trace.WithAttributes(attribute.String("payment.id", payment.ID), attribute.String("payment.status", status)),
+	)
+	defer span.End()
 
-			case "pending", "failed":
-				d.paymentsQueue.Enqueue(payment) // если ошибка или статус pending, то добавляем в очередь снова
-				d.logger.Info("Re-enqueued payment for further processing", zap.String("payment_id", payment.ID))
-			case "complete":
-				d.logger.Info("Payment complete", zap.String("payment_id", payment.ID))
-			default:
-				d.paymentsQueue.Enqueue(payment) // если ошибка, то добавляем в очередь снова
-				d.logger.Warn("Unexpected payment status", zap.String("payment_id", payment.ID), zap.String("status", status))
-			}
+	if unpaid {
+		status, err = d.service.GetPayment(ctx, payment.ID) // уведомления нет, опрашиваем шлюз
+		if err != nil {
+			d.logger.Error("Failed to check payment status", zap.String("payment_id", payment.ID), zap.Error(err))
+			d.retry(ctx, delivery, "poll", err)
+			return
 		}
 	}
+
+	switch status {
+	case clients.ProviderStatusSuccess:
+		d.payout(ctx, delivery)
+	case clients.ProviderStatusPending, clients.ProviderStatusFailed:
+		delivery.Attempts = 0                         // шлюз ответил, ждать оплаты - не ошибка
+		if d.requeue(ctx, delivery, d.pollInterval) { // статус pending, то добавляем в очередь снова
+			d.logger.Info("Re-enqueued payment for further processing", zap.String("payment_id", payment.ID), zap.Duration("delay", d.pollInterval))
+		}
+	case "complete", "refunded", "expired": // по истекшему платежу поздняя оплата вернет его в очередь
+		d.paymentsQueue.Ack(delivery)
+		d.logger.Info("Payment closed", zap.String("payment_id", payment.ID), zap.String("status", status))
+	default:
+		d.logger.Warn("Unexpected payment status", zap.String("payment_id", payment.ID), zap.String("status", status))
+		d.retry(ctx, delivery, "status", fmt.Errorf("unexpected payment status: %s", status))
+	}
+}
+
+// requeue возврат платежа в очередь не раньше, чем через delay, с подтверждением текущей выдачи.
+// Если поставить платеж не удалось, выдача не подтверждается и платеж выдается повторно после VisibilityTimeout
+func (d PaymentDemon) requeue(ctx context.Context, delivery db.Delivery, delay time.Duration) bool {
+	if err := d.paymentsQueue.Requeue(ctx, delivery, delay); err != nil {
+		d.logger.Error("Failed to re-enqueue payment", zap.String("payment_id", delivery.Payment.ID), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// retry повтор платежа после ошибки на этапе stage с экспоненциальной паузой.
+// После maxAttempts ошибок подряд платеж уходит в dead letter и больше не крутится в очереди.
+func (d PaymentDemon) retry(ctx context.Context, delivery db.Delivery, stage string, cause error) {
+	payment := delivery.Payment
+	metrics.DemonFailed(stage)
+	attempts := delivery.Attempts + 1 // счетчик хранится в записи очереди и переживает перезапуск демона
+	if attempts >= d.maxAttempts {
+		d.deadLetter(ctx, delivery, stage, attempts, cause)
+		return
+	}
+
+	delivery.Attempts = attempts
+	delay := backoff(attempts, d.retryInitial, d.retryMax)
+	if !d.requeue(ctx, delivery, delay) { // если ошибка, то добавляем в очередь снова
+		return
+	}
+	d.logger.Info("Payment scheduled for retry", zap.String("payment_id", payment.ID), zap.String("stage", stage), zap.Int("attempt", attempts), zap.Duration("delay", delay))
+}
+
+// deadLetter сохранение платежа, исчерпавшего попытки. Если сохранить не удалось, платеж остается в очереди
+func (d PaymentDemon) deadLetter(ctx context.Context, delivery db.Delivery, stage string, attempts int, cause error) {
+	payment := delivery.Payment
+	err := d.deadLetters.AddDeadLetter(ctx, models.DeadLetter{PaymentID: payment.ID, Stage: stage, LastError: cause.Error(), Attempts: attempts})
+	if err != nil {
+		d.requeue(ctx, delivery, d.retryMax)
+		d.logger.Error("Failed to move payment to dead letters", zap.String("payment_id", payment.ID), zap.Error(err))
+		return
+	}
+
+	d.paymentsQueue.Ack(delivery)
+	metrics.DemonDeadLetters.Inc()
+	d.logger.Error("Payment moved to dead letters", zap.String("payment_id", payment.ID), zap.String("stage", stage), zap.Int("attempts", attempts), zap.Error(cause))
+}
+
+// payout закрытие оплаченного счета и перевод средств получателю
+func (d PaymentDemon) payout(ctx context.Context, delivery db.Delivery) {
+	payment := delivery.Payment
+	provider, err := d.providers.Get(payment.Provider) // выплата идет через тот же шлюз, что и оплата
+	if err != nil {
+		d.logger.Error("Failed to resolve payment provider", zap.String("payment_id", payment.ID), zap.String("provider", payment.Provider), zap.Error(err))
+		d.retry(ctx, delivery, "provider", err)
+		return
+	}
+
+	receiverData, err := d.authClient.GetUserById(ctx, payment.ToUserID) // если успешно, запрашиваем счет для перевода средств
+	if err != nil {
+		d.logger.Error("Failed to get receiver", zap.String("user_id", payment.ToUserID), zap.Error(err))
+		d.retry(ctx, delivery, "receiver", err)
+		return
+	}
+
+	receiver := receiverData.YoomoneyId // получаем идентификатор получателя средств
+
+	err = d.service.UpdatePaymentStatus(ctx, payment.ID, models.StatusChange{ // закрывает счет только один обработчик
+		Expected: models.StatusSuccess,
+		Status:   models.StatusComplete,
+		Actor:    models.ActorDemon,
+		Reason:   "payout to receiver started",
+	})
+	if errors.Is(err, repository.ErrStatusConflict) {
+		d.paymentsQueue.Ack(delivery)
+		d.logger.Info("Payment already processed", zap.String("payment_id", payment.ID), zap.Error(err))
+		return
+	}
+	if err != nil {
+		d.logger.Error("Failed to update payment status", zap.String("payment_id", payment.ID), zap.Error(err))
+		d.retry(ctx, delivery, "status", err)
+		return
+	}
+
+	refunded, err := d.service.RefundedAmount(ctx, payment.ID) // частично возвращенную сумму и комиссию получателю не переводим
+	if err != nil {
+		d.revertPayout(ctx, delivery, err)
+		return
+	}
+	payout := payment
+	payout.Amount, _, err = payment.PayoutSplit(refunded)
+	if err != nil {
+		d.revertPayout(ctx, delivery, err)
+		return
+	}
+	if !payout.Amount.IsPositive() {
+		d.paymentsQueue.Ack(delivery)
+		d.logger.Info("Nothing to pay out after refunds and fee", zap.String("payment_id", payment.ID))
+		return
+	}
+
+	paymentStatus, err := provider.Payout(ctx, &payout, receiver)
+	if errors.Is(err, clients.ErrProviderRejected) { // перевод точно не ушел, повторяем
+		err = fmt.Errorf("%w (provider status %q)", err, paymentStatus)
+		d.revertPayout(ctx, delivery, err)
+		return
+	}
+	if err != nil { // перевод мог уйти: повтор заплатит получателю дважды, платеж остается COMPLETE и разбирается по сверке
+		metrics.DemonFailed("payout")
+		d.deadLetter(ctx, delivery, "payout_unknown", delivery.Attempts+1, fmt.Errorf("payout outcome unknown: %w", err))
+		return
+	}
+
+	d.paymentsQueue.Ack(delivery)
+	d.logger.Info("New transfer created", zap.String("status", paymentStatus), zap.Stringer("amount", payout.Amount))
+}
+
+// revertPayout если перевод не выполнен, то возвращаем статус платежа на success и повторяем позже
+func (d PaymentDemon) revertPayout(ctx context.Context, delivery db.Delivery, cause error) {
+	payment := delivery.Payment
+	err := d.service.UpdatePaymentStatus(ctx, payment.ID, models.StatusChange{
+		Expected:         models.StatusComplete,
+		Status:           models.StatusSuccess,
+		Actor:            models.ActorDemon,
+		Reason:           "payout failed",
+		ProviderResponse: cause.Error(),
+	})
+	if err != nil {
+		d.logger.Error("Failed to update payment status", zap.String("payment_id", payment.ID), zap.Error(err))
+	}
+	d.logger.Error("Failed to create new transfer", zap.String("original_payment_id", payment.ID), zap.Error(cause))
+	d.retry(ctx, delivery, "payout", cause)
 }
@@ -0,0 +1,82 @@
+package payments_demon
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/broker"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"go.uber.org/zap"
+)
+
+// outboxCleanupInterval как часто удаляются опубликованные события
+const outboxCleanupInterval = time.Hour
+
+// OutboxRelay публикация событий из outbox в брокер. Доставка at-least-once:
+// событие помечается опубликованным после ответа брокера, при сбое между ними оно уйдет повторно с тем же id.
+type OutboxRelay struct {
+	outbox    repository.OutboxRepository
+	broker    broker.Broker
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+	logger    *zap.Logger
+}
+
+// NewOutboxRelay создание релея outbox
+func NewOutboxRelay(outbox repository.OutboxRepository, broker broker.Broker, cfg config.Outbox, logger *zap.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:    outbox,
+		broker:    broker,
+		interval:  cfg.PollInterval,
+		batchSize: max(cfg.BatchSize, 1),
+		retention: cfg.Retention,
+		logger:    logger,
+	}
+}
+
+// Run публикация до отмены ctx. Пока outbox отдает полные пачки, следующая берется без паузы
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	var cleaned time.Time
+	for {
+		published, err := r.outbox.PublishOutbox(ctx, r.batchSize, r.publish)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Warn("Failed to publish outbox events", zap.Int("published", published), zap.Error(err))
+		}
+
+		if r.retention > 0 && time.Since(cleaned) > outboxCleanupInterval {
+			cleaned = time.Now()
+			if deleted, err := r.outbox.DeletePublished(ctx, cleaned.Add(-r.retention)); err == nil && deleted > 0 {
+				r.logger.Info("Deleted published outbox events", zap.Int64("count", deleted))
+			}
+		}
+
+		if err == nil && published == r.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// publish отправка одного события в JSON-конверте
+func (r *OutboxRelay) publish(ctx context.Context, event *models.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	err = r.broker.Publish(ctx, broker.Message{ID: event.ID, Type: event.Type, Key: event.PaymentID, Body: body})
+	if err != nil {
+		metrics.OutboxFailures.Inc()
+		return err
+	}
+	metrics.OutboxPublished.WithLabelValues(event.Type).Inc()
+	return nil
+}
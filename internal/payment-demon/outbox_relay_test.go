@@ -0,0 +1,91 @@
+package payments_demon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/broker"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeOutbox outbox в памяти с той же семантикой, что и в постгресе: пачка прерывается на первой ошибке
+type fakeOutbox struct {
+	mu      sync.Mutex
+	pending []*models.OutboxEvent
+}
+
+func (o *fakeOutbox) PublishOutbox(ctx context.Context, limit int, publish func(ctx context.Context, event *models.OutboxEvent) error) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	published := 0
+	for _, event := range o.pending[:min(limit, len(o.pending))] {
+		if err := publish(ctx, event); err != nil {
+			o.pending = o.pending[published:]
+			return published, err
+		}
+		published++
+	}
+	o.pending = o.pending[published:]
+	return published, nil
+}
+
+func (o *fakeOutbox) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// flakyBroker отклоняет первые failures сообщений
+type flakyBroker struct {
+	*broker.MemoryBroker
+	mu       sync.Mutex
+	failures int
+}
+
+func (b *flakyBroker) Publish(ctx context.Context, msg broker.Message) error {
+	b.mu.Lock()
+	if b.failures > 0 {
+		b.failures--
+		b.mu.Unlock()
+		return errors.New("broker unavailable")
+	}
+	b.mu.Unlock()
+	return b.MemoryBroker.Publish(ctx, msg)
+}
+
+func TestOutboxRelay_PublishesInOrderAfterFailure(t *testing.T) {
+	outbox := &fakeOutbox{}
+	for _, id := range []string{"event-1", "event-2", "event-3"} {
+		outbox.pending = append(outbox.pending, &models.OutboxEvent{ID: id, Type: models.EventPaymentPaid, PaymentID: "payment-1", Payload: json.RawMessage(`{"actor":"poller"}`)})
+	}
+	b := &flakyBroker{MemoryBroker: broker.NewMemoryBroker(), failures: 1}
+	messages := b.Subscribe(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	relay := NewOutboxRelay(outbox, b, config.Outbox{PollInterval: 10 * time.Millisecond, BatchSize: 2}, zaptest.NewLogger(t))
+	done := make(chan error)
+	go func() { done <- relay.Run(ctx) }()
+
+	for _, id := range []string{"event-1", "event-2", "event-3"} {
+		select {
+		case msg := <-messages:
+			assert.Equal(t, id, msg.ID)
+			assert.Equal(t, "payment-1", msg.Key)
+			var envelope models.OutboxEvent
+			assert.NoError(t, json.Unmarshal(msg.Body, &envelope))
+			assert.Equal(t, id, envelope.ID)
+			assert.JSONEq(t, `{"actor":"poller"}`, string(envelope.Payload))
+		case <-time.After(time.Second):
+			t.Fatalf("event %s was not published", id)
+		}
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,61 @@
+package payments_demon
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
+	"go.uber.org/zap"
+)
+
+// Reconciler периодическая сверка платежей с историей операций всех шлюзов, которые ее поддерживают
+type Reconciler struct {
+	service   *service.PaymentService
+	providers *clients.ProviderRegistry
+	interval  time.Duration
+	window    time.Duration
+	autoFix   bool
+	logger    *zap.Logger
+}
+
+// NewReconciler создание периодической сверки
+func NewReconciler(service *service.PaymentService, providers *clients.ProviderRegistry, cfg config.Reconciliation, logger *zap.Logger) *Reconciler {
+	return &Reconciler{
+		service:   service,
+		providers: providers,
+		interval:  cfg.Interval,
+		window:    cfg.Window,
+		autoFix:   cfg.AutoFix,
+		logger:    logger,
+	}
+}
+
+// Run сверка за последние window каждые interval до отмены ctx. Первая сверка через interval после запуска,
+// чтобы частые перезапуски не нагружали шлюз.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		to := time.Now()
+		for _, name := range r.providers.Names() {
+			report, err := r.service.Reconcile(ctx, name, to.Add(-r.window), to, r.autoFix)
+			switch {
+			case errors.Is(err, service.ErrReconciliationUnsupported):
+			case err != nil && ctx.Err() == nil:
+				r.logger.Error("Reconciliation failed", zap.String("provider", name), zap.Error(err))
+			case err == nil && len(report.Mismatches) > 0:
+				r.logger.Warn("Reconciliation found mismatches", zap.String("provider", name), zap.String("report_id", report.ID), zap.Int("mismatches", len(report.Mismatches)), zap.Int("fixed", report.Fixed()))
+			}
+		}
+	}
+}
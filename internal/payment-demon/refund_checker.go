@@ -0,0 +1,54 @@
+package payments_demon
+
+import (
+	"context"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
+	"go.uber.org/zap"
+)
+
+// RefundChecker периодически завершает возвраты, оставшиеся в PENDING после таймаута или обрыва связи со шлюзом.
+// Их сумма зарезервирована, поэтому без проверки она не вернулась бы ни отправителю, ни в остаток платежа.
+type RefundChecker struct {
+	service    *service.PaymentService
+	interval   time.Duration
+	checkAfter time.Duration
+	failAfter  time.Duration
+	batchSize  int
+	logger     *zap.Logger
+}
+
+// NewRefundChecker создание проверки зависших возвратов
+func NewRefundChecker(service *service.PaymentService, cfg config.Refunds, logger *zap.Logger) *RefundChecker {
+	return &RefundChecker{
+		service:    service,
+		interval:   cfg.CheckInterval,
+		checkAfter: cfg.CheckAfter,
+		failAfter:  cfg.FailAfter,
+		batchSize:  max(cfg.BatchSize, 1),
+		logger:     logger,
+	}
+}
+
+// Run проверка каждые interval до отмены ctx
+func (c *RefundChecker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		resolved, err := c.service.ResolvePendingRefunds(ctx, c.checkAfter, c.failAfter, c.batchSize)
+		if err != nil && ctx.Err() == nil {
+			c.logger.Error("Failed to resolve pending refunds", zap.Int("resolved", resolved), zap.Error(err))
+		} else if resolved > 0 {
+			c.logger.Info("Resolved pending refunds", zap.Int("count", resolved))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package payments_demon
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// pollDelay сколько еще ждать уведомления шлюза по платежу, созданному в createdAt, прежде чем опрашивать шлюз
+func pollDelay(createdAt, now time.Time, fallback time.Duration) time.Duration {
+	return createdAt.Add(fallback).Sub(now)
+}
+
+// backoff экспоненциальная пауза перед попыткой attempt: initial, 2*initial, ... не больше max.
+// Половина паузы случайная, чтобы платежи, упавшие вместе на недоступном шлюзе, не возвращались к нему разом.
+func backoff(attempt int, initial, max time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,28 @@
+package payments_demon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffGrowsAndIsCapped(t *testing.T) {
+	initial, max := time.Second, 10*time.Second
+	for attempt, full := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			delay := backoff(attempt, initial, max)
+			assert.GreaterOrEqual(t, delay, full/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, delay, full, "attempt %d", attempt)
+		}
+	}
+}
+
+func TestPollDelay(t *testing.T) {
+	createdAt := time.Now()
+
+	assert.Equal(t, time.Minute, pollDelay(createdAt, createdAt, time.Minute), "waits for notification first")
+	assert.Equal(t, 20*time.Second, pollDelay(createdAt, createdAt.Add(40*time.Second), time.Minute))
+	assert.LessOrEqual(t, pollDelay(createdAt, createdAt.Add(time.Minute), time.Minute), time.Duration(0))
+	assert.Less(t, pollDelay(createdAt, createdAt.Add(time.Hour), time.Minute), time.Duration(0))
+}
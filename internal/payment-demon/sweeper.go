@@ -0,0 +1,50 @@
+package payments_demon
+
+import (
+	"context"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
+	"go.uber.org/zap"
+)
+
+// ExpirationSweeper периодически переводит неоплаченные платежи старше срока оплаты в EXPIRED.
+// Несколько экземпляров сервиса могут работать одновременно: платеж истекает только у одного из них.
+type ExpirationSweeper struct {
+	service   *service.PaymentService
+	interval  time.Duration
+	batchSize int
+	logger    *zap.Logger
+}
+
+// NewExpirationSweeper создание свипера просроченных платежей
+func NewExpirationSweeper(service *service.PaymentService, cfg config.Expiration, logger *zap.Logger) *ExpirationSweeper {
+	return &ExpirationSweeper{
+		service:   service,
+		interval:  cfg.SweepInterval,
+		batchSize: max(cfg.BatchSize, 1),
+		logger:    logger,
+	}
+}
+
+// Run проверка каждые interval до отмены ctx
+func (s *ExpirationSweeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := s.service.ExpirePayments(ctx, s.batchSize)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to expire payments", zap.Int("expired", expired), zap.Error(err))
+		} else if expired > 0 {
+			s.logger.Info("Expired unpaid payments", zap.Int("count", expired))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
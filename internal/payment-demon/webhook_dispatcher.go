@@ -0,0 +1,181 @@
+package payments_demon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/webhooks"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+)
+
+// errForbiddenDestination адрес вебхука указывает во внутреннюю сеть
+var errForbiddenDestination = errors.New("webhook destination is not a public address")
+
+// WebhookDispatcher отправка вебхуков интеграторам. Доставка at-least-once:
+// получатель отбрасывает повторы по заголовку X-Webhook-Id.
+type WebhookDispatcher struct {
+	webhooks     repository.WebhookRepository
+	client       *http.Client
+	interval     time.Duration
+	batchSize    int
+	lease        time.Duration // на это время взятые доставки скрыты от других экземпляров
+	retryInitial time.Duration
+	retryMax     time.Duration
+	maxAttempts  int
+	allowHTTP    bool
+	logger       *zap.Logger
+}
+
+// NewWebhookDispatcher создание диспетчера вебхуков
+func NewWebhookDispatcher(repo repository.WebhookRepository, cfg config.Webhooks, logger *zap.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhooks:     repo,
+		client:       newWebhookClient(cfg.Timeout, models.PublicIP),
+		interval:     cfg.PollInterval,
+		batchSize:    max(cfg.BatchSize, 1),
+		lease:        2*cfg.Timeout + time.Minute,
+		retryInitial: cfg.RetryInitial,
+		retryMax:     cfg.RetryMax,
+		maxAttempts:  max(cfg.MaxAttempts, 1),
+		allowHTTP:    cfg.AllowHTTP,
+		logger:       logger,
+	}
+}
+
+// newWebhookClient HTTP-клиент вебхуков. Адрес проверяется после разрешения имени, прямо перед подключением,
+// поэтому имя, которое после регистрации стало указывать во внутреннюю сеть (DNS rebinding), не пройдет.
+// Прокси из окружения не используется, а редиректы не выполняются: ответ 3xx считается неудачей
+func newWebhookClient(timeout time.Duration, allowed func(net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowed(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", errForbiddenDestination, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(metrics.NewRoundTripper("webhook", transport)),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run отправка до отмены ctx. Пачка отправляется параллельно, пока пачки полные, следующая берется без паузы
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	for {
+		deliveries, err := d.webhooks.ClaimWebhookDeliveries(ctx, d.batchSize, d.lease)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Warn("Failed to claim webhook deliveries", zap.Error(err))
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if err == nil && len(deliveries) == d.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(d.interval):
+		}
+	}
+}
+
+// deliver одна попытка доставки и запись ее результата
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	statusCode, err := d.send(ctx, delivery)
+	if ctx.Err() != nil {
+		return // остановка сервиса: доставка вернется к отправке после lease
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	result := "delivered"
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = models.ProviderResponseSnippet(err.Error())
+		result = "failed"
+		d.logger.Error("Webhook delivery failed", zap.String("delivery_id", delivery.ID), zap.String("url", delivery.URL), zap.Int("attempts", delivery.Attempts), zap.Error(err))
+	default:
+		delivery.LastError = models.ProviderResponseSnippet(err.Error())
+		delivery.NextAttemptAt = now.Add(backoff(delivery.Attempts, d.retryInitial, d.retryMax))
+		result = "retry"
+		d.logger.Warn("Webhook delivery attempt failed", zap.String("delivery_id", delivery.ID), zap.String("url", delivery.URL), zap.Int("attempts", delivery.Attempts), zap.Time("next_attempt_at", delivery.NextAttemptAt), zap.Error(err))
+	}
+	metrics.WebhookDeliveries.WithLabelValues(result).Inc()
+
+	if err := d.webhooks.SaveWebhookAttempt(ctx, delivery); err != nil {
+		d.logger.Warn("Failed to save webhook attempt", zap.String("delivery_id", delivery.ID), zap.Error(err))
+	}
+}
+
+// send подписанный POST на адрес, успехом считается любой ответ 2xx
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	if parsed, err := url.Parse(delivery.URL); err != nil || parsed.Scheme != "https" && !(parsed.Scheme == "http" && d.allowHTTP) {
+		return 0, fmt.Errorf("webhook url must use https: %s", delivery.URL) // адрес сохранили, пока http был разрешен
+	}
+	body, err := delivery.Body()
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode webhook: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(delivery.Secret, time.Now(), body))
+	req.Header.Set(webhooks.HeaderEventID, delivery.Event.ID)
+	req.Header.Set(webhooks.HeaderEventType, delivery.Event.Type)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	return resp.StatusCode, nil
+}
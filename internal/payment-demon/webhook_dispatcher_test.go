@@ -0,0 +1,159 @@
+package payments_demon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/webhooks"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeWebhooks запоминает сохраненные попытки, остальные методы хранилища не нужны
+type fakeWebhooks struct {
+	repository.WebhookRepository
+	mu    sync.Mutex
+	saved []models.WebhookDelivery
+}
+
+func (f *fakeWebhooks) SaveWebhookAttempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saved = append(f.saved, *delivery)
+	return nil
+}
+
+func newTestDelivery(url string) *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		ID:         "delivery-1",
+		EndpointID: "endpoint-1",
+		Event:      models.OutboxEvent{ID: "event-1", Type: models.EventPaymentPaid, PaymentID: "payment-1", Payload: json.RawMessage(`{"actor":"poller"}`)},
+		Status:     models.WebhookDeliveryPending,
+		URL:        url,
+		Secret:     "whsec_test",
+	}
+}
+
+// newTestDispatcher диспетчер, которому разрешен httptest-сервер: http на loopback
+func newTestDispatcher(t *testing.T, repo repository.WebhookRepository, cfg config.Webhooks) *WebhookDispatcher {
+	cfg.AllowHTTP = true
+	dispatcher := NewWebhookDispatcher(repo, cfg, zaptest.NewLogger(t))
+	dispatcher.client = newWebhookClient(cfg.Timeout, func(net.IP) bool { return true })
+	return dispatcher
+}
+
+func TestWebhookDispatcher_RetriesThenDelivers(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, webhooks.Verify("whsec_test", r.Header.Get(webhooks.HeaderSignature), body, time.Minute, time.Now()))
+		assert.Equal(t, "event-1", r.Header.Get(webhooks.HeaderEventID))
+		assert.Equal(t, models.EventPaymentPaid, r.Header.Get(webhooks.HeaderEventType))
+		calls++
+		if calls == 1 {
+			http.Error(w, "temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	repo := &fakeWebhooks{}
+	cfg := config.Webhooks{Timeout: time.Second, RetryInitial: time.Minute, RetryMax: time.Hour, MaxAttempts: 3}
+	dispatcher := newTestDispatcher(t, repo, cfg)
+
+	delivery := newTestDelivery(server.URL)
+	dispatcher.deliver(context.Background(), delivery)
+	require.Len(t, repo.saved, 1)
+	assert.Equal(t, models.WebhookDeliveryPending, repo.saved[0].Status)
+	assert.Equal(t, http.StatusServiceUnavailable, repo.saved[0].LastStatusCode)
+	assert.Contains(t, repo.saved[0].LastError, "temporarily unavailable")
+	assert.True(t, repo.saved[0].NextAttemptAt.After(time.Now().Add(29*time.Second)))
+
+	dispatcher.deliver(context.Background(), delivery)
+	require.Len(t, repo.saved, 2)
+	assert.Equal(t, models.WebhookDeliveryDelivered, repo.saved[1].Status)
+	assert.Equal(t, 2, repo.saved[1].Attempts)
+	assert.Empty(t, repo.saved[1].LastError)
+	assert.NotNil(t, repo.saved[1].DeliveredAt)
+}
+
+func TestWebhookDispatcher_FailsAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := &fakeWebhooks{}
+	cfg := config.Webhooks{Timeout: time.Second, RetryInitial: time.Minute, RetryMax: time.Hour, MaxAttempts: 2}
+	dispatcher := newTestDispatcher(t, repo, cfg)
+
+	delivery := newTestDelivery(server.URL)
+	dispatcher.deliver(context.Background(), delivery)
+	dispatcher.deliver(context.Background(), delivery)
+	require.Len(t, repo.saved, 2)
+	assert.Equal(t, models.WebhookDeliveryPending, repo.saved[0].Status)
+	assert.Equal(t, models.WebhookDeliveryFailed, repo.saved[1].Status)
+	assert.Nil(t, repo.saved[1].DeliveredAt)
+}
+
+func TestWebhookDispatcher_RefusesInternalDestinations(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	repo := &fakeWebhooks{}
+	cfg := config.Webhooks{Timeout: time.Second, RetryInitial: time.Minute, RetryMax: time.Hour, MaxAttempts: 3, AllowHTTP: true}
+	dispatcher := NewWebhookDispatcher(repo, cfg, zaptest.NewLogger(t))
+
+	dispatcher.deliver(context.Background(), newTestDelivery(server.URL))
+	require.Len(t, repo.saved, 1)
+	assert.Equal(t, models.WebhookDeliveryPending, repo.saved[0].Status)
+	assert.Contains(t, repo.saved[0].LastError, "not a public address")
+	assert.Zero(t, calls, "loopback must be refused at dial time")
+}
+
+func TestWebhookDispatcher_RequiresHTTPSUnlessAllowed(t *testing.T) {
+	repo := &fakeWebhooks{}
+	cfg := config.Webhooks{Timeout: time.Second, RetryInitial: time.Minute, RetryMax: time.Hour, MaxAttempts: 3}
+	dispatcher := NewWebhookDispatcher(repo, cfg, zaptest.NewLogger(t))
+
+	dispatcher.deliver(context.Background(), newTestDelivery("http://example.com/hook"))
+	require.Len(t, repo.saved, 1)
+	assert.Contains(t, repo.saved[0].LastError, "must use https")
+}
+
+func TestWebhookDispatcher_DoesNotFollowRedirects(t *testing.T) {
+	var redirected bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer server.Close()
+
+	repo := &fakeWebhooks{}
+	cfg := config.Webhooks{Timeout: time.Second, RetryInitial: time.Minute, RetryMax: time.Hour, MaxAttempts: 3}
+	dispatcher := newTestDispatcher(t, repo, cfg)
+
+	dispatcher.deliver(context.Background(), newTestDelivery(server.URL))
+	require.Len(t, repo.saved, 1)
+	assert.Equal(t, models.WebhookDeliveryPending, repo.saved[0].Status)
+	assert.Equal(t, http.StatusTemporaryRedirect, repo.saved[0].LastStatusCode)
+	assert.False(t, redirected)
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// PaymentCacheInvalidator сброс кэша платежа, если он изменился в обход PaymentRepository (например, возвратом)
+type PaymentCacheInvalidator interface {
+	InvalidatePayment(ctx context.Context, paymentID string)
+}
+
+// NoPaymentCache invalidator для работы без кэша
+type NoPaymentCache struct{}
+
+func (NoPaymentCache) InvalidatePayment(context.Context, string) {}
+
+// CachedPaymentRepository декоратор PaymentRepository с кэшем в Redis.
+// Любое изменение платежа сбрасывает сам платеж, его детали и все страницы истории его участников.
+type CachedPaymentRepository struct {
+	next       PaymentRepository
+	redis      *redis.Client
+	logger     *zap.Logger
+	paymentTTL time.Duration
+	historyTTL time.Duration
+	detailsTTL time.Duration
+}
+
+// NewCachedPaymentRepository создание кэширующего репозитория поверх next
+func NewCachedPaymentRepository(next PaymentRepository, redis *redis.Client, cfg config.Cache, logger *zap.Logger) *CachedPaymentRepository {
+	return &CachedPaymentRepository{
+		next:       next,
+		redis:      redis,
+		logger:     logger,
+		paymentTTL: cfg.PaymentTTL,
+		historyTTL: cfg.HistoryTTL,
+		detailsTTL: cfg.DetailsTTL,
+	}
+}
+
+// paymentVersionKey версия платежа, входит в ключи платежа и его деталей, как версия истории.
+// Сброс меняет версию, поэтому платеж, прочитанный из бд до сброса, записывается под старый ключ и больше не читается.
+// Версия - время сброса, а не счетчик: ключ версии живет не дольше закэшированных значений,
+// и после его истечения новая версия не совпадет со старой
+func paymentVersionKey(paymentID string) string {
+	return fmt.Sprintf("payment_version:%s", paymentID)
+}
+
+func paymentCacheKey(paymentID string, version int64) string {
+	return fmt.Sprintf("payment:%s:%d", paymentID, version)
+}
+
+func paymentDetailsCacheKey(paymentID string, version int64) string {
+	return fmt.Sprintf("payment_details:%s:%d", paymentID, version)
+}
+
+// historyVersionKey версия истории пользователя, входит в ключи всех страниц.
+// Увеличение версии разом сбрасывает все закэшированные страницы.
+func historyVersionKey(userID string) string {
+	return fmt.Sprintf("payment_history_version:%s", userID)
+}
+
+// historyCacheKey ключ страницы истории: фильтры и курсор входят в ключ в виде хэша
+func historyCacheKey(version int64, query models.HistoryQuery) string {
+	data, _ := json.Marshal(query)
+	return fmt.Sprintf("payment_history:%s:%d:%x", query.UserID, version, sha256.Sum256(data))
+}
+
+func (c *CachedPaymentRepository) GetPaymentByID(ctx context.Context, paymentID string) (*models.Payment, error) {
+	version, ok := c.paymentVersion(ctx, paymentID)
+	if !ok {
+		return c.next.GetPaymentByID(ctx, paymentID)
+	}
+
+	key := paymentCacheKey(paymentID, version)
+	var payment models.Payment
+	if c.get(ctx, key, &payment) {
+		return &payment, nil
+	}
+
+	result, err := c.next.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	c.set(ctx, key, result, c.paymentTTL)
+	return result, nil
+}
+
+func (c *CachedPaymentRepository) GetPaymentHistory(ctx context.Context, query models.HistoryQuery) (*models.HistoryPage, error) {
+	version, err := c.redis.Get(ctx, historyVersionKey(query.UserID)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		c.logger.Warn("Failed to read payment history cache version", zap.String("user_id", query.UserID), zap.Error(err))
+		return c.next.GetPaymentHistory(ctx, query)
+	}
+
+	// версия читается до запроса в бд, поэтому страница, прочитанная до изменения, уйдет под старый ключ
+	key := historyCacheKey(version, query)
+	var page models.HistoryPage
+	if c.get(ctx, key, &page) {
+		return &page, nil
+	}
+
+	result, err := c.next.GetPaymentHistory(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.set(ctx, key, result, c.historyTTL)
+	return result, nil
+}
+
+func (c *CachedPaymentRepository) GetPaymentDetails(ctx context.Context, paymentID string) (models.Money, error) {
+	version, ok := c.paymentVersion(ctx, paymentID)
+	if !ok {
+		return c.next.GetPaymentDetails(ctx, paymentID)
+	}
+
+	key := paymentDetailsCacheKey(paymentID, version)
+	var details models.Money
+	if c.get(ctx, key, &details) {
+		return details, nil
+	}
+
+	details, err := c.next.GetPaymentDetails(ctx, paymentID)
+	if err != nil {
+		return models.Money{}, err
+	}
+	c.set(ctx, key, details, c.detailsTTL)
+	return details, nil
+}
+
+func (c *CachedPaymentRepository) CreatePayment(ctx context.Context, fromUserID, toUserID string, amount, fee models.Money, provider string) (string, error) {
+	paymentID, err := c.next.CreatePayment(ctx, fromUserID, toUserID, amount, fee, provider)
+	if err != nil {
+		return "", err
+	}
+	c.invalidateHistory(ctx, fromUserID, toUserID) // новый платеж должен появиться в истории
+	return paymentID, nil
+}
+
+func (c *CachedPaymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, change models.StatusChange) (*models.PaymentEvent, error) {
+	event, err := c.next.UpdatePaymentStatus(ctx, paymentID, change)
+	if err != nil {
+		if errors.Is(err, ErrStatusConflict) {
+			c.InvalidatePayment(ctx, paymentID) // в кэше мог остаться старый статус
+		}
+		return nil, err
+	}
+	c.InvalidatePayment(ctx, paymentID)
+	return event, nil
+}
+
+// GetActivePayments не кэшируется: список меняется с каждым статусом
+func (c *CachedPaymentRepository) GetActivePayments(ctx context.Context, userID string) ([]*models.Payment, error) {
+	return c.next.GetActivePayments(ctx, userID)
+}
+
+func (c *CachedPaymentRepository) GetPaymentEvents(ctx context.Context, paymentID string) ([]*models.PaymentEvent, error) {
+	return c.next.GetPaymentEvents(ctx, paymentID)
+}
+
+// GetExpiredPayments не кэшируется: нужен актуальный статус
+func (c *CachedPaymentRepository) GetExpiredPayments(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Payment, error) {
+	return c.next.GetExpiredPayments(ctx, createdBefore, limit)
+}
+
+// paymentVersion текущая версия платежа, 0 - платеж еще не сбрасывался.
+// Версия читается до запроса в бд; если прочитать ее не удалось, кэш не используется
+func (c *CachedPaymentRepository) paymentVersion(ctx context.Context, paymentID string) (int64, bool) {
+	version, err := c.redis.Get(ctx, paymentVersionKey(paymentID)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		c.logger.Warn("Failed to read payment cache version", zap.String("payment_id", paymentID), zap.Error(err))
+		return 0, false
+	}
+	return version, true
+}
+
+// InvalidatePayment сброс платежа, его деталей и истории отправителя и получателя
+func (c *CachedPaymentRepository) InvalidatePayment(ctx context.Context, paymentID string) {
+	payment, err := c.next.GetPaymentByID(ctx, paymentID) // участники нужны, чтобы сбросить их историю
+	if err := c.redis.Set(ctx, paymentVersionKey(paymentID), time.Now().UnixNano(), c.versionTTL()).Err(); err != nil {
+		c.logger.Warn("Failed to invalidate payment cache", zap.String("payment_id", paymentID), zap.Error(err))
+	}
+	if err != nil {
+		if !errors.Is(err, ErrPaymentNotFound) {
+			c.logger.Warn("Failed to fetch payment for cache invalidation", zap.String("payment_id", paymentID), zap.Error(err))
+		}
+		return
+	}
+	c.invalidateHistory(ctx, payment.FromUserID, payment.ToUserID)
+}
+
+// versionTTL версия платежа нужна, пока живут значения, записанные под ней. 0 - без срока
+func (c *CachedPaymentRepository) versionTTL() time.Duration {
+	if c.paymentTTL <= 0 || c.detailsTTL <= 0 {
+		return 0
+	}
+	return max(c.paymentTTL, c.detailsTTL)
+}
+
+// invalidateHistory сброс всех страниц истории пользователей
+func (c *CachedPaymentRepository) invalidateHistory(ctx context.Context, userIDs ...string) {
+	for _, userID := range userIDs {
+		if err := c.redis.Incr(ctx, historyVersionKey(userID)).Err(); err != nil {
+			c.logger.Warn("Failed to invalidate payment history cache", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+}
+
+// get чтение из кэша, при промахе или ошибке Redis идем в бд
+func (c *CachedPaymentRepository) get(ctx context.Context, key string, value interface{}) bool {
+	data, err := c.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.logger.Warn("Failed to read payment cache", zap.String("key", key), zap.Error(err))
+		}
+		return false
+	}
+	return json.Unmarshal(data, value) == nil
+}
+
+func (c *CachedPaymentRepository) set(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if err := c.redis.Set(ctx, key, data, ttl).Err(); err != nil {
+		c.logger.Warn("Failed to write payment cache", zap.String("key", key), zap.Error(err))
+	}
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// fakePaymentRepository репозиторий в памяти со счетчиком обращений
+type fakePaymentRepository struct {
+	PaymentRepository
+	payments  map[string]*models.Payment
+	reads     int
+	afterRead func() // вызывается один раз после чтения платежа, до возврата результата
+}
+
+func (f *fakePaymentRepository) GetPaymentByID(ctx context.Context, paymentID string) (*models.Payment, error) {
+	f.reads++
+	payment, ok := f.payments[paymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	copied := *payment
+	if afterRead := f.afterRead; afterRead != nil {
+		f.afterRead = nil
+		afterRead()
+	}
+	return &copied, nil
+}
+
+func (f *fakePaymentRepository) GetPaymentHistory(ctx context.Context, query models.HistoryQuery) (*models.HistoryPage, error) {
+	f.reads++
+	page := &models.HistoryPage{}
+	for _, payment := range f.payments {
+		if payment.FromUserID == query.UserID {
+			copied := *payment
+			page.Payments = append(page.Payments, &copied)
+		}
+	}
+	return page, nil
+}
+
+func (f *fakePaymentRepository) CreatePayment(ctx context.Context, fromUserID, toUserID string, amount, fee models.Money, provider string) (string, error) {
+	id := "payment-" + string(rune('a'+len(f.payments)))
+	f.payments[id] = &models.Payment{ID: id, FromUserID: fromUserID, ToUserID: toUserID, Amount: amount, Fee: fee, Status: models.StatusPending}
+	return id, nil
+}
+
+func (f *fakePaymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, change models.StatusChange) (*models.PaymentEvent, error) {
+	payment := f.payments[paymentID]
+	if payment.Status != change.Expected {
+		return nil, &StatusConflictError{PaymentID: paymentID, Expected: change.Expected, Actual: payment.Status}
+	}
+	payment.Status = change.Status
+	return &models.PaymentEvent{PaymentID: paymentID, OldStatus: change.Expected, NewStatus: change.Status}, nil
+}
+
+func newTestCachedRepository(t *testing.T) (*CachedPaymentRepository, *fakePaymentRepository, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	fake := &fakePaymentRepository{payments: map[string]*models.Payment{}}
+	cfg := config.Cache{Enabled: true, PaymentTTL: time.Minute, HistoryTTL: time.Minute, DetailsTTL: time.Minute}
+	return NewCachedPaymentRepository(fake, rdb, cfg, zap.NewNop()), fake, mr
+}
+
+func TestCachedPaymentRepository_StatusUpdateInvalidatesPayment(t *testing.T) {
+	ctx := context.Background()
+	repo, fake, mr := newTestCachedRepository(t)
+	id, err := repo.CreatePayment(ctx, "alice", "bob", models.Money{MinorUnits: 100, Currency: "RUB"}, models.Money{Currency: "RUB"}, "yoomoney")
+	assert.NoError(t, err)
+
+	payment, err := repo.GetPaymentByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusPending, payment.Status)
+	_, err = repo.GetPaymentByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.reads)
+	assert.True(t, mr.Exists(paymentCacheKey(id, 0)))
+
+	_, err = repo.UpdatePaymentStatus(ctx, id, models.StatusChange{Expected: models.StatusPending, Status: models.StatusSuccess})
+	assert.NoError(t, err)
+
+	payment, err = repo.GetPaymentByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusSuccess, payment.Status)
+}
+
+func TestCachedPaymentRepository_ConcurrentInvalidateKeepsStaleReadOut(t *testing.T) {
+	ctx := context.Background()
+	repo, fake, _ := newTestCachedRepository(t)
+	id, _ := repo.CreatePayment(ctx, "alice", "bob", models.Money{MinorUnits: 100, Currency: "RUB"}, models.Money{Currency: "RUB"}, "yoomoney")
+
+	fake.afterRead = func() { // пока читатель держит старую строку, статус меняется и кэш сбрасывается
+		fake.payments[id].Status = models.StatusSuccess
+		repo.InvalidatePayment(ctx, id)
+	}
+	payment, err := repo.GetPaymentByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusPending, payment.Status)
+
+	payment, err = repo.GetPaymentByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusSuccess, payment.Status, "stale row written after invalidate must not be served")
+}
+
+func TestCachedPaymentRepository_InvalidatesAllHistoryPages(t *testing.T) {
+	ctx := context.Background()
+	repo, _, _ := newTestCachedRepository(t)
+	first, err := repo.CreatePayment(ctx, "alice", "bob", models.Money{MinorUnits: 100, Currency: "RUB"}, models.Money{Currency: "RUB"}, "yoomoney")
+	assert.NoError(t, err)
+
+	queries := []models.HistoryQuery{
+		{UserID: "alice", Limit: 10},
+		{UserID: "alice", Limit: 10, Direction: models.DirectionOutgoing, Currency: "RUB"},
+	}
+	for _, query := range queries {
+		history, err := repo.GetPaymentHistory(ctx, query)
+		assert.NoError(t, err)
+		assert.Len(t, history.Payments, 1)
+	}
+
+	_, err = repo.CreatePayment(ctx, "alice", "carol", models.Money{MinorUnits: 200, Currency: "RUB"}, models.Money{Currency: "RUB"}, "yoomoney")
+	assert.NoError(t, err)
+	for _, query := range queries {
+		history, err := repo.GetPaymentHistory(ctx, query)
+		assert.NoError(t, err)
+		assert.Len(t, history.Payments, 2, "query %+v", query)
+	}
+
+	_, err = repo.UpdatePaymentStatus(ctx, first, models.StatusChange{Expected: models.StatusPending, Status: models.StatusFailed})
+	assert.NoError(t, err)
+	history, err := repo.GetPaymentHistory(ctx, queries[1])
+	assert.NoError(t, err)
+	statuses := map[models.PaymentStatus]int{}
+	for _, payment := range history.Payments {
+		statuses[payment.Status]++
+	}
+	assert.Equal(t, 1, statuses[models.StatusFailed])
+}
+
+func TestCachedPaymentRepository_ConflictDropsStalePayment(t *testing.T) {
+	ctx := context.Background()
+	repo, fake, _ := newTestCachedRepository(t)
+	id, _ := repo.CreatePayment(ctx, "alice", "bob", models.Money{MinorUnits: 100, Currency: "RUB"}, models.Money{Currency: "RUB"}, "yoomoney")
+	_, err := repo.GetPaymentByID(ctx, id)
+	assert.NoError(t, err)
+
+	fake.payments[id].Status = models.StatusSuccess // статус поменяли в обход кэша
+	_, err = repo.UpdatePaymentStatus(ctx, id, models.StatusChange{Expected: models.StatusPending, Status: models.StatusFailed})
+	assert.ErrorIs(t, err, ErrStatusConflict)
+
+	payment, err := repo.GetPaymentByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusSuccess, payment.Status)
+}
+
+func TestCachedPaymentRepository_HistoryKeyIncludesCursor(t *testing.T) {
+	ctx := context.Background()
+	repo, fake, _ := newTestCachedRepository(t)
+	_, err := repo.CreatePayment(ctx, "alice", "bob", models.Money{MinorUnits: 100, Currency: "RUB"}, models.Money{Currency: "RUB"}, "yoomoney")
+	assert.NoError(t, err)
+
+	query := models.HistoryQuery{UserID: "alice", Limit: 10}
+	_, err = repo.GetPaymentHistory(ctx, query)
+	assert.NoError(t, err)
+	_, err = repo.GetPaymentHistory(ctx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.reads)
+
+	query.Cursor = &models.HistoryCursor{CreatedAt: time.Now(), ID: "payment-a"}
+	_, err = repo.GetPaymentHistory(ctx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.reads, "another page must not be served from the first page cache")
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// DeadLetterRepository платежи, на которых демон исчерпал попытки
+type DeadLetterRepository interface {
+	// AddDeadLetter сохранение платежа, повторная передача обновляет ошибку и число попыток
+	AddDeadLetter(ctx context.Context, letter models.DeadLetter) error
+	ListDeadLetters(ctx context.Context, limit int) ([]*models.DeadLetter, error)
+	// DeleteDeadLetter удаление перед возвратом платежа в очередь
+	DeleteDeadLetter(ctx context.Context, paymentID string) error
+}
+
+type deadLetterRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewDeadLetterRepository создание хранилища dead letter в постгресе
+func NewDeadLetterRepository(db *pgxpool.Pool, logger *zap.Logger) DeadLetterRepository {
+	return &deadLetterRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *deadLetterRepository) AddDeadLetter(ctx context.Context, letter models.DeadLetter) error {
+	query := `INSERT INTO payment_dead_letters (payment_id, stage, last_error, attempts)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (payment_id) DO UPDATE SET stage = EXCLUDED.stage, last_error = EXCLUDED.last_error,
+			  attempts = EXCLUDED.attempts, updated_at = NOW()`
+	_, err := r.db.Exec(ctx, query, letter.PaymentID, letter.Stage, letter.LastError, letter.Attempts)
+	if err != nil {
+		r.logger.Error("Failed to save dead letter", zap.String("payment_id", letter.PaymentID), zap.Error(err))
+		return fmt.Errorf("error saving dead letter: %w", err)
+	}
+	return nil
+}
+
+func (r *deadLetterRepository) ListDeadLetters(ctx context.Context, limit int) ([]*models.DeadLetter, error) {
+	query := `SELECT payment_id, stage, last_error, attempts, created_at, updated_at
+			  FROM payment_dead_letters ORDER BY updated_at DESC, payment_id LIMIT $1`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		r.logger.Error("Failed to fetch dead letters", zap.Error(err))
+		return nil, fmt.Errorf("error fetching dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []*models.DeadLetter
+	for rows.Next() {
+		var letter models.DeadLetter
+		err := rows.Scan(&letter.PaymentID, &letter.Stage, &letter.LastError, &letter.Attempts, &letter.CreatedAt, &letter.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning dead letter: %w", err)
+		}
+		letters = append(letters, &letter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return letters, nil
+}
+
+func (r *deadLetterRepository) DeleteDeadLetter(ctx context.Context, paymentID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM payment_dead_letters WHERE payment_id = $1`, paymentID)
+	if err != nil {
+		r.logger.Error("Failed to delete dead letter", zap.String("payment_id", paymentID), zap.Error(err))
+		return fmt.Errorf("error deleting dead letter: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
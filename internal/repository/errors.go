@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+// ErrPaymentNotFound платежа с таким id нет
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// ErrDeadLetterNotFound платежа нет среди dead letter
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrStatusConflict статус платежа уже изменен другим запросом
+var ErrStatusConflict = errors.New("payment status conflict")
+
+// StatusConflictError статус платежа не совпал с ожидаемым при обновлении
+type StatusConflictError struct {
+	PaymentID string
+	Expected  models.PaymentStatus
+	Actual    models.PaymentStatus
+}
+
+func (e *StatusConflictError) Error() string {
+	return fmt.Sprintf("payment %s status conflict: expected %s, actual %s", e.PaymentID, e.Expected, e.Actual)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrStatusConflict)
+func (e *StatusConflictError) Is(target error) bool {
+	return target == ErrStatusConflict
+}
+
+// ErrReconciliationReportNotFound отчета сверки с таким id нет
+var ErrReconciliationReportNotFound = errors.New("reconciliation report not found")
+
+// ErrWebhookEndpointNotFound адреса вебхука с таким id нет
+var ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+
+// ErrWebhookDeliveryNotFound доставки вебхука с таким id нет
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+// ErrWebhookDeliveryNotFailed вручную повторяется только доставка, исчерпавшая попытки
+var ErrWebhookDeliveryNotFailed = errors.New("webhook delivery is not failed")
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// IdempotencyRepository хранилище ключей идемпотентности. Ключи разных пользователей не пересекаются
+type IdempotencyRepository interface {
+	// Reserve занимает ключ под запрос. Если ключ уже занят, возвращает сохраненную запись и false
+	Reserve(ctx context.Context, operation, userID, key, fingerprint string) (*models.IdempotencyRecord, bool, error)
+	// Complete сохраняет ответ на запрос с ключом
+	Complete(ctx context.Context, operation, userID, key string, response []byte) error
+	// Release освобождает ключ, если запрос завершился ошибкой и его можно повторить
+	Release(ctx context.Context, operation, userID, key string) error
+}
+
+type idempotencyRepository struct {
+	db          *pgxpool.Pool
+	logger      *zap.Logger
+	redis       *redis.Client
+	ttl         time.Duration
+	lockTimeout time.Duration
+}
+
+// NewIdempotencyRepository создание хранилища ключей в постгресе с быстрым путем через редиску
+func NewIdempotencyRepository(db *pgxpool.Pool, logger *zap.Logger, redis *redis.Client, ttl, lockTimeout time.Duration) IdempotencyRepository {
+	return &idempotencyRepository{
+		db:          db,
+		logger:      logger,
+		redis:       redis,
+		ttl:         ttl,
+		lockTimeout: lockTimeout,
+	}
+}
+
+func (r *idempotencyRepository) Reserve(ctx context.Context, operation, userID, key, fingerprint string) (*models.IdempotencyRecord, bool, error) {
+	cacheKey := idempotencyCacheKey(operation, userID, key)
+	cached, err := r.redis.Get(ctx, cacheKey).Result() // в редиске только завершенные запросы
+	if err == nil {
+		var record models.IdempotencyRecord
+		if err := json.Unmarshal([]byte(cached), &record); err == nil {
+			return &record, false, nil
+		}
+	}
+
+	// ключ занимается заново, если старая запись истекла или зависла незавершенной
+	now := time.Now()
+	query := `INSERT INTO idempotency_keys (operation, user_id, key, fingerprint, created_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (operation, user_id, key) DO UPDATE
+			  SET fingerprint = EXCLUDED.fingerprint, response = NULL, completed = FALSE, created_at = EXCLUDED.created_at
+			  WHERE idempotency_keys.created_at < $6
+			     OR (NOT idempotency_keys.completed AND idempotency_keys.created_at < $7)
+			  RETURNING key`
+	var reserved string
+	err = r.db.QueryRow(ctx, query, operation, userID, key, fingerprint, now, now.Add(-r.ttl), now.Add(-r.lockTimeout)).Scan(&reserved)
+	if err == nil {
+		return nil, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		r.logger.Error("Failed to reserve idempotency key", zap.String("operation", operation), zap.String("user_id", userID), zap.String("key", key), zap.Error(err))
+		return nil, false, fmt.Errorf("error reserving idempotency key: %w", err)
+	}
+
+	record := models.IdempotencyRecord{Operation: operation, UserID: userID, Key: key}
+	query = `SELECT fingerprint, response, completed FROM idempotency_keys WHERE operation = $1 AND user_id = $2 AND key = $3`
+	err = r.db.QueryRow(ctx, query, operation, userID, key).Scan(&record.Fingerprint, &record.Response, &record.Completed)
+	if err != nil {
+		r.logger.Error("Failed to fetch idempotency key", zap.String("operation", operation), zap.String("user_id", userID), zap.String("key", key), zap.Error(err))
+		return nil, false, fmt.Errorf("error fetching idempotency key: %w", err)
+	}
+
+	if record.Completed {
+		r.cache(ctx, &record)
+	}
+	return &record, false, nil
+}
+
+func (r *idempotencyRepository) Complete(ctx context.Context, operation, userID, key string, response []byte) error {
+	query := `UPDATE idempotency_keys SET response = $1, completed = TRUE WHERE operation = $2 AND user_id = $3 AND key = $4
+			  RETURNING fingerprint`
+	record := models.IdempotencyRecord{Operation: operation, UserID: userID, Key: key, Response: response, Completed: true}
+	err := r.db.QueryRow(ctx, query, response, operation, userID, key).Scan(&record.Fingerprint)
+	if err != nil {
+		r.logger.Error("Failed to complete idempotency key", zap.String("operation", operation), zap.String("user_id", userID), zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("error completing idempotency key: %w", err)
+	}
+
+	r.cache(ctx, &record)
+	return nil
+}
+
+func (r *idempotencyRepository) Release(ctx context.Context, operation, userID, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE operation = $1 AND user_id = $2 AND key = $3 AND NOT completed`
+	_, err := r.db.Exec(ctx, query, operation, userID, key)
+	if err != nil {
+		r.logger.Error("Failed to release idempotency key", zap.String("operation", operation), zap.String("user_id", userID), zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) cache(ctx context.Context, record *models.IdempotencyRecord) {
+	data, err := json.Marshal(record)
+	if err == nil {
+		r.redis.Set(ctx, idempotencyCacheKey(record.Operation, record.UserID, record.Key), data, r.ttl)
+	}
+}
+
+func idempotencyCacheKey(operation, userID, key string) string {
+	return fmt.Sprintf("idempotency:%s:%s:%s", operation, userID, key)
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// LedgerRepository чтение главной книги. Проводки пишутся в транзакциях изменения платежей и возвратов
+type LedgerRepository interface {
+	// GetBalances остатки счета по валютам со стороны его нормального сальдо
+	GetBalances(ctx context.Context, account string) ([]*models.AccountBalance, error)
+}
+
+type ledgerRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewLedgerRepository создание хранилища главной книги в постгресе
+func NewLedgerRepository(db *pgxpool.Pool, logger *zap.Logger) LedgerRepository {
+	return &ledgerRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *ledgerRepository) GetBalances(ctx context.Context, account string) ([]*models.AccountBalance, error) {
+	query := `SELECT currency, SUM(amount_minor) FROM ledger_postings WHERE account = $1 GROUP BY currency ORDER BY currency`
+	rows, err := r.db.Query(ctx, query, account)
+	if err != nil {
+		r.logger.Error("Failed to fetch ledger balances", zap.String("account", account), zap.Error(err))
+		return nil, fmt.Errorf("error fetching ledger balances: %w", err)
+	}
+	defer rows.Close()
+
+	var balances []*models.AccountBalance
+	for rows.Next() {
+		balance := &models.AccountBalance{Account: account}
+		if err := rows.Scan(&balance.Balance.Currency, &balance.Balance.MinorUnits); err != nil {
+			return nil, fmt.Errorf("error scanning ledger balance: %w", err)
+		}
+		if models.CreditNormal(account) {
+			balance.Balance.MinorUnits = -balance.Balance.MinorUnits
+		}
+		balances = append(balances, balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return balances, nil
+}
+
+// postPaymentLedger проводка по смене статуса платежа, payment - снимок после смены.
+// Создание, неудачная оплата, истечение и полный возврат денег не двигают: возвраты проводятся отдельно
+func postPaymentLedger(ctx context.Context, tx pgx.Tx, payment *models.Payment, oldStatus models.PaymentStatus) error {
+	switch {
+	case payment.Status == models.StatusSuccess && oldStatus == models.StatusComplete:
+		paid, fee, err := paidOut(ctx, tx, payment)
+		if err != nil || paid.IsZero() && fee.IsZero() {
+			return err
+		}
+		return postLedgerEntry(ctx, tx, models.PayoutReversalEntry(payment, paid, fee))
+	case payment.Status == models.StatusSuccess && !oldStatus.IsPaid():
+		return postLedgerEntry(ctx, tx, models.FundsReceivedEntry(payment))
+	case payment.Status == models.StatusComplete:
+		// получателю переводится сумма за вычетом возвратов и комиссии, как в демоне
+		refunded, err := sumRefunds(ctx, tx, payment.ID, payment.Amount.Currency)
+		if err != nil {
+			return err
+		}
+		payout, fee, err := payment.PayoutSplit(refunded)
+		if err != nil || !payout.IsPositive() && !fee.IsPositive() {
+			return err
+		}
+		return postLedgerEntry(ctx, tx, models.PayoutEntry(payment, payout, fee))
+	}
+	return nil
+}
+
+// postRefundLedger проводка успешного возврата
+func postRefundLedger(ctx context.Context, tx pgx.Tx, refund *models.Refund) error {
+	payment, err := scanPayment(tx.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, refund.PaymentID))
+	if err != nil {
+		return fmt.Errorf("error fetching payment for ledger: %w", err)
+	}
+	held := models.Money{Currency: refund.Amount.Currency}
+	query := `SELECT COALESCE(-SUM(p.amount_minor), 0) FROM ledger_postings p JOIN ledger_entries e ON e.id = p.entry_id
+			  WHERE e.payment_id = $1 AND p.account = $2 AND p.currency = $3`
+	if err := tx.QueryRow(ctx, query, payment.ID, models.UserAccount(payment.ToUserID), held.Currency).Scan(&held.MinorUnits); err != nil {
+		return fmt.Errorf("error fetching held amount: %w", err)
+	}
+	return postLedgerEntry(ctx, tx, models.RefundEntry(payment, refund, held))
+}
+
+// paidOut сколько по платежу переведено получателю и удержано комиссией, не считая отмененных выплат
+func paidOut(ctx context.Context, tx pgx.Tx, payment *models.Payment) (paid, fee models.Money, err error) {
+	paid = models.Money{Currency: payment.Amount.Currency}
+	fee = models.Money{Currency: payment.Amount.Currency}
+	query := `SELECT COALESCE(-SUM(p.amount_minor) FILTER (WHERE p.account = $4), 0), COALESCE(-SUM(p.amount_minor) FILTER (WHERE p.account = $5), 0)
+			  FROM ledger_postings p JOIN ledger_entries e ON e.id = p.entry_id
+			  WHERE e.payment_id = $1 AND e.kind IN ($2, $3) AND p.currency = $6`
+	err = tx.QueryRow(ctx, query, payment.ID, models.EntryPayout, models.EntryPayoutReversal, models.ProviderClearingAccount(payment.Provider), models.FeesAccount, paid.Currency).
+		Scan(&paid.MinorUnits, &fee.MinorUnits)
+	if err != nil {
+		return models.Money{}, models.Money{}, fmt.Errorf("error fetching paid out amount: %w", err)
+	}
+	return paid, fee, nil
+}
+
+// postLedgerEntry запись проводки в транзакции. Баланс проверяется здесь и еще раз триггером на коммите
+func postLedgerEntry(ctx context.Context, tx pgx.Tx, entry *models.LedgerEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	entry.ID = uuid.New().String()
+	var refundID *string
+	if entry.RefundID != "" {
+		refundID = &entry.RefundID
+	}
+	query := `INSERT INTO ledger_entries (id, kind, payment_id, refund_id) VALUES ($1, $2, $3, $4) RETURNING created_at`
+	if err := tx.QueryRow(ctx, query, entry.ID, entry.Kind, entry.PaymentID, refundID).Scan(&entry.CreatedAt); err != nil {
+		return fmt.Errorf("error saving ledger entry: %w", err)
+	}
+
+	for _, posting := range entry.Postings {
+		query := `INSERT INTO ledger_accounts (code, kind) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		if _, err := tx.Exec(ctx, query, posting.Account, models.AccountKind(posting.Account)); err != nil {
+			return fmt.Errorf("error saving ledger account: %w", err)
+		}
+		query = `INSERT INTO ledger_postings (entry_id, account, amount_minor, currency) VALUES ($1, $2, $3, $4)`
+		if _, err := tx.Exec(ctx, query, entry.ID, posting.Account, posting.Amount.MinorUnits, posting.Amount.Currency); err != nil {
+			return fmt.Errorf("error saving ledger posting: %w", err)
+		}
+	}
+	return nil
+}
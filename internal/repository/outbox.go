@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// OutboxRepository доменные события, ожидающие публикации в брокер
+type OutboxRepository interface {
+	// PublishOutbox выдача до limit неопубликованных событий по порядку записи.
+	// Событие помечается опубликованным только после успешного publish, на первой ошибке пачка
+	// прерывается, чтобы не нарушить порядок. Пачка заблокирована, другие экземпляры берут следующие события.
+	PublishOutbox(ctx context.Context, limit int, publish func(ctx context.Context, event *models.OutboxEvent) error) (int, error)
+	// DeletePublished удаление событий, опубликованных раньше before
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewOutboxRepository создание outbox в постгресе
+func NewOutboxRepository(db *pgxpool.Pool, logger *zap.Logger) OutboxRepository {
+	return &outboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *outboxRepository) PublishOutbox(ctx context.Context, limit int, publish func(ctx context.Context, event *models.OutboxEvent) error) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT seq, id, event_type, payment_id, payload, created_at FROM outbox_events
+			  WHERE published_at IS NULL ORDER BY seq LIMIT $1 FOR UPDATE SKIP LOCKED`
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		r.logger.Error("Failed to fetch outbox events", zap.Error(err))
+		return 0, fmt.Errorf("error fetching outbox events: %w", err)
+	}
+	var seqs []int64
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		var seq int64
+		var event models.OutboxEvent
+		if err := rows.Scan(&seq, &event.ID, &event.Type, &event.PaymentID, &event.Payload, &event.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning outbox event: %w", err)
+		}
+		seqs = append(seqs, seq)
+		events = append(events, &event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	published := 0
+	var publishErr error
+	for i, event := range events {
+		if publishErr = publish(ctx, event); publishErr != nil {
+			_, err := tx.Exec(ctx, `UPDATE outbox_events SET attempts = attempts + 1, last_error = $1 WHERE seq = $2`, publishErr.Error(), seqs[i])
+			if err != nil {
+				return 0, fmt.Errorf("error saving outbox error: %w", err)
+			}
+			break
+		}
+		published++
+	}
+	if published > 0 {
+		_, err := tx.Exec(ctx, `UPDATE outbox_events SET published_at = NOW() WHERE seq = ANY($1)`, seqs[:published])
+		if err != nil {
+			return 0, fmt.Errorf("error marking outbox events published: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil { // события уже в брокере, после повтора потребитель отбросит дубли по id
+		return 0, fmt.Errorf("error committing outbox: %w", err)
+	}
+	return published, publishErr
+}
+
+func (r *outboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM outbox_events WHERE published_at < $1`, before)
+	if err != nil {
+		r.logger.Error("Failed to delete published outbox events", zap.Error(err))
+		return 0, fmt.Errorf("error deleting outbox events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// insertOutboxEvent запись доменного события в outbox и доставок вебхуков в транзакции изменения платежа
+func insertOutboxEvent(ctx context.Context, tx pgx.Tx, eventType, paymentID string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox event: %w", err)
+	}
+	event := &models.OutboxEvent{ID: uuid.New().String(), Type: eventType, PaymentID: paymentID, Payload: payload}
+	query := `INSERT INTO outbox_events (id, event_type, payment_id, payload) VALUES ($1, $2, $3, $4) RETURNING created_at`
+	if err := tx.QueryRow(ctx, query, event.ID, event.Type, event.PaymentID, event.Payload).Scan(&event.CreatedAt); err != nil {
+		return fmt.Errorf("error saving outbox event: %w", err)
+	}
+	return insertWebhookDeliveries(ctx, tx, event)
+}
+
+// insertPaymentOutboxEvent событие смены статуса со снимком платежа после смены
+func insertPaymentOutboxEvent(ctx context.Context, tx pgx.Tx, event *models.PaymentEvent, payment *models.Payment) error {
+	return insertOutboxEvent(ctx, tx, models.PaymentEventType(event.OldStatus, event.NewStatus), event.PaymentID, models.PaymentEventData{
+		Payment:   payment,
+		OldStatus: event.OldStatus,
+		Actor:     event.Actor,
+		Reason:    event.Reason,
+	})
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// ReconciliationRepository выборки для сверки с историей шлюза и отчеты сверки
+type ReconciliationRepository interface {
+	// GetPaymentsByIDs платежи по меткам операций, отсутствующие id пропускаются
+	GetPaymentsByIDs(ctx context.Context, paymentIDs []string) ([]*models.Payment, error)
+	// GetCompletedPayments платежи шлюза provider, закрытые (COMPLETE) в окне [from, to)
+	GetCompletedPayments(ctx context.Context, provider string, from, to time.Time) ([]*models.Payment, error)
+	// GetRefundIDs какие из refundIDs есть среди возвратов
+	GetRefundIDs(ctx context.Context, refundIDs []string) ([]string, error)
+	// GetRefundedAmounts сумма незавершившихся ошибкой возвратов по платежам в минорных единицах валюты платежа
+	GetRefundedAmounts(ctx context.Context, paymentIDs []string) (map[string]int64, error)
+	// SaveReconciliationReport сохранение отчета, заполняет ID и CreatedAt
+	SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error
+	GetReconciliationReport(ctx context.Context, reportID string) (*models.ReconciliationReport, error)
+	ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error)
+}
+
+// reconciliationColumns колонки отчета в порядке сканирования scanReconciliationReport
+const reconciliationColumns = `id, provider, window_from, window_to, operations, auto_fix, mismatches, created_at`
+
+type reconciliationRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewReconciliationRepository создание хранилища сверок в постгресе
+func NewReconciliationRepository(db *pgxpool.Pool, logger *zap.Logger) ReconciliationRepository {
+	return &reconciliationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *reconciliationRepository) GetPaymentsByIDs(ctx context.Context, paymentIDs []string) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + `
+			  FROM payments WHERE id = ANY($1::uuid[])`
+	return r.queryPayments(ctx, query, paymentIDs)
+}
+
+func (r *reconciliationRepository) GetCompletedPayments(ctx context.Context, provider string, from, to time.Time) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + `
+			  FROM payments WHERE status = 'COMPLETE' AND provider = $1 AND updated_at >= $2 AND updated_at < $3`
+	return r.queryPayments(ctx, query, provider, from, to)
+}
+
+func (r *reconciliationRepository) queryPayments(ctx context.Context, query string, args ...any) ([]*models.Payment, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to fetch payments for reconciliation", zap.Error(err))
+		return nil, fmt.Errorf("error fetching payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r *reconciliationRepository) GetRefundIDs(ctx context.Context, refundIDs []string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM refunds WHERE id = ANY($1::uuid[])`, refundIDs)
+	if err != nil {
+		r.logger.Error("Failed to fetch refunds for reconciliation", zap.Error(err))
+		return nil, fmt.Errorf("error fetching refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning refund: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (r *reconciliationRepository) GetRefundedAmounts(ctx context.Context, paymentIDs []string) (map[string]int64, error) {
+	query := `SELECT payment_id, SUM(amount_minor) FROM refunds
+			  WHERE payment_id = ANY($1::uuid[]) AND status <> $2 GROUP BY payment_id`
+	rows, err := r.db.Query(ctx, query, paymentIDs, models.RefundStatusFailed)
+	if err != nil {
+		r.logger.Error("Failed to sum refunds for reconciliation", zap.Error(err))
+		return nil, fmt.Errorf("error summing refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunded := make(map[string]int64)
+	for rows.Next() {
+		var paymentID string
+		var amount int64
+		if err := rows.Scan(&paymentID, &amount); err != nil {
+			return nil, fmt.Errorf("error scanning refund sum: %w", err)
+		}
+		refunded[paymentID] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return refunded, nil
+}
+
+func (r *reconciliationRepository) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	mismatches, err := json.Marshal(report.Mismatches)
+	if err != nil {
+		return fmt.Errorf("error encoding mismatches: %w", err)
+	}
+
+	query := `INSERT INTO reconciliation_reports (id, provider, window_from, window_to, operations, auto_fix, mismatches)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  RETURNING id, created_at`
+	err = r.db.QueryRow(ctx, query, uuid.New().String(), report.Provider, report.From, report.To, report.Operations, report.AutoFix, mismatches).
+		Scan(&report.ID, &report.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to save reconciliation report", zap.String("provider", report.Provider), zap.Error(err))
+		return fmt.Errorf("error saving reconciliation report: %w", err)
+	}
+	return nil
+}
+
+func (r *reconciliationRepository) GetReconciliationReport(ctx context.Context, reportID string) (*models.ReconciliationReport, error) {
+	query := `SELECT ` + reconciliationColumns + ` FROM reconciliation_reports WHERE id = $1`
+	report, err := scanReconciliationReport(r.db.QueryRow(ctx, query, reportID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReconciliationReportNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch reconciliation report", zap.String("report_id", reportID), zap.Error(err))
+		return nil, fmt.Errorf("error fetching reconciliation report: %w", err)
+	}
+	return report, nil
+}
+
+func (r *reconciliationRepository) ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	query := `SELECT ` + reconciliationColumns + ` FROM reconciliation_reports ORDER BY created_at DESC, id LIMIT $1`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		r.logger.Error("Failed to fetch reconciliation reports", zap.Error(err))
+		return nil, fmt.Errorf("error fetching reconciliation reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*models.ReconciliationReport
+	for rows.Next() {
+		report, err := scanReconciliationReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning reconciliation report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return reports, nil
+}
+
+// scanReconciliationReport сканирование строки с колонками reconciliationColumns
+func scanReconciliationReport(row pgx.Row) (*models.ReconciliationReport, error) {
+	var report models.ReconciliationReport
+	var mismatches []byte
+	err := row.Scan(&report.ID, &report.Provider, &report.From, &report.To, &report.Operations, &report.AutoFix, &mismatches, &report.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(mismatches, &report.Mismatches); err != nil {
+		return nil, fmt.Errorf("error decoding mismatches: %w", err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// RefundRepository хранилище возвратов по платежам
+type RefundRepository interface {
+	// CreateRefund резервирует возврат в статусе PENDING. Нулевая сумма означает весь остаток платежа
+	CreateRefund(ctx context.Context, paymentID string, amount models.Money, reason, initiatorID string) (*models.Refund, error)
+	// CompleteRefund завершение возврата. После полного возврата платеж переходит в REFUNDED,
+	// и тогда вместе с возвратом возвращается событие смены статуса
+	CompleteRefund(ctx context.Context, refundID string, status models.RefundStatus, providerRef string) (*models.Refund, *models.PaymentEvent, error)
+	ListRefunds(ctx context.Context, paymentID string) ([]*models.Refund, error)
+	// RefundedAmount сумма возвратов платежа, кроме неудавшихся
+	RefundedAmount(ctx context.Context, paymentID string) (models.Money, error)
+	// ListPendingRefunds возвраты в статусе PENDING, созданные раньше createdBefore, сначала старые
+	ListPendingRefunds(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Refund, error)
+}
+
+// refundColumns колонки возврата в порядке сканирования scanRefund
+const refundColumns = `id, payment_id, amount_minor, currency, reason, status, initiator_id, provider_reference, created_at, updated_at`
+
+type refundRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+	cache  PaymentCacheInvalidator
+}
+
+// NewRefundRepository создание хранилища возвратов, cache сбрасывается при переходе платежа в REFUNDED
+func NewRefundRepository(db *pgxpool.Pool, logger *zap.Logger, cache PaymentCacheInvalidator) RefundRepository {
+	return &refundRepository{
+		db:     db,
+		logger: logger,
+		cache:  cache,
+	}
+}
+
+func (r *refundRepository) CreateRefund(ctx context.Context, paymentID string, amount models.Money, reason, initiatorID string) (*models.Refund, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// блокируем платеж, чтобы параллельные возвраты не превысили его сумму
+	var captured models.Money
+	var status models.PaymentStatus
+	query := `SELECT amount_minor, currency, status FROM payments WHERE id = $1 FOR UPDATE`
+	err = tx.QueryRow(ctx, query, paymentID).Scan(&captured.MinorUnits, &captured.Currency, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching payment: %w", err)
+	}
+	if !status.IsRefundable() {
+		return nil, &models.TransitionError{From: status, To: models.StatusRefunded}
+	}
+
+	refunded, err := sumRefunds(ctx, tx, paymentID, captured.Currency)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := captured.Sub(refunded)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount.IsZero() {
+		amount = remaining
+	}
+	if amount.Currency != captured.Currency {
+		return nil, fmt.Errorf("%w: refund in %s for payment in %s", models.ErrCurrencyMismatch, amount.Currency, captured.Currency)
+	}
+	if !amount.IsPositive() || amount.MinorUnits > remaining.MinorUnits {
+		return nil, fmt.Errorf("%w: requested %s, refundable %s %s", models.ErrRefundExceedsPayment, amount, remaining, remaining.Currency)
+	}
+
+	query = `INSERT INTO refunds (id, payment_id, amount_minor, currency, reason, status, initiator_id)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + refundColumns
+	refund, err := scanRefund(tx.QueryRow(ctx, query, uuid.New().String(), paymentID, amount.MinorUnits, amount.Currency, reason, models.RefundStatusPending, initiatorID))
+	if err != nil {
+		r.logger.Error("Failed to create refund", zap.String("payment_id", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("error creating refund: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing refund: %w", err)
+	}
+
+	r.logger.Info("Refund created", zap.String("refund_id", refund.ID), zap.String("payment_id", paymentID), zap.Stringer("amount", amount))
+	return refund, nil
+}
+
+func (r *refundRepository) CompleteRefund(ctx context.Context, refundID string, status models.RefundStatus, providerRef string) (*models.Refund, *models.PaymentEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE refunds SET status = $1, provider_reference = $2, updated_at = $3
+			  WHERE id = $4 AND status = $5 RETURNING ` + refundColumns
+	refund, err := scanRefund(tx.QueryRow(ctx, query, status, providerRef, time.Now(), refundID, models.RefundStatusPending))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, fmt.Errorf("refund %s is not pending", refundID)
+	}
+	if err != nil {
+		r.logger.Error("Failed to complete refund", zap.String("refund_id", refundID), zap.Error(err))
+		return nil, nil, fmt.Errorf("error completing refund: %w", err)
+	}
+
+	if err := insertOutboxEvent(ctx, tx, models.RefundEventType(status), refund.PaymentID, models.RefundEventData{Refund: refund}); err != nil {
+		return nil, nil, err
+	}
+
+	var event *models.PaymentEvent
+	if status == models.RefundStatusSucceeded {
+		if err := postRefundLedger(ctx, tx, refund); err != nil {
+			return nil, nil, err
+		}
+
+		// платеж считается возвращенным, когда успешные возвраты покрыли всю сумму
+		query = `UPDATE payments SET status = $1, updated_at = $2
+				  WHERE id = $3 AND status IN ($4, $5)
+				  AND amount_minor <= (SELECT COALESCE(SUM(amount_minor), 0) FROM refunds WHERE payment_id = $3 AND status = $6)
+				  RETURNING (SELECT status FROM payments WHERE id = $3)`
+		var oldStatus models.PaymentStatus
+		err := tx.QueryRow(ctx, query, models.StatusRefunded, time.Now(), refund.PaymentID, models.StatusSuccess, models.StatusComplete, models.RefundStatusSucceeded).Scan(&oldStatus)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows): // возвращена только часть суммы
+		case err != nil:
+			return nil, nil, fmt.Errorf("error updating payment status: %w", err)
+		default:
+			event, err = insertPaymentEvent(ctx, tx, refund.PaymentID, oldStatus, models.StatusChange{
+				Status:           models.StatusRefunded,
+				Actor:            models.ActorRefund,
+				Reason:           fmt.Sprintf("fully refunded by refund %s", refund.ID),
+				ProviderResponse: providerRef,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			r.logger.Info("Payment fully refunded", zap.String("payment_id", refund.PaymentID))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("error committing refund: %w", err)
+	}
+	if event != nil {
+		metrics.StatusChanged(string(event.OldStatus), string(event.NewStatus), refund.Amount.Currency)
+		r.cache.InvalidatePayment(ctx, refund.PaymentID)
+	}
+
+	r.logger.Info("Refund completed", zap.String("refund_id", refundID), zap.String("status", string(status)))
+	return refund, event, nil
+}
+
+func (r *refundRepository) ListRefunds(ctx context.Context, paymentID string) ([]*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE payment_id = $1 ORDER BY created_at`
+	rows, err := r.db.Query(ctx, query, paymentID)
+	if err != nil {
+		r.logger.Error("Failed to fetch refunds", zap.String("payment_id", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("error fetching refunds: %w", err)
+	}
+	return collectRefunds(rows)
+}
+
+func (r *refundRepository) ListPendingRefunds(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE status = $1 AND created_at < $2 ORDER BY created_at LIMIT $3`
+	rows, err := r.db.Query(ctx, query, models.RefundStatusPending, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to fetch pending refunds", zap.Error(err))
+		return nil, fmt.Errorf("error fetching pending refunds: %w", err)
+	}
+	return collectRefunds(rows)
+}
+
+// collectRefunds чтение всех строк с колонками refundColumns
+func collectRefunds(rows pgx.Rows) ([]*models.Refund, error) {
+	defer rows.Close()
+
+	var refunds []*models.Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning refund: %w", err)
+		}
+		refunds = append(refunds, refund)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return refunds, nil
+}
+
+func (r *refundRepository) RefundedAmount(ctx context.Context, paymentID string) (models.Money, error) {
+	var currency string
+	err := r.db.QueryRow(ctx, `SELECT currency FROM payments WHERE id = $1`, paymentID).Scan(&currency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Money{}, ErrPaymentNotFound
+	}
+	if err != nil {
+		return models.Money{}, fmt.Errorf("error fetching payment: %w", err)
+	}
+	return sumRefunds(ctx, r.db, paymentID, currency)
+}
+
+// querier общий интерфейс пула и транзакции
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// sumRefunds сумма возвратов, которые прошли или еще проводятся
+func sumRefunds(ctx context.Context, q querier, paymentID, currency string) (models.Money, error) {
+	sum := models.Money{Currency: currency}
+	query := `SELECT COALESCE(SUM(amount_minor), 0) FROM refunds WHERE payment_id = $1 AND status <> $2`
+	if err := q.QueryRow(ctx, query, paymentID, models.RefundStatusFailed).Scan(&sum.MinorUnits); err != nil {
+		return models.Money{}, fmt.Errorf("error summing refunds: %w", err)
+	}
+	return sum, nil
+}
+
+// scanRefund сканирование строки с колонками refundColumns
+func scanRefund(row pgx.Row) (*models.Refund, error) {
+	var refund models.Refund
+	err := row.Scan(
+		&refund.ID,
+		&refund.PaymentID,
+		&refund.Amount.MinorUnits,
+		&refund.Amount.Currency,
+		&refund.Reason,
+		&refund.Status,
+		&refund.InitiatorID,
+		&refund.ProviderRef,
+		&refund.CreatedAt,
+		&refund.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
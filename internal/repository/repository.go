@@ -2,190 +2,249 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
 	"go.uber.org/zap"
 )
 
 type PaymentRepository interface {
-	CreatePayment(ctx context.Context, fromUserID, toUserID string, amount float64, currency string) (string, error)
+	CreatePayment(ctx context.Context, fromUserID, toUserID string, amount, fee models.Money, provider string) (string, error)
 	GetPaymentByID(ctx context.Context, paymentID string) (*models.Payment, error)
-	GetPaymentHistory(ctx context.Context, userID string, page, limit int) ([]*models.Payment, error)
-	UpdatePaymentStatus(ctx context.Context, paymentID string, status models.PaymentStatus) error
-	GetPaymentDetails(ctx context.Context, paymentID string) (float64, string, error)
+	GetPaymentHistory(ctx context.Context, query models.HistoryQuery) (*models.HistoryPage, error)
+	UpdatePaymentStatus(ctx context.Context, paymentID string, change models.StatusChange) (*models.PaymentEvent, error)
+	GetPaymentDetails(ctx context.Context, paymentID string) (models.Money, error)
 	GetActivePayments(ctx context.Context, userID string) ([]*models.Payment, error)
+	GetPaymentEvents(ctx context.Context, paymentID string) ([]*models.PaymentEvent, error)
+	// GetExpiredPayments неоплаченные платежи, созданные раньше createdBefore, от старых к новым
+	GetExpiredPayments(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Payment, error)
 }
 
+// paymentColumns колонки платежа в порядке сканирования scanPayment
+const paymentColumns = `id, from_user_id, to_user_id, amount_minor, currency, fee_minor, status, provider, created_at, updated_at`
+
 type paymentRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
-	redis  *redis.Client
 }
 
-func NewPaymentRepository(db *pgxpool.Pool, logger *zap.Logger, redis *redis.Client) PaymentRepository {
+// NewPaymentRepository создание репозитория в постгресе, кэширование добавляет NewCachedPaymentRepository
+func NewPaymentRepository(db *pgxpool.Pool, logger *zap.Logger) PaymentRepository {
 	return &paymentRepository{
 		db:     db,
 		logger: logger,
-		redis:  redis,
 	}
 }
 
 func (r *paymentRepository) GetPaymentByID(ctx context.Context, paymentID string) (*models.Payment, error) {
-	cacheKey := fmt.Sprintf("payment:%s", paymentID)
-
-	cachedPayment, err := r.redis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var payment models.Payment
-		if err := json.Unmarshal([]byte(cachedPayment), &payment); err == nil {
-			return &payment, nil
-		}
-	}
-
-	query := `SELECT id, from_user_id, to_user_id, amount, currency, status, created_at, updated_at 
+	query := `SELECT ` + paymentColumns + `
 			  FROM payments WHERE id = $1`
-	var payment models.Payment
-	err = r.db.QueryRow(ctx, query, paymentID).Scan(
-		&payment.ID,
-		&payment.FromUserID,
-		&payment.ToUserID,
-		&payment.Amount,
-		&payment.Currency,
-		&payment.Status,
-		&payment.CreatedAt,
-		&payment.UpdatedAt,
-	)
+	payment, err := scanPayment(r.db.QueryRow(ctx, query, paymentID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPaymentNotFound
+	}
 	if err != nil {
 		r.logger.Error("Failed to fetch payment by ID", zap.String("payment_id", paymentID), zap.Error(err))
 		return nil, fmt.Errorf("error fetching payment by ID: %w", err)
 	}
 
-	data, err := json.Marshal(payment)
-	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
-	}
-
-	return &payment, nil
+	return payment, nil
 }
 
-func (r *paymentRepository) GetPaymentHistory(ctx context.Context, userID string, page, limit int) ([]*models.Payment, error) {
-	cacheKey := fmt.Sprintf("payment_history:%s:%d:%d", userID, page, limit)
-
-	cachedHistory, err := r.redis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var payments []*models.Payment
-		if err := json.Unmarshal([]byte(cachedHistory), &payments); err == nil {
-			return payments, nil
+// GetPaymentHistory страница истории по курсору: платежи отсортированы по (created_at, id) от новых к старым,
+// следующая страница начинается после последнего платежа предыдущей, поэтому новые платежи не сдвигают страницы
+func (r *paymentRepository) GetPaymentHistory(ctx context.Context, query models.HistoryQuery) (*models.HistoryPage, error) {
+	where, args := historyConditions(query)
+	total := int64(0)
+	if query.WithTotal {
+		err := r.db.QueryRow(ctx, `SELECT count(*) FROM payments WHERE `+strings.Join(where, " AND "), args...).Scan(&total)
+		if err != nil {
+			r.logger.Error("Failed to count payment history", zap.String("user_id", query.UserID), zap.Error(err))
+			return nil, fmt.Errorf("error counting payment history: %w", err)
 		}
 	}
 
-	offset := (page - 1) * limit
-	query := `SELECT id, from_user_id, to_user_id, amount, currency, status, created_at, updated_at 
-			  FROM payments WHERE from_user_id = $1 LIMIT $2 OFFSET $3`
-	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.ID)
+		where = append(where, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, query.Limit+1, query.Offset()) // лишняя строка показывает, есть ли следующая страница
+	sql := `SELECT ` + paymentColumns + `
+			FROM payments WHERE ` + strings.Join(where, " AND ") + fmt.Sprintf(`
+			ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := r.db.Query(ctx, sql, args...)
 	if err != nil {
-		r.logger.Error("Failed to fetch payment history", zap.String("user_id", userID), zap.Error(err))
+		r.logger.Error("Failed to fetch payment history", zap.String("user_id", query.UserID), zap.Error(err))
 		return nil, fmt.Errorf("error fetching payment history: %w", err)
 	}
 	defer rows.Close()
 
-	var payments []*models.Payment
+	page := &models.HistoryPage{Total: total}
 	for rows.Next() {
-		var payment models.Payment
-		if err := rows.Scan(
-			&payment.ID,
-			&payment.FromUserID,
-			&payment.ToUserID,
-			&payment.Amount,
-			&payment.Currency,
-			&payment.Status,
-			&payment.CreatedAt,
-			&payment.UpdatedAt,
-		); err != nil {
+		payment, err := scanPayment(rows)
+		if err != nil {
 			r.logger.Error("Failed to scan payment history row", zap.Error(err))
 			return nil, fmt.Errorf("error scanning payment history: %w", err)
 		}
-		payments = append(payments, &payment)
+		page.Payments = append(page.Payments, payment)
 	}
 
-	data, err := json.Marshal(payments)
-	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	return payments, nil
+	if len(page.Payments) > query.Limit {
+		page.Payments = page.Payments[:query.Limit]
+		last := page.Payments[len(page.Payments)-1]
+		page.NextCursor = &models.HistoryCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return page, nil
 }
 
-func (r *paymentRepository) GetPaymentDetails(ctx context.Context, paymentID string) (float64, string, error) {
-	cacheKey := fmt.Sprintf("payment_details:%s", paymentID)
+// historyConditions условия выборки истории по фильтрам запроса, без курсора
+func historyConditions(query models.HistoryQuery) ([]string, []any) {
+	args := []any{query.UserID}
+	var where []string
+	switch query.Direction {
+	case models.DirectionOutgoing:
+		where = append(where, "from_user_id = $1")
+	case models.DirectionIncoming:
+		where = append(where, "to_user_id = $1")
+	default:
+		where = append(where, "(from_user_id = $1 OR to_user_id = $1)")
+	}
 
-	cachedDetails, err := r.redis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var details struct {
-			Amount   float64 `json:"amount"`
-			Currency string  `json:"currency"`
-		}
-		if err := json.Unmarshal([]byte(cachedDetails), &details); err == nil {
-			return details.Amount, details.Currency, nil
+	arg := func(condition string, value any) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	if len(query.Statuses) > 0 {
+		statuses := make([]string, 0, len(query.Statuses))
+		for _, status := range query.Statuses {
+			statuses = append(statuses, string(status))
 		}
+		arg("status = ANY($%d)", statuses)
+	}
+	if query.Currency != "" {
+		arg("currency = $%d", query.Currency)
+	}
+	if query.MinAmount > 0 {
+		arg("amount_minor >= $%d", query.MinAmount)
+	}
+	if query.MaxAmount > 0 {
+		arg("amount_minor <= $%d", query.MaxAmount)
 	}
+	if !query.From.IsZero() {
+		arg("created_at >= $%d", query.From)
+	}
+	if !query.To.IsZero() {
+		arg("created_at < $%d", query.To)
+	}
+	return where, args
+}
 
-	query := `SELECT amount, currency FROM payments WHERE id = $1`
-	var amount float64
-	var currency string
-	err = r.db.QueryRow(ctx, query, paymentID).Scan(&amount, &currency)
+func (r *paymentRepository) GetPaymentDetails(ctx context.Context, paymentID string) (models.Money, error) {
+	query := `SELECT amount_minor, currency FROM payments WHERE id = $1`
+	var amount models.Money
+	err := r.db.QueryRow(ctx, query, paymentID).Scan(&amount.MinorUnits, &amount.Currency)
 	if err != nil {
 		r.logger.Error("Failed to fetch payment details", zap.String("payment_id", paymentID), zap.Error(err))
-		return 0, "", fmt.Errorf("error fetching payment details: %w", err)
-	}
-
-	details := struct {
-		Amount   float64 `json:"amount"`
-		Currency string  `json:"currency"`
-	}{Amount: amount, Currency: currency}
-	data, err := json.Marshal(details)
-	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
+		return models.Money{}, fmt.Errorf("error fetching payment details: %w", err)
 	}
 
-	return amount, currency, nil
+	return amount, nil
 }
 
-func (r *paymentRepository) CreatePayment(ctx context.Context, fromUserID, toUserID string, amount float64, currency string) (string, error) {
+func (r *paymentRepository) CreatePayment(ctx context.Context, fromUserID, toUserID string, amount, fee models.Money, provider string) (string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	id := uuid.New().String()
-	query := `INSERT INTO payments (id, from_user_id, to_user_id, amount, currency, status) 
-			  VALUES ($1, $2, $3, $4, $5, 'PENDING') RETURNING id`
+	query := `INSERT INTO payments (id, from_user_id, to_user_id, amount_minor, currency, fee_minor, status, provider) 
+			  VALUES ($1, $2, $3, $4, $5, $6, 'PENDING', $7) RETURNING id`
 
 	var paymentID string
-	err := r.db.QueryRow(ctx, query, id, fromUserID, toUserID, amount, currency).Scan(&paymentID)
+	err = tx.QueryRow(ctx, query, id, fromUserID, toUserID, amount.MinorUnits, amount.Currency, fee.MinorUnits, provider).Scan(&paymentID)
 	if err != nil {
 		r.logger.Error("Failed to create payment", zap.Error(err))
 		return "", fmt.Errorf("error creating payment: %w", err)
 	}
 
+	// история платежа начинается с его создания
+	_, err = insertPaymentEvent(ctx, tx, paymentID, "", models.StatusChange{Status: models.StatusPending, Actor: models.UserActor(fromUserID), Reason: "payment created"})
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("error committing payment: %w", err)
+	}
+	metrics.PaymentCreated(amount.Currency, provider)
+
 	r.logger.Info("Payment created", zap.String("payment_id", paymentID))
 	return paymentID, nil
 }
 
-func (r *paymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, status models.PaymentStatus) error {
-	query := `UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3`
-	_, err := r.db.Exec(ctx, query, status, time.Now(), paymentID)
+// UpdatePaymentStatus смена статуса, только если текущий статус равен change.Expected и переход разрешен.
+// Каждая смена записывается в историю статусов платежа.
+func (r *paymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, change models.StatusChange) (*models.PaymentEvent, error) {
+	if err := models.ValidateTransition(change.Expected, change.Status); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		r.logger.Error("Failed to update payment status", zap.String("payment_id", paymentID), zap.String("status", string(status)), zap.Error(err))
-		return fmt.Errorf("error updating payment status: %w", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4 RETURNING currency`
+	var currency string
+	err = tx.QueryRow(ctx, query, change.Status, time.Now(), paymentID, change.Expected).Scan(&currency)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		r.logger.Error("Failed to update payment status", zap.String("payment_id", paymentID), zap.String("status", string(change.Status)), zap.Error(err))
+		return nil, fmt.Errorf("error updating payment status: %w", err)
+	}
+
+	if err != nil { // статус успели поменять, либо платежа нет
+		var actual models.PaymentStatus
+		err := tx.QueryRow(ctx, `SELECT status FROM payments WHERE id = $1`, paymentID).Scan(&actual)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error fetching payment status: %w", err)
+		}
+		r.logger.Warn("Payment status conflict", zap.String("payment_id", paymentID), zap.String("expected", string(change.Expected)), zap.String("actual", string(actual)), zap.String("status", string(change.Status)))
+		return nil, &StatusConflictError{PaymentID: paymentID, Expected: change.Expected, Actual: actual}
 	}
 
-	r.logger.Info("Payment status updated", zap.String("payment_id", paymentID), zap.String("status", string(status)))
-	return nil
+	event, err := insertPaymentEvent(ctx, tx, paymentID, change.Expected, change)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing payment status: %w", err)
+	}
+	metrics.StatusChanged(string(change.Expected), string(change.Status), currency)
+
+	r.logger.Info("Payment status updated", zap.String("payment_id", paymentID), zap.String("from", string(change.Expected)), zap.String("status", string(change.Status)), zap.String("actor", change.Actor))
+	return event, nil
 }
 
 func (r *paymentRepository) GetActivePayments(ctx context.Context, userID string) ([]*models.Payment, error) {
-	query := `SELECT id, from_user_id, to_user_id, amount, currency, status, created_at, updated_at 
+	query := `SELECT ` + paymentColumns + `
 			  FROM payments WHERE from_user_id = $1 AND status = 'PENDING' OR from_user_id = $1 AND status = 'FAILED'`
 
 	rows, err := r.db.Query(ctx, query, userID)
@@ -197,21 +256,12 @@ func (r *paymentRepository) GetActivePayments(ctx context.Context, userID string
 
 	var payments []*models.Payment
 	for rows.Next() {
-		var payment models.Payment
-		if err := rows.Scan(
-			&payment.ID,
-			&payment.FromUserID,
-			&payment.ToUserID,
-			&payment.Amount,
-			&payment.Currency,
-			&payment.Status,
-			&payment.CreatedAt,
-			&payment.UpdatedAt,
-		); err != nil {
+		payment, err := scanPayment(rows)
+		if err != nil {
 			r.logger.Error("Failed to scan payment history row", zap.Error(err))
 			return nil, fmt.Errorf("error scanning payment history: %w", err)
 		}
-		payments = append(payments, &payment)
+		payments = append(payments, payment)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -221,3 +271,105 @@ func (r *paymentRepository) GetActivePayments(ctx context.Context, userID string
 
 	return payments, nil
 }
+
+func (r *paymentRepository) GetExpiredPayments(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + `
+			  FROM payments WHERE status IN ('PENDING', 'FAILED') AND created_at < $1 ORDER BY created_at LIMIT $2`
+	rows, err := r.db.Query(ctx, query, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to fetch expired payments", zap.Error(err))
+		return nil, fmt.Errorf("error fetching expired payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning expired payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r *paymentRepository) GetPaymentEvents(ctx context.Context, paymentID string) ([]*models.PaymentEvent, error) {
+	query := `SELECT id, payment_id, old_status, new_status, actor, reason, provider_response, created_at
+			  FROM payment_events WHERE payment_id = $1 ORDER BY id`
+	rows, err := r.db.Query(ctx, query, paymentID)
+	if err != nil {
+		r.logger.Error("Failed to fetch payment events", zap.String("payment_id", paymentID), zap.Error(err))
+		return nil, fmt.Errorf("error fetching payment events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.PaymentEvent
+	for rows.Next() {
+		var event models.PaymentEvent
+		err := rows.Scan(&event.ID, &event.PaymentID, &event.OldStatus, &event.NewStatus, &event.Actor, &event.Reason, &event.ProviderResponse, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// insertPaymentEvent запись смены статуса в историю и в outbox в той же транзакции, что и сама смена
+func insertPaymentEvent(ctx context.Context, tx pgx.Tx, paymentID string, oldStatus models.PaymentStatus, change models.StatusChange) (*models.PaymentEvent, error) {
+	event := &models.PaymentEvent{
+		PaymentID:        paymentID,
+		OldStatus:        oldStatus,
+		NewStatus:        change.Status,
+		Actor:            change.Actor,
+		Reason:           change.Reason,
+		ProviderResponse: models.ProviderResponseSnippet(change.ProviderResponse),
+	}
+	query := `INSERT INTO payment_events (payment_id, old_status, new_status, actor, reason, provider_response)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := tx.QueryRow(ctx, query, event.PaymentID, event.OldStatus, event.NewStatus, event.Actor, event.Reason, event.ProviderResponse).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error saving payment event: %w", err)
+	}
+	payment, err := scanPayment(tx.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, paymentID))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching payment snapshot: %w", err)
+	}
+	if err := insertPaymentOutboxEvent(ctx, tx, event, payment); err != nil { // другие сервисы узнают о смене статуса через брокер
+		return nil, err
+	}
+	if err := postPaymentLedger(ctx, tx, payment, oldStatus); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// scanPayment сканирование строки с колонками paymentColumns
+func scanPayment(row pgx.Row) (*models.Payment, error) {
+	var payment models.Payment
+	err := row.Scan(
+		&payment.ID,
+		&payment.FromUserID,
+		&payment.ToUserID,
+		&payment.Amount.MinorUnits,
+		&payment.Amount.Currency,
+		&payment.Fee.MinorUnits,
+		&payment.Status,
+		&payment.Provider,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	payment.Fee.Currency = payment.Amount.Currency
+	return &payment, nil
+}
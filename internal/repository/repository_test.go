@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+func TestHistoryConditions(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	where, args := historyConditions(models.HistoryQuery{
+		UserID:    "alice",
+		Direction: models.DirectionIncoming,
+		Statuses:  []models.PaymentStatus{models.StatusSuccess, models.StatusComplete},
+		Currency:  "RUB",
+		MinAmount: 100,
+		From:      from,
+	})
+
+	assert.Equal(t, []string{
+		"to_user_id = $1",
+		"status = ANY($2)",
+		"currency = $3",
+		"amount_minor >= $4",
+		"created_at >= $5",
+	}, where)
+	assert.Equal(t, []any{"alice", []string{"SUCCESS", "COMPLETE"}, "RUB", int64(100), from}, args)
+}
+
+func TestHistoryConditionsDirection(t *testing.T) {
+	where, args := historyConditions(models.HistoryQuery{UserID: "alice", Direction: models.DirectionBoth})
+	assert.Equal(t, []string{"(from_user_id = $1 OR to_user_id = $1)"}, where)
+	assert.Equal(t, []any{"alice"}, args)
+
+	where, _ = historyConditions(models.HistoryQuery{UserID: "alice", Direction: models.DirectionOutgoing})
+	assert.Equal(t, []string{"from_user_id = $1"}, where)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// RiskRepository история платежей для правил риска и журнал их решений
+type RiskRepository interface {
+	// PaymentStats число платежей пользователя, созданных после since, и их сумма в валюте currency
+	PaymentStats(ctx context.Context, userID, currency string, since time.Time) (int, int64, error)
+	// FirstPaidPayment время создания первого оплаченного платежа от fromUserID к toUserID, нулевое, если таких не было
+	FirstPaidPayment(ctx context.Context, fromUserID, toUserID string) (time.Time, error)
+	SaveRiskDecision(ctx context.Context, decision *models.RiskDecision) error
+}
+
+type riskRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewRiskRepository создание хранилища правил риска в постгресе
+func NewRiskRepository(db *pgxpool.Pool, logger *zap.Logger) RiskRepository {
+	return &riskRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *riskRepository) PaymentStats(ctx context.Context, userID, currency string, since time.Time) (int, int64, error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(amount_minor) FILTER (WHERE currency = $2), 0)
+			  FROM payments WHERE from_user_id = $1 AND created_at >= $3`
+	var count int
+	var amount int64
+	if err := r.db.QueryRow(ctx, query, userID, currency, since).Scan(&count, &amount); err != nil {
+		r.logger.Error("Failed to fetch payment stats", zap.String("user_id", userID), zap.Error(err))
+		return 0, 0, fmt.Errorf("error fetching payment stats: %w", err)
+	}
+	return count, amount, nil
+}
+
+func (r *riskRepository) FirstPaidPayment(ctx context.Context, fromUserID, toUserID string) (time.Time, error) {
+	query := `SELECT MIN(created_at) FROM payments WHERE from_user_id = $1 AND to_user_id = $2 AND status IN ($3, $4)`
+	var first *time.Time
+	if err := r.db.QueryRow(ctx, query, fromUserID, toUserID, models.StatusSuccess, models.StatusComplete).Scan(&first); err != nil {
+		r.logger.Error("Failed to fetch first paid payment", zap.String("from_user_id", fromUserID), zap.String("to_user_id", toUserID), zap.Error(err))
+		return time.Time{}, fmt.Errorf("error fetching first paid payment: %w", err)
+	}
+	if first == nil {
+		return time.Time{}, nil
+	}
+	return *first, nil
+}
+
+func (r *riskRepository) SaveRiskDecision(ctx context.Context, decision *models.RiskDecision) error {
+	var paymentID *string
+	if decision.PaymentID != "" {
+		paymentID = &decision.PaymentID
+	}
+	query := `INSERT INTO risk_decisions (id, from_user_id, to_user_id, amount_minor, currency, decision, rule, reason, payment_id, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := r.db.Exec(ctx, query, decision.ID, decision.FromUserID, decision.ToUserID, decision.Amount.MinorUnits, decision.Amount.Currency,
+		decision.Decision, decision.Rule, decision.Reason, paymentID, decision.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving risk decision: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// WebhookRepository адреса вебхуков интеграторов и доставки событий на них.
+// Доставки создаются вместе с событием outbox, в транзакции изменения платежа.
+type WebhookRepository interface {
+	CreateWebhookEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetWebhookEndpoint(ctx context.Context, endpointID string) (*models.WebhookEndpoint, error)
+	ListWebhookEndpoints(ctx context.Context, userID string) ([]*models.WebhookEndpoint, error)
+	// DeleteWebhookEndpoint удаление адреса вместе с его доставками
+	DeleteWebhookEndpoint(ctx context.Context, endpointID string) error
+	// ClaimWebhookDeliveries до limit доставок, которым пора отправляться. На время lease они
+	// откладываются, чтобы другие экземпляры их не взяли, и возвращаются с адресом и ключом подписи
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	// SaveWebhookAttempt результат попытки: статус, счетчик, ответ и время следующей попытки
+	SaveWebhookAttempt(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error)
+	// ListWebhookDeliveries доставки адреса, сначала новые, пустой status - все
+	ListWebhookDeliveries(ctx context.Context, endpointID string, status models.WebhookDeliveryStatus, limit int) ([]*models.WebhookDelivery, error)
+	// RedeliverWebhook возврат FAILED-доставки к отправке с обнуленным счетчиком попыток
+	RedeliverWebhook(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error)
+}
+
+// webhookEndpointColumns колонки адреса в порядке сканирования scanWebhookEndpoint
+const webhookEndpointColumns = `id, user_id, url, secret, event_types, created_at`
+
+// webhookDeliveryColumns колонки доставки в порядке сканирования scanWebhookDelivery
+const webhookDeliveryColumns = `id, endpoint_id, event_id, event_type, payment_id, payload, occurred_at, status,
+	attempts, last_status_code, last_error, next_attempt_at, delivered_at, created_at, updated_at`
+
+type webhookRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewWebhookRepository создание хранилища вебхуков в постгресе
+func NewWebhookRepository(db *pgxpool.Pool, logger *zap.Logger) WebhookRepository {
+	return &webhookRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *webhookRepository) CreateWebhookEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	if endpoint.EventTypes == nil {
+		endpoint.EventTypes = []string{}
+	}
+	query := `INSERT INTO webhook_endpoints (id, user_id, url, secret, event_types)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err := r.db.QueryRow(ctx, query, uuid.New().String(), endpoint.UserID, endpoint.URL, endpoint.Secret, endpoint.EventTypes).
+		Scan(&endpoint.ID, &endpoint.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create webhook endpoint", zap.String("user_id", endpoint.UserID), zap.Error(err))
+		return fmt.Errorf("error creating webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+func (r *webhookRepository) GetWebhookEndpoint(ctx context.Context, endpointID string) (*models.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE id = $1`
+	endpoint, err := scanWebhookEndpoint(r.db.QueryRow(ctx, query, endpointID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWebhookEndpointNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook endpoint", zap.String("endpoint_id", endpointID), zap.Error(err))
+		return nil, fmt.Errorf("error fetching webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+func (r *webhookRepository) ListWebhookEndpoints(ctx context.Context, userID string) ([]*models.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE user_id = $1 ORDER BY created_at`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook endpoints", zap.String("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("error fetching webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []*models.WebhookEndpoint
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+func (r *webhookRepository) DeleteWebhookEndpoint(ctx context.Context, endpointID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, endpointID)
+	if err != nil {
+		r.logger.Error("Failed to delete webhook endpoint", zap.String("endpoint_id", endpointID), zap.Error(err))
+		return fmt.Errorf("error deleting webhook endpoint: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookEndpointNotFound
+	}
+	return nil
+}
+
+func (r *webhookRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries d SET next_attempt_at = NOW() + $2 * interval '1 millisecond'
+			  FROM webhook_endpoints e
+			  WHERE e.id = d.endpoint_id AND d.id IN (
+				  SELECT id FROM webhook_deliveries WHERE status = 'PENDING' AND next_attempt_at <= NOW()
+				  ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+			  RETURNING d.id, d.endpoint_id, d.event_id, d.event_type, d.payment_id, d.payload, d.occurred_at, d.status,
+			  d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.delivered_at, d.created_at, d.updated_at,
+			  e.url, e.secret`
+	rows, err := r.db.Query(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		r.logger.Error("Failed to claim webhook deliveries", zap.Error(err))
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows, true)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookRepository) SaveWebhookAttempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = $2, last_status_code = $3, last_error = $4,
+			  next_attempt_at = $5, delivered_at = $6, updated_at = NOW() WHERE id = $7`
+	_, err := r.db.Exec(ctx, query, delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError,
+		delivery.NextAttemptAt, delivery.DeliveredAt, delivery.ID)
+	if err != nil {
+		r.logger.Error("Failed to save webhook attempt", zap.String("delivery_id", delivery.ID), zap.Error(err))
+		return fmt.Errorf("error saving webhook attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *webhookRepository) GetWebhookDelivery(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(ctx, query, deliveryID), false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook delivery", zap.String("delivery_id", deliveryID), zap.Error(err))
+		return nil, fmt.Errorf("error fetching webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func (r *webhookRepository) ListWebhookDeliveries(ctx context.Context, endpointID string, status models.WebhookDeliveryStatus, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+			  WHERE endpoint_id = $1 AND ($2 = '' OR status = $2) ORDER BY created_at DESC, id LIMIT $3`
+	rows, err := r.db.Query(ctx, query, endpointID, status, limit)
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook deliveries", zap.String("endpoint_id", endpointID), zap.Error(err))
+		return nil, fmt.Errorf("error fetching webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookRepository) RedeliverWebhook(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries SET status = 'PENDING', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+			  WHERE id = $1 AND status = 'FAILED' RETURNING ` + webhookDeliveryColumns
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(ctx, query, deliveryID), false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := r.GetWebhookDelivery(ctx, deliveryID); err != nil {
+			return nil, err
+		}
+		return nil, ErrWebhookDeliveryNotFailed
+	}
+	if err != nil {
+		r.logger.Error("Failed to redeliver webhook", zap.String("delivery_id", deliveryID), zap.Error(err))
+		return nil, fmt.Errorf("error redelivering webhook: %w", err)
+	}
+	return delivery, nil
+}
+
+// insertWebhookDeliveries доставки события на адреса владельцев платежа, подписанных на его тип
+func insertWebhookDeliveries(ctx context.Context, tx pgx.Tx, event *models.OutboxEvent) error {
+	query := `INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payment_id, payload, occurred_at)
+			  SELECT gen_random_uuid(), e.id, $1, $2, p.id, $4, $5
+			  FROM webhook_endpoints e JOIN payments p ON p.id = $3
+			  WHERE e.user_id IN (p.from_user_id, p.to_user_id) AND (cardinality(e.event_types) = 0 OR $2 = ANY(e.event_types))`
+	_, err := tx.Exec(ctx, query, event.ID, event.Type, event.PaymentID, event.Payload, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// scanWebhookEndpoint сканирование строки с колонками webhookEndpointColumns
+func scanWebhookEndpoint(row pgx.Row) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := row.Scan(&endpoint.ID, &endpoint.UserID, &endpoint.URL, &endpoint.Secret, &endpoint.EventTypes, &endpoint.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// scanWebhookDelivery сканирование строки с колонками webhookDeliveryColumns, withEndpoint - с адресом и ключом в конце
+func scanWebhookDelivery(row pgx.Row, withEndpoint bool) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	dest := []any{
+		&delivery.ID, &delivery.EndpointID, &delivery.Event.ID, &delivery.Event.Type, &delivery.Event.PaymentID,
+		&delivery.Event.Payload, &delivery.Event.CreatedAt, &delivery.Status, &delivery.Attempts, &delivery.LastStatusCode,
+		&delivery.LastError, &delivery.NextAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt, &delivery.UpdatedAt,
+	}
+	if withEndpoint {
+		dest = append(dest, &delivery.URL, &delivery.Secret)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
@@ -0,0 +1,136 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// Store история платежей для правил и журнал решений
+type Store interface {
+	// PaymentStats число платежей пользователя, созданных после since, и их сумма в валюте currency
+	PaymentStats(ctx context.Context, userID, currency string, since time.Time) (count int, amount int64, err error)
+	// FirstPaidPayment время создания первого оплаченного платежа от fromUserID к toUserID, нулевое, если таких не было
+	FirstPaidPayment(ctx context.Context, fromUserID, toUserID string) (time.Time, error)
+	SaveRiskDecision(ctx context.Context, decision *models.RiskDecision) error
+}
+
+// Engine проверка платежа правилами риска до его создания. Запреты проверяются раньше отправки на проверку.
+// Лимиты скорости проверяются без блокировок, поэтому одновременные платежи могут превысить их на несколько штук
+type Engine struct {
+	cfg       config.Risk
+	blocklist map[string]bool
+	store     Store
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+// NewEngine создание движка правил риска
+func NewEngine(cfg config.Risk, store Store, logger *zap.Logger) *Engine {
+	blocklist := make(map[string]bool, len(cfg.Blocklist))
+	for _, userID := range cfg.Blocklist {
+		if userID != "" {
+			blocklist[userID] = true
+		}
+	}
+	return &Engine{
+		cfg:       cfg,
+		blocklist: blocklist,
+		store:     store,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Evaluate решение по платежу amount от fromUserID к toUserID. Решение еще не записано, его записывает Record.
+// Черный список и платеж самому себе проверяются всегда, Enabled выключает только лимиты
+func (e *Engine) Evaluate(ctx context.Context, fromUserID, toUserID string, amount models.Money) (*models.RiskDecision, error) {
+	decision := &models.RiskDecision{
+		ID:         uuid.New().String(),
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Amount:     amount,
+		Decision:   models.RiskAllow,
+		CreatedAt:  e.now(),
+	}
+	rule, reason, verdict, err := e.evaluate(ctx, fromUserID, toUserID, amount, decision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if verdict != models.RiskAllow {
+		decision.Decision, decision.Rule, decision.Reason = verdict, rule, reason
+	}
+	metrics.RiskDecisions.WithLabelValues(string(decision.Decision), decision.Rule).Inc()
+	return decision, nil
+}
+
+// Record запись решения в журнал. Ошибка записи не меняет решение, поэтому только логируется.
+// При выключенных лимитах в журнал попадают только отказы
+func (e *Engine) Record(ctx context.Context, decision *models.RiskDecision) {
+	if !e.cfg.Enabled && decision.Decision == models.RiskAllow {
+		return
+	}
+	if err := e.store.SaveRiskDecision(ctx, decision); err != nil {
+		e.logger.Error("Failed to save risk decision", zap.String("decision_id", decision.ID), zap.String("decision", string(decision.Decision)), zap.Error(err))
+	}
+}
+
+// evaluate первое сработавшее правило
+func (e *Engine) evaluate(ctx context.Context, fromUserID, toUserID string, amount models.Money, now time.Time) (string, string, models.RiskDecisionKind, error) {
+	switch {
+	case e.blocklist[fromUserID]:
+		return models.RiskRuleBlocklist, "sender is blocklisted", models.RiskDeny, nil
+	case e.blocklist[toUserID]:
+		return models.RiskRuleBlocklist, "recipient is blocklisted", models.RiskDeny, nil
+	case fromUserID == toUserID:
+		return models.RiskRuleSelfPayment, "sender and recipient are the same user", models.RiskDeny, nil
+	case !e.cfg.Enabled:
+		return "", "", models.RiskAllow, nil
+	}
+	if limit := e.cfg.MaxAmount[amount.Currency]; limit > 0 && amount.MinorUnits > limit {
+		return models.RiskRuleMaxAmount, fmt.Sprintf("amount exceeds %s %s limit", models.Money{MinorUnits: limit, Currency: amount.Currency}, amount.Currency), models.RiskDeny, nil
+	}
+
+	windows := []struct {
+		period                time.Duration
+		countRule, amountRule string
+		count                 int
+		amount                int64
+	}{
+		{time.Hour, models.RiskRuleHourlyCount, models.RiskRuleHourlyAmount, e.cfg.HourlyCount, e.cfg.HourlyAmount[amount.Currency]},
+		{24 * time.Hour, models.RiskRuleDailyCount, models.RiskRuleDailyAmount, e.cfg.DailyCount, e.cfg.DailyAmount[amount.Currency]},
+	}
+	for _, window := range windows {
+		if window.count <= 0 && window.amount <= 0 {
+			continue
+		}
+		count, sum, err := e.store.PaymentStats(ctx, fromUserID, amount.Currency, now.Add(-window.period))
+		if err != nil {
+			return "", "", "", err
+		}
+		if window.count > 0 && count+1 > window.count {
+			return window.countRule, fmt.Sprintf("more than %d payments in %s", window.count, window.period), models.RiskDeny, nil
+		}
+		if window.amount > 0 && sum+amount.MinorUnits > window.amount {
+			return window.amountRule, fmt.Sprintf("payments exceed %s %s in %s", models.Money{MinorUnits: window.amount, Currency: amount.Currency}, amount.Currency, window.period), models.RiskDeny, nil
+		}
+	}
+
+	if limit := e.cfg.NewRecipientMaxAmount[amount.Currency]; limit > 0 && amount.MinorUnits > limit {
+		first, err := e.store.FirstPaidPayment(ctx, fromUserID, toUserID)
+		if err != nil {
+			return "", "", "", err
+		}
+		if first.IsZero() || now.Sub(first) < e.cfg.NewRecipientCooling {
+			return models.RiskRuleNewRecipient, fmt.Sprintf("amount above %s %s to a recipient first paid less than %s ago", models.Money{MinorUnits: limit, Currency: amount.Currency}, amount.Currency, e.cfg.NewRecipientCooling), models.RiskReview, nil
+		}
+	}
+
+	return "", "", models.RiskAllow, nil
+}
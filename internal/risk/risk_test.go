@@ -0,0 +1,136 @@
+package risk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeStore история платежей одного отправителя в памяти
+type fakeStore struct {
+	payments  []models.Payment // платежи отправителя
+	firstPaid map[string]time.Time
+	saved     []*models.RiskDecision
+}
+
+func (s *fakeStore) PaymentStats(ctx context.Context, userID, currency string, since time.Time) (int, int64, error) {
+	var count int
+	var amount int64
+	for _, payment := range s.payments {
+		if payment.FromUserID != userID || payment.CreatedAt.Before(since) {
+			continue
+		}
+		count++
+		if payment.Amount.Currency == currency {
+			amount += payment.Amount.MinorUnits
+		}
+	}
+	return count, amount, nil
+}
+
+func (s *fakeStore) FirstPaidPayment(ctx context.Context, fromUserID, toUserID string) (time.Time, error) {
+	return s.firstPaid[toUserID], nil
+}
+
+func (s *fakeStore) SaveRiskDecision(ctx context.Context, decision *models.RiskDecision) error {
+	s.saved = append(s.saved, decision)
+	return nil
+}
+
+func rub(minorUnits int64) models.Money {
+	return models.Money{MinorUnits: minorUnits, Currency: "RUB"}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{
+		payments: []models.Payment{
+			{FromUserID: "alice", Amount: rub(30000), CreatedAt: now.Add(-10 * time.Minute)},
+			{FromUserID: "alice", Amount: rub(30000), CreatedAt: now.Add(-5 * time.Hour)},
+			{FromUserID: "alice", Amount: models.Money{MinorUnits: 100, Currency: "USD"}, CreatedAt: now.Add(-5 * time.Hour)},
+		},
+		firstPaid: map[string]time.Time{"old-friend": now.Add(-48 * time.Hour), "fresh": now.Add(-time.Hour)},
+	}
+	engine := NewEngine(config.Risk{
+		Enabled:               true,
+		Blocklist:             []string{"mallory"},
+		MaxAmount:             map[string]int64{"RUB": 1000000},
+		HourlyCount:           2,
+		DailyCount:            5,
+		HourlyAmount:          map[string]int64{"RUB": 100000},
+		DailyAmount:           map[string]int64{"RUB": 200000},
+		NewRecipientCooling:   24 * time.Hour,
+		NewRecipientMaxAmount: map[string]int64{"RUB": 50000},
+	}, store, zaptest.NewLogger(t))
+	engine.now = func() time.Time { return now }
+
+	tests := []struct {
+		name     string
+		from, to string
+		amount   models.Money
+		decision models.RiskDecisionKind
+		rule     string
+	}{
+		{"allowed", "alice", "old-friend", rub(10000), models.RiskAllow, ""},
+		{"blocklisted sender", "mallory", "bob", rub(100), models.RiskDeny, models.RiskRuleBlocklist},
+		{"blocklisted recipient", "alice", "mallory", rub(100), models.RiskDeny, models.RiskRuleBlocklist},
+		{"self payment", "alice", "alice", rub(100), models.RiskDeny, models.RiskRuleSelfPayment},
+		{"max amount", "bob", "carol", rub(1000001), models.RiskDeny, models.RiskRuleMaxAmount},
+		{"hourly amount", "alice", "old-friend", rub(80000), models.RiskDeny, models.RiskRuleHourlyAmount},
+		{"new recipient", "alice", "stranger", rub(60000), models.RiskReview, models.RiskRuleNewRecipient},
+		{"recipient in cooling period", "alice", "fresh", rub(60000), models.RiskReview, models.RiskRuleNewRecipient},
+		{"known recipient", "alice", "old-friend", rub(60000), models.RiskAllow, ""},
+		{"new recipient small amount", "alice", "stranger", rub(50000), models.RiskAllow, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate(context.Background(), tt.from, tt.to, tt.amount)
+			require.NoError(t, err)
+			assert.Equal(t, tt.decision, decision.Decision)
+			assert.Equal(t, tt.rule, decision.Rule)
+			assert.NotEmpty(t, decision.ID)
+		})
+	}
+}
+
+func TestEngine_VelocityCount(t *testing.T) {
+	now := time.Now()
+	store := &fakeStore{payments: []models.Payment{
+		{FromUserID: "alice", Amount: rub(100), CreatedAt: now.Add(-2 * time.Hour)},
+		{FromUserID: "alice", Amount: rub(100), CreatedAt: now.Add(-3 * time.Hour)},
+	}}
+	engine := NewEngine(config.Risk{Enabled: true, HourlyCount: 1, DailyCount: 2}, store, zaptest.NewLogger(t))
+
+	decision, err := engine.Evaluate(context.Background(), "alice", "bob", rub(100))
+	require.NoError(t, err)
+	assert.Equal(t, models.RiskDeny, decision.Decision)
+	assert.Equal(t, models.RiskRuleDailyCount, decision.Rule)
+
+	engine.Record(context.Background(), decision)
+	assert.Equal(t, []*models.RiskDecision{decision}, store.saved)
+}
+
+func TestEngine_Disabled(t *testing.T) {
+	store := &fakeStore{payments: []models.Payment{{FromUserID: "alice", Amount: rub(100), CreatedAt: time.Now()}}}
+	engine := NewEngine(config.Risk{Enabled: false, Blocklist: []string{"mallory"}, MaxAmount: map[string]int64{"RUB": 100}, HourlyCount: 1}, store, zaptest.NewLogger(t))
+
+	decision, err := engine.Evaluate(context.Background(), "alice", "bob", rub(1000))
+	require.NoError(t, err)
+	assert.Equal(t, models.RiskAllow, decision.Decision, "limits are off")
+	engine.Record(context.Background(), decision)
+	assert.Empty(t, store.saved)
+
+	for _, users := range [][2]string{{"alice", "alice"}, {"mallory", "bob"}, {"alice", "mallory"}} {
+		decision, err := engine.Evaluate(context.Background(), users[0], users[1], rub(100))
+		require.NoError(t, err)
+		assert.Equal(t, models.RiskDeny, decision.Decision, "%s -> %s", users[0], users[1])
+		engine.Record(context.Background(), decision)
+	}
+	assert.Len(t, store.saved, 3)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// maxDeadLetters сколько dead letter отдается за один запрос
+const maxDeadLetters = 100
+
+// ListDeadLetters платежи, на которых демон исчерпал попытки, сначала недавние
+func (s *PaymentService) ListDeadLetters(ctx context.Context, limit int) ([]*models.DeadLetter, error) {
+	if limit <= 0 || limit > maxDeadLetters {
+		limit = maxDeadLetters
+	}
+	letters, err := s.deadLetters.ListDeadLetters(ctx, limit)
+	if err != nil {
+		s.logger.Error("Failed to list dead letters", zap.Error(err))
+		return nil, err
+	}
+	return letters, nil
+}
+
+// RequeueDeadLetter возврат платежа из dead letter в очередь демона с обнуленным счетчиком попыток
+func (s *PaymentService) RequeueDeadLetter(ctx context.Context, paymentID string) (*models.Payment, error) {
+	payment, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		s.logger.Error("Failed to fetch dead letter payment", zap.String("payment_id", paymentID), zap.Error(err))
+		return nil, err
+	}
+	if err := s.deadLetters.DeleteDeadLetter(ctx, paymentID); err != nil {
+		s.logger.Warn("Failed to requeue dead letter", zap.String("payment_id", paymentID), zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.paymentsQueue.Enqueue(ctx, *payment); err != nil { // платеж не должен пропасть и из очереди, и из dead letter
+		if restoreErr := s.deadLetters.AddDeadLetter(ctx, models.DeadLetter{PaymentID: paymentID, Stage: "requeue", LastError: err.Error()}); restoreErr != nil {
+			s.logger.Error("Failed to restore dead letter", zap.String("payment_id", paymentID), zap.Error(restoreErr))
+		}
+		return nil, err
+	}
+	s.logger.Info("Dead letter requeued", zap.String("payment_id", paymentID), zap.String("status", string(payment.Status)))
+	return payment, nil
+}
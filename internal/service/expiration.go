@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"go.uber.org/zap"
+)
+
+// ExpirePayments перевод в EXPIRED неоплаченных платежей старше срока оплаты пачками по batchSize
+// и удаление их из очереди демона. Возвращает число истекших платежей.
+func (s *PaymentService) ExpirePayments(ctx context.Context, batchSize int) (int, error) {
+	if s.lifetime <= 0 {
+		return 0, nil
+	}
+
+	expired := 0
+	for {
+		payments, err := s.repo.GetExpiredPayments(ctx, time.Now().Add(-s.lifetime), batchSize)
+		if err != nil {
+			return expired, err
+		}
+
+		var ids []string
+		for _, payment := range payments {
+			err := s.expirePayment(ctx, payment)
+			if errors.Is(err, repository.ErrStatusConflict) { // платеж успели оплатить
+				continue
+			}
+			if err != nil {
+				return expired, err
+			}
+			ids = append(ids, payment.ID)
+		}
+		if _, err := s.paymentsQueue.Remove(ctx, ids...); err != nil {
+			s.logger.Warn("Failed to remove expired payments from queue", zap.Int("count", len(ids)), zap.Error(err)) // демон сам отпустит их по статусу
+		}
+		expired += len(ids)
+
+		if len(payments) < batchSize || ctx.Err() != nil {
+			return expired, nil
+		}
+	}
+}
+
+// overdue неоплаченный платеж старше срока оплаты
+func (s *PaymentService) overdue(payment *models.Payment) bool {
+	return s.lifetime > 0 && payment.Status.IsUnpaid() && time.Since(payment.CreatedAt) > s.lifetime
+}
+
+// expirePayment перевод платежа в EXPIRED из текущего статуса
+func (s *PaymentService) expirePayment(ctx context.Context, payment *models.Payment) error {
+	event, err := s.repo.UpdatePaymentStatus(ctx, payment.ID, models.StatusChange{
+		Expected: payment.Status,
+		Status:   models.StatusExpired,
+		Actor:    models.ActorExpiration,
+		Reason:   "payment lifetime exceeded",
+	})
+	if err != nil {
+		return err
+	}
+	s.publish(ctx, event)
+	s.logger.Info("Payment expired", zap.String("payment_id", payment.ID), zap.Time("created_at", payment.CreatedAt))
+	return nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/auth"
+	"go.uber.org/zap"
+)
+
+// ErrIdempotencyKeyReused ключ уже использован для запроса с другими параметрами
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with different request")
+
+// ErrIdempotencyInProgress запрос с этим ключом еще выполняется
+var ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+
+// Операции, для которых ключи идемпотентности хранятся раздельно
+const (
+	operationCreatePayment = "create_payment"
+	operationRefundPayment = "refund_payment"
+)
+
+// fingerprint хэш параметров запроса
+func fingerprint(params ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(params, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotent выполнение fn не больше одного раза на ключ, повтор получает сохраненный результат.
+// Ключ действует в пределах пользователя из контекста: одинаковые ключи разных пользователей не пересекаются.
+// Без ключа fn выполняется как обычно.
+func idempotent[T any](ctx context.Context, s *PaymentService, operation, key, requestFingerprint string, fn func() (T, error)) (T, error) {
+	var result T
+	if key == "" || s.idempotency == nil {
+		return fn()
+	}
+
+	var userID string // без пользователя (внутренний вызов) ключи общие
+	if user, ok := auth.UserFromContext(ctx); ok {
+		userID = user.ID
+	}
+	record, reserved, err := s.idempotency.Reserve(ctx, operation, userID, key, requestFingerprint)
+	if err != nil {
+		return result, err
+	}
+	if !reserved {
+		if record.Fingerprint != requestFingerprint {
+			return result, ErrIdempotencyKeyReused
+		}
+		if !record.Completed {
+			return result, ErrIdempotencyInProgress
+		}
+		s.logger.Info("Replaying idempotent request", zap.String("operation", operation), zap.String("user_id", userID), zap.String("idempotency_key", key))
+		err := json.Unmarshal(record.Response, &result)
+		return result, err
+	}
+
+	result, err = fn()
+	// результат сохраняется, даже если клиент уже отключился
+	ctx = context.WithoutCancel(ctx)
+	if err != nil { // неудачный запрос можно повторить с тем же ключом
+		if releaseErr := s.idempotency.Release(ctx, operation, userID, key); releaseErr != nil {
+			s.logger.Error("Failed to release idempotency key", zap.String("operation", operation), zap.String("idempotency_key", key), zap.Error(releaseErr))
+		}
+		return result, err
+	}
+
+	response, err := json.Marshal(result)
+	if err == nil {
+		err = s.idempotency.Complete(ctx, operation, userID, key, response)
+	}
+	if err != nil { // результат уже получен, отдаем его, но повтор с ключом может выполниться заново
+		s.logger.Error("Failed to save idempotent response", zap.String("operation", operation), zap.String("idempotency_key", key), zap.Error(err))
+	}
+	return result, nil
+}
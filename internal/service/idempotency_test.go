@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/auth"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// fakeIdempotency ключи в памяти с той же областью действия, что и в бд: операция, пользователь, ключ
+type fakeIdempotency struct {
+	records map[[3]string]*models.IdempotencyRecord
+}
+
+func (f *fakeIdempotency) Reserve(ctx context.Context, operation, userID, key, fingerprint string) (*models.IdempotencyRecord, bool, error) {
+	id := [3]string{operation, userID, key}
+	if record, ok := f.records[id]; ok {
+		return record, false, nil
+	}
+	f.records[id] = &models.IdempotencyRecord{Operation: operation, UserID: userID, Key: key, Fingerprint: fingerprint}
+	return nil, true, nil
+}
+
+func (f *fakeIdempotency) Complete(ctx context.Context, operation, userID, key string, response []byte) error {
+	record := f.records[[3]string{operation, userID, key}]
+	record.Response, record.Completed = response, true
+	return nil
+}
+
+func (f *fakeIdempotency) Release(ctx context.Context, operation, userID, key string) error {
+	delete(f.records, [3]string{operation, userID, key})
+	return nil
+}
+
+func TestIdempotent_KeysAreScopedToUser(t *testing.T) {
+	s := &PaymentService{idempotency: &fakeIdempotency{records: map[[3]string]*models.IdempotencyRecord{}}, logger: zap.NewNop()}
+	alice := auth.WithUser(context.Background(), auth.User{ID: "alice"})
+	bob := auth.WithUser(context.Background(), auth.User{ID: "bob"})
+
+	var calls int
+	create := func() (string, error) {
+		calls++
+		return "payment-" + string(rune('0'+calls)), nil
+	}
+
+	first, err := idempotent(alice, s, operationCreatePayment, "key-1", "same", create)
+	require.NoError(t, err)
+	replay, err := idempotent(alice, s, operationCreatePayment, "key-1", "same", create)
+	require.NoError(t, err)
+	assert.Equal(t, first, replay, "same user replays the stored result")
+
+	other, err := idempotent(bob, s, operationCreatePayment, "key-1", "same", create)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other, "another user's key must not replay alice's result")
+	assert.Equal(t, 2, calls)
+
+	_, err = idempotent(bob, s, operationCreatePayment, "key-1", "different", create)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+}
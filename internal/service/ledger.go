@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// GetBalance остатки счета главной книги по валютам. Счета без проводок возвращают пустой список
+func (s *PaymentService) GetBalance(ctx context.Context, account string) ([]*models.AccountBalance, error) {
+	balances, err := s.ledger.GetBalances(ctx, account)
+	if err != nil {
+		s.logger.Error("Failed to get ledger balance", zap.String("account", account), zap.Error(err))
+		return nil, err
+	}
+	return balances, nil
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"go.uber.org/zap"
+)
+
+// ErrReconciliationUnsupported шлюз не отдает историю операций
+var ErrReconciliationUnsupported = errors.New("provider does not support reconciliation")
+
+// ErrInvalidReconciliationWindow окно сверки пустое или слишком длинное
+var ErrInvalidReconciliationWindow = errors.New("invalid reconciliation window")
+
+const (
+	maxReconciliationReports = 100                 // сколько отчетов отдается за один запрос
+	maxReconciliationWindow  = 31 * 24 * time.Hour // длиннее окна история шлюза отдается слишком долго
+)
+
+// Reconcile сверка платежей шлюза providerName с его историей операций за окно [from, to).
+// Отчет сохраняется. При autoFix платежи, оплаченные в шлюзе полной суммой, переводятся в SUCCESS,
+// остальные расхождения только попадают в отчет: их исправление может привести к двойной выплате.
+func (s *PaymentService) Reconcile(ctx context.Context, providerName string, from, to time.Time, autoFix bool) (*models.ReconciliationReport, error) {
+	if from.IsZero() || to.IsZero() || !from.Before(to) || to.Sub(from) > maxReconciliationWindow {
+		return nil, fmt.Errorf("%w: from %s, to %s", ErrInvalidReconciliationWindow, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	history, ok := provider.(clients.OperationHistory)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReconciliationUnsupported, provider.Name())
+	}
+
+	s.logger.Info("Starting reconciliation", zap.String("provider", provider.Name()), zap.Time("from", from), zap.Time("to", to), zap.Bool("auto_fix", autoFix))
+	operations, err := history.Operations(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching provider operations: %w", err)
+	}
+
+	input, err := s.reconciliationInput(ctx, provider.Name(), from, to, operations)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.ReconciliationReport{
+		Provider:   provider.Name(),
+		From:       from,
+		To:         to,
+		Operations: len(operations),
+		AutoFix:    autoFix,
+		Mismatches: reconcile(input),
+	}
+	if autoFix {
+		s.fixMismatches(ctx, report, input.payments)
+	}
+	for _, mismatch := range report.Mismatches {
+		metrics.ReconciliationMismatch(report.Provider, string(mismatch.Kind))
+	}
+
+	if err := s.reconciliations.SaveReconciliationReport(ctx, report); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Reconciliation finished",
+		zap.String("report_id", report.ID),
+		zap.String("provider", report.Provider),
+		zap.Int("operations", report.Operations),
+		zap.Int("mismatches", len(report.Mismatches)),
+		zap.Int("fixed", report.Fixed()),
+	)
+	return report, nil
+}
+
+// GetReconciliationReport отчет сверки по id
+func (s *PaymentService) GetReconciliationReport(ctx context.Context, reportID string) (*models.ReconciliationReport, error) {
+	if _, err := uuid.Parse(reportID); err != nil {
+		return nil, repository.ErrReconciliationReportNotFound
+	}
+	return s.reconciliations.GetReconciliationReport(ctx, reportID)
+}
+
+// ListReconciliationReports последние отчеты сверки, сначала новые
+func (s *PaymentService) ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	if limit <= 0 || limit > maxReconciliationReports {
+		limit = maxReconciliationReports
+	}
+	reports, err := s.reconciliations.ListReconciliationReports(ctx, limit)
+	if err != nil {
+		s.logger.Error("Failed to list reconciliation reports", zap.Error(err))
+		return nil, err
+	}
+	return reports, nil
+}
+
+// reconciliationInput все, с чем сравниваются операции шлюза
+type reconciliationInput struct {
+	provider   string
+	operations []clients.ProviderOperation
+	payments   map[string]*models.Payment // платежи по меткам операций и закрытые в окне
+	refunds    map[string]bool            // возвраты по меткам операций
+	completed  []*models.Payment          // платежи, закрытые в окне сверки
+	refunded   map[string]int64           // сумма возвратов по платежу
+}
+
+// reconciliationInput загрузка платежей и возвратов, на которые ссылаются метки операций
+func (s *PaymentService) reconciliationInput(ctx context.Context, provider string, from, to time.Time, operations []clients.ProviderOperation) (reconciliationInput, error) {
+	input := reconciliationInput{
+		provider:   provider,
+		operations: operations,
+		payments:   map[string]*models.Payment{},
+		refunds:    map[string]bool{},
+	}
+
+	var paymentIDs, refundIDs []string
+	for _, operation := range operations {
+		label := operation.Label
+		if refundID, ok := strings.CutPrefix(label, clients.RefundLabelPrefix); ok {
+			label = refundID
+		}
+		if _, err := uuid.Parse(label); err != nil { // чужие метки в бд не ищем
+			continue
+		}
+		if label != operation.Label {
+			refundIDs = append(refundIDs, label)
+		} else {
+			paymentIDs = append(paymentIDs, label)
+		}
+	}
+
+	payments, err := s.reconciliations.GetPaymentsByIDs(ctx, paymentIDs)
+	if err != nil {
+		return input, err
+	}
+	input.completed, err = s.reconciliations.GetCompletedPayments(ctx, provider, from, to)
+	if err != nil {
+		return input, err
+	}
+	for _, payment := range append(payments, input.completed...) {
+		input.payments[payment.ID] = payment
+	}
+
+	refunds, err := s.reconciliations.GetRefundIDs(ctx, refundIDs)
+	if err != nil {
+		return input, err
+	}
+	for _, refundID := range refunds {
+		input.refunds[refundID] = true
+	}
+
+	ids := make([]string, 0, len(input.payments))
+	for paymentID := range input.payments {
+		ids = append(ids, paymentID)
+	}
+	input.refunded, err = s.reconciliations.GetRefundedAmounts(ctx, ids)
+	return input, err
+}
+
+// reconcile поиск расхождений между успешными операциями шлюза и платежами
+func reconcile(input reconciliationInput) []models.ReconciliationMismatch {
+	var mismatches []models.ReconciliationMismatch
+	deposited := map[string]bool{} // по платежу получена оплата
+	paidOut := map[string]bool{}   // по платежу сделана выплата
+
+	for _, operation := range input.operations {
+		if operation.Status != clients.ProviderStatusSuccess || operation.Label == "" { // операции без метки сделаны не сервисом
+			continue
+		}
+		actual := operation.Amount
+		mismatch := models.ReconciliationMismatch{OperationID: operation.ID, Label: operation.Label, Actual: &actual}
+
+		if refundID, ok := strings.CutPrefix(operation.Label, clients.RefundLabelPrefix); ok {
+			if !input.refunds[refundID] || operation.Direction != clients.OperationOut {
+				mismatch.Kind, mismatch.Details = models.MismatchUnknownLabel, "no refund with this label"
+				mismatches = append(mismatches, mismatch)
+			}
+			continue
+		}
+
+		payment, ok := input.payments[operation.Label]
+		if !ok || payment.Provider != input.provider {
+			mismatch.Kind, mismatch.Details = models.MismatchUnknownLabel, "no payment with this label"
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		expected := payment.Amount
+		mismatch.PaymentID, mismatch.PaymentStatus, mismatch.Expected = payment.ID, payment.Status, &expected
+		comparable := payment.Amount.Currency == operation.Amount.Currency // суммы в валюте, отличной от валюты шлюза, конвертируются по плавающему курсу
+
+		switch operation.Direction {
+		case clients.OperationIn:
+			if !deposited[payment.ID] && !payment.Status.IsPaid() && payment.Status != models.StatusRefunded {
+				settled := mismatch
+				settled.Kind, settled.Details = models.MismatchPaidNotSettled, "provider operation succeeded"
+				mismatches = append(mismatches, settled)
+			}
+			deposited[payment.ID] = true
+			if comparable && operation.Amount != payment.Amount {
+				mismatch.Kind, mismatch.Details = models.MismatchAmount, "deposit differs from payment amount"
+				mismatches = append(mismatches, mismatch)
+			}
+		case clients.OperationOut:
+			paidOut[payment.ID] = true
+			// выплата не больше суммы платежа за вычетом комиссии и не меньше остатка после всех возвратов
+			if comparable && (operation.Amount.MinorUnits > expectedPayout(payment, 0) || operation.Amount.MinorUnits < expectedPayout(payment, input.refunded[payment.ID])) {
+				mismatch.Kind, mismatch.Details = models.MismatchAmount, "payout differs from payment amount minus refunds and fee"
+				mismatches = append(mismatches, mismatch)
+			}
+		}
+	}
+
+	for _, payment := range input.completed {
+		if paidOut[payment.ID] || expectedPayout(payment, input.refunded[payment.ID]) <= 0 { // после полного возврата выплачивать нечего
+			continue
+		}
+		expected := payment.Amount
+		mismatches = append(mismatches, models.ReconciliationMismatch{
+			Kind:          models.MismatchCompleteWithoutPayout,
+			PaymentID:     payment.ID,
+			Label:         payment.ID,
+			PaymentStatus: payment.Status,
+			Expected:      &expected,
+			Details:       "no payout operation in window",
+		})
+	}
+	return mismatches
+}
+
+// paidInFull поступление по операции совпадает с суммой платежа
+func paidInFull(mismatch models.ReconciliationMismatch) bool {
+	return mismatch.Expected != nil && mismatch.Actual != nil && *mismatch.Actual == *mismatch.Expected
+}
+
+// fixMismatches перевод в SUCCESS платежей, оплаченных в шлюзе полной суммой, выплату затем делает демон
+func (s *PaymentService) fixMismatches(ctx context.Context, report *models.ReconciliationReport, payments map[string]*models.Payment) {
+	for i := range report.Mismatches {
+		mismatch := &report.Mismatches[i]
+		if mismatch.Kind != models.MismatchPaidNotSettled || !paidInFull(*mismatch) { // недоплату и суммы в другой валюте проверяет человек
+			continue
+		}
+		current, err := s.syncStatus(ctx, payments[mismatch.PaymentID], models.StatusChange{
+			Status: models.StatusSuccess,
+			Actor:  models.ActorReconciliation,
+			Reason: "reconciliation: provider operation " + mismatch.OperationID + " succeeded",
+		})
+		if err != nil {
+			s.logger.Error("Failed to fix reconciliation mismatch", zap.String("payment_id", mismatch.PaymentID), zap.Error(err))
+			continue
+		}
+		mismatch.Fixed = current.IsPaid()
+	}
+}
+
+// expectedPayout выплата получателю в минорных единицах после возвратов refunded и комиссии, как ее считает демон
+func expectedPayout(payment *models.Payment, refunded int64) int64 {
+	remaining := payment.Amount.MinorUnits - refunded
+	return remaining - min(max(payment.Fee.MinorUnits, 0), max(remaining, 0))
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+)
+
+const (
+	pendingID  = "00000000-0000-0000-0000-000000000001"
+	completeID = "00000000-0000-0000-0000-000000000002"
+	refundedID = "00000000-0000-0000-0000-000000000003"
+	unpaidOut  = "00000000-0000-0000-0000-000000000004"
+	refundID   = "00000000-0000-0000-0000-000000000005"
+)
+
+func rub(minorUnits int64) models.Money {
+	return models.Money{MinorUnits: minorUnits, Currency: "RUB"}
+}
+
+func TestReconcile(t *testing.T) {
+	payments := map[string]*models.Payment{
+		pendingID:  {ID: pendingID, Amount: rub(10000), Status: models.StatusPending, Provider: "yoomoney"},
+		completeID: {ID: completeID, Amount: rub(5000), Status: models.StatusComplete, Provider: "yoomoney"},
+		refundedID: {ID: refundedID, Amount: rub(3000), Fee: rub(100), Status: models.StatusComplete, Provider: "yoomoney"},
+		unpaidOut:  {ID: unpaidOut, Amount: rub(2000), Status: models.StatusComplete, Provider: "yoomoney"},
+	}
+	input := reconciliationInput{
+		provider: "yoomoney",
+		operations: []clients.ProviderOperation{
+			{ID: "op-1", Label: pendingID, Direction: clients.OperationIn, Status: clients.ProviderStatusSuccess, Amount: rub(9900)},
+			{ID: "op-2", Label: completeID, Direction: clients.OperationIn, Status: clients.ProviderStatusSuccess, Amount: rub(5000)},
+			{ID: "op-3", Label: completeID, Direction: clients.OperationOut, Status: clients.ProviderStatusSuccess, Amount: rub(5000)},
+			{ID: "op-4", Label: refundedID, Direction: clients.OperationOut, Status: clients.ProviderStatusSuccess, Amount: rub(1900)}, // выплата за вычетом возврата и комиссии
+			{ID: "op-5", Label: clients.RefundLabelPrefix + refundID, Direction: clients.OperationOut, Status: clients.ProviderStatusSuccess, Amount: rub(1000)},
+			{ID: "op-6", Label: "someone-else", Direction: clients.OperationIn, Status: clients.ProviderStatusSuccess, Amount: rub(100)},
+			{ID: "op-7", Label: unpaidOut, Direction: clients.OperationOut, Status: clients.ProviderStatusFailed, Amount: rub(2000)}, // отклоненная выплата не считается
+			{ID: "op-8", Direction: clients.OperationIn, Status: clients.ProviderStatusSuccess, Amount: rub(100)},                    // пополнение без метки
+		},
+		payments:  payments,
+		refunds:   map[string]bool{refundID: true},
+		completed: []*models.Payment{payments[completeID], payments[refundedID], payments[unpaidOut]},
+		refunded:  map[string]int64{refundedID: 1000},
+	}
+
+	var kinds []string
+	for _, mismatch := range reconcile(input) {
+		kinds = append(kinds, string(mismatch.Kind)+" "+mismatch.Label)
+	}
+	assert.Equal(t, []string{
+		"paid_not_settled " + pendingID,
+		"amount_mismatch " + pendingID,
+		"unknown_label someone-else",
+		"complete_without_payout " + unpaidOut,
+	}, kinds)
+}
+
+func TestReconcile_PayoutAmountOutOfRange(t *testing.T) {
+	payment := &models.Payment{ID: completeID, Amount: rub(5000), Status: models.StatusComplete, Provider: "yoomoney"}
+	input := reconciliationInput{
+		provider: "yoomoney",
+		operations: []clients.ProviderOperation{
+			{ID: "op-1", Label: completeID, Direction: clients.OperationOut, Status: clients.ProviderStatusSuccess, Amount: rub(6000)},
+		},
+		payments:  map[string]*models.Payment{completeID: payment},
+		completed: []*models.Payment{payment},
+		refunded:  map[string]int64{},
+	}
+
+	mismatches := reconcile(input)
+	if assert.Len(t, mismatches, 1) {
+		assert.Equal(t, models.MismatchAmount, mismatches[0].Kind)
+		assert.Equal(t, rub(5000), *mismatches[0].Expected)
+		assert.Equal(t, rub(6000), *mismatches[0].Actual)
+	}
+}
+
+func TestReconcile_UnderpaidDepositIsNotFixed(t *testing.T) {
+	payment := &models.Payment{ID: pendingID, Amount: rub(10000), Status: models.StatusPending, Provider: "yoomoney"}
+	input := reconciliationInput{
+		provider: "yoomoney",
+		operations: []clients.ProviderOperation{
+			{ID: "op-1", Label: pendingID, Direction: clients.OperationIn, Status: clients.ProviderStatusSuccess, Amount: rub(1)},
+		},
+		payments: map[string]*models.Payment{pendingID: payment},
+		refunded: map[string]int64{},
+	}
+	report := &models.ReconciliationReport{Provider: "yoomoney", AutoFix: true, Mismatches: reconcile(input)}
+
+	(&PaymentService{}).fixMismatches(context.Background(), report, input.payments) // без зависимостей: исправление статуса упало бы
+	if assert.Len(t, report.Mismatches, 2) {
+		assert.Equal(t, models.MismatchPaidNotSettled, report.Mismatches[0].Kind)
+		assert.Equal(t, models.MismatchAmount, report.Mismatches[1].Kind)
+	}
+	assert.Zero(t, report.Fixed())
+}
+
+func TestPaidInFull(t *testing.T) {
+	full, under, usd := rub(10000), rub(9999), models.Money{MinorUnits: 10000, Currency: "USD"}
+	assert.True(t, paidInFull(models.ReconciliationMismatch{Expected: &full, Actual: &full}))
+	assert.False(t, paidInFull(models.ReconciliationMismatch{Expected: &full, Actual: &under}))
+	assert.False(t, paidInFull(models.ReconciliationMismatch{Expected: &full, Actual: &usd}))
+	assert.False(t, paidInFull(models.ReconciliationMismatch{Expected: &full}))
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"go.uber.org/zap"
+)
+
+// ResolvePendingRefunds завершение возвратов, которые дольше checkAfter остаются в PENDING, потому что шлюз
+// не ответил определенно. Исход ищется в истории операций шлюза по метке RefundLabelPrefix+id возврата.
+// Возврат без операции в истории дольше failAfter считается неудавшимся, и его сумму снова можно вернуть.
+// Возвращает число завершенных возвратов.
+func (s *PaymentService) ResolvePendingRefunds(ctx context.Context, checkAfter, failAfter time.Duration, batchSize int) (int, error) {
+	now := time.Now()
+	refunds, err := s.refunds.ListPendingRefunds(ctx, now.Add(-checkAfter), batchSize)
+	if err != nil || len(refunds) == 0 {
+		return 0, err
+	}
+
+	operations := map[string]map[string]clients.ProviderOperation{} // операции возвратов по шлюзу и метке
+	resolved := 0
+	for _, refund := range refunds {
+		payment, err := s.repo.GetPaymentByID(ctx, refund.PaymentID)
+		if err != nil {
+			return resolved, fmt.Errorf("error fetching payment: %w", err)
+		}
+		byLabel, ok := operations[payment.Provider]
+		if !ok {
+			byLabel, err = s.refundOperations(ctx, payment.Provider, refunds[0].CreatedAt.Add(-time.Minute), now) // возвраты отсортированы, первый самый старый
+			if err != nil {
+				return resolved, err
+			}
+			operations[payment.Provider] = byLabel
+		}
+
+		label := clients.RefundLabelPrefix + refund.ID
+		operation, found := byLabel[label]
+		status, reference := models.RefundStatusFailed, ""
+		switch {
+		case found && operation.Status == clients.ProviderStatusSuccess:
+			status, reference = models.RefundStatusSucceeded, label
+		case found && operation.Status == clients.ProviderStatusFailed:
+		case found: // перевод еще проводится
+			continue
+		case now.Sub(refund.CreatedAt) < failAfter: // операция могла еще не попасть в историю
+			continue
+		}
+
+		if _, err := s.completeRefund(ctx, refund.ID, status, reference); err != nil {
+			s.logger.Error("Failed to resolve pending refund", zap.String("refund_id", refund.ID), zap.Error(err)) // возврат мог завершить другой экземпляр
+			continue
+		}
+		resolved++
+		s.logger.Info("Pending refund resolved", zap.String("refund_id", refund.ID), zap.String("payment_id", refund.PaymentID), zap.String("status", string(status)), zap.Bool("found", found))
+	}
+	return resolved, nil
+}
+
+// refundOperations успешные и неудачные переводы возвратов шлюза providerName за окно [from, to) по метке
+func (s *PaymentService) refundOperations(ctx context.Context, providerName string, from, to time.Time) (map[string]clients.ProviderOperation, error) {
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	history, ok := provider.(clients.OperationHistory)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReconciliationUnsupported, provider.Name())
+	}
+	operations, err := history.Operations(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching provider operations: %w", err)
+	}
+
+	byLabel := make(map[string]clients.ProviderOperation)
+	for _, operation := range operations {
+		if operation.Direction != clients.OperationOut || !strings.HasPrefix(operation.Label, clients.RefundLabelPrefix) {
+			continue
+		}
+		if previous, ok := byLabel[operation.Label]; ok && previous.Status == clients.ProviderStatusSuccess {
+			continue // успешная операция важнее повторов с ошибкой
+		}
+		byLabel[operation.Label] = operation
+	}
+	return byLabel, nil
+}
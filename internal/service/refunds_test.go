@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"go.uber.org/zap"
+)
+
+type fakePayments struct {
+	repository.PaymentRepository
+	payments map[string]*models.Payment
+}
+
+func (f *fakePayments) GetPaymentByID(ctx context.Context, paymentID string) (*models.Payment, error) {
+	if payment, ok := f.payments[paymentID]; ok {
+		return payment, nil
+	}
+	return nil, repository.ErrPaymentNotFound
+}
+
+type fakeRefunds struct {
+	repository.RefundRepository
+	pending   []*models.Refund
+	completed map[string]models.RefundStatus
+}
+
+func (f *fakeRefunds) ListPendingRefunds(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Refund, error) {
+	var refunds []*models.Refund
+	for _, refund := range f.pending {
+		if refund.CreatedAt.Before(createdBefore) {
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (f *fakeRefunds) CompleteRefund(ctx context.Context, refundID string, status models.RefundStatus, providerRef string) (*models.Refund, *models.PaymentEvent, error) {
+	f.completed[refundID] = status
+	return &models.Refund{ID: refundID, Status: status, ProviderRef: providerRef}, nil, nil
+}
+
+// historyProvider шлюз, который отдает только историю операций
+type historyProvider struct {
+	clients.PaymentProvider
+	operations []clients.ProviderOperation
+}
+
+func (p *historyProvider) Name() string {
+	return "yoomoney"
+}
+
+func (p *historyProvider) Operations(ctx context.Context, from, to time.Time) ([]clients.ProviderOperation, error) {
+	return p.operations, nil
+}
+
+func TestResolvePendingRefunds(t *testing.T) {
+	const (
+		sentID     = "00000000-0000-0000-0000-000000000011"
+		rejectedID = "00000000-0000-0000-0000-000000000012"
+		missingID  = "00000000-0000-0000-0000-000000000013"
+		lostID     = "00000000-0000-0000-0000-000000000014"
+		freshID    = "00000000-0000-0000-0000-000000000015"
+	)
+	now := time.Now()
+	refunds := &fakeRefunds{
+		pending: []*models.Refund{
+			{ID: lostID, PaymentID: completeID, CreatedAt: now.Add(-48 * time.Hour)},
+			{ID: sentID, PaymentID: completeID, CreatedAt: now.Add(-time.Hour)},
+			{ID: rejectedID, PaymentID: completeID, CreatedAt: now.Add(-time.Hour)},
+			{ID: missingID, PaymentID: completeID, CreatedAt: now.Add(-time.Hour)},
+			{ID: freshID, PaymentID: completeID, CreatedAt: now.Add(-time.Second)}, // перевод может еще выполняться
+		},
+		completed: map[string]models.RefundStatus{},
+	}
+	provider := &historyProvider{operations: []clients.ProviderOperation{
+		{Label: clients.RefundLabelPrefix + sentID, Direction: clients.OperationOut, Status: clients.ProviderStatusSuccess},
+		{Label: clients.RefundLabelPrefix + rejectedID, Direction: clients.OperationOut, Status: clients.ProviderStatusFailed},
+		{Label: clients.RefundLabelPrefix + freshID, Direction: clients.OperationOut, Status: clients.ProviderStatusSuccess},
+	}}
+	providers, err := clients.NewProviderRegistry("yoomoney", provider)
+	require.NoError(t, err)
+	s := &PaymentService{
+		repo:      &fakePayments{payments: map[string]*models.Payment{completeID: {ID: completeID, Provider: "yoomoney"}}},
+		refunds:   refunds,
+		providers: providers,
+		logger:    zap.NewNop(),
+	}
+
+	resolved, err := s.ResolvePendingRefunds(context.Background(), time.Minute, 24*time.Hour, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 3, resolved)
+	assert.Equal(t, map[string]models.RefundStatus{
+		sentID:     models.RefundStatusSucceeded,
+		rejectedID: models.RefundStatusFailed,
+		lostID:     models.RefundStatusFailed, // операции так и не появилось в истории
+	}, refunds.completed)
+}
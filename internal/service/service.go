@@ -2,115 +2,354 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.crja72.ru/gospec/go8/payment/internal/auth"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/db"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/fees"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/risk"
 	"go.uber.org/zap"
 )
 
+// ErrInvalidAmount сумма платежа должна быть больше нуля
+var ErrInvalidAmount = errors.New("amount must be greater than zero")
+
+// ErrPaymentExpired срок оплаты платежа истек
+var ErrPaymentExpired = errors.New("payment expired")
+
+// ErrProviderMismatch платеж создан через другой шлюз
+var ErrProviderMismatch = errors.New("payment provider mismatch")
+
+// ErrPaidAmountMismatch зачисленная шлюзом сумма не совпала с суммой платежа
+var ErrPaidAmountMismatch = errors.New("paid amount does not match payment")
+
+// refundTimeout сколько ждать перевода возврата в шлюзе
+const refundTimeout = 30 * time.Second
+
+// paidRateTolerance допустимая недоплата в процентах для платежей не в рублях:
+// курс на момент оплаты мог отличаться от курса, по которому выдана ссылка
+const paidRateTolerance = 2
+
+// providerCommission комиссия шлюза в процентах за оплату картой, удерживается из зачисляемой суммы
+const providerCommission = 3
+
 // PaymentService структура для сервиса
 type PaymentService struct {
-	repo          repository.PaymentRepository
-	logger        *zap.Logger
-	converter     *clients.ForexClient
-	paymentClient *clients.YooMoneyClient
-	paymentsQueue *db.LockFreeQueue
+	repo            repository.PaymentRepository
+	idempotency     repository.IdempotencyRepository
+	refunds         repository.RefundRepository
+	deadLetters     repository.DeadLetterRepository
+	reconciliations repository.ReconciliationRepository
+	webhooks        repository.WebhookRepository
+	ledger          repository.LedgerRepository
+	logger          *zap.Logger
+	converter       clients.CurrencyConverter
+	providers       *clients.ProviderRegistry
+	paymentsQueue   db.Queue
+	wallets         clients.WalletResolver
+	events          db.PaymentEventBus
+	fees            *fees.Calculator
+	risk            *risk.Engine
+	lifetime        time.Duration // срок оплаты, 0 - бессрочно
+	webhooksHTTP    bool          // разрешены адреса вебхуков http://
 }
 
 // NewPaymentService создание экземпляра сервиса
-func NewPaymentService(repo repository.PaymentRepository, logger *zap.Logger, converter *clients.ForexClient, paymentClient *clients.YooMoneyClient, paymentsQueue *db.LockFreeQueue) *PaymentService {
+func NewPaymentService(repo repository.PaymentRepository, idempotency repository.IdempotencyRepository, refunds repository.RefundRepository, deadLetters repository.DeadLetterRepository, reconciliations repository.ReconciliationRepository, webhooks repository.WebhookRepository, ledger repository.LedgerRepository, logger *zap.Logger, converter clients.CurrencyConverter, providers *clients.ProviderRegistry, paymentsQueue db.Queue, wallets clients.WalletResolver, events db.PaymentEventBus, fees *fees.Calculator, risk *risk.Engine, lifetime time.Duration, webhooksHTTP bool) *PaymentService {
 	return &PaymentService{
-		repo:          repo,
-		logger:        logger,
-		converter:     converter,
-		paymentClient: paymentClient,
-		paymentsQueue: paymentsQueue,
+		repo:            repo,
+		idempotency:     idempotency,
+		refunds:         refunds,
+		deadLetters:     deadLetters,
+		reconciliations: reconciliations,
+		webhooks:        webhooks,
+		ledger:          ledger,
+		logger:          logger,
+		converter:       converter,
+		providers:       providers,
+		paymentsQueue:   paymentsQueue,
+		wallets:         wallets,
+		events:          events,
+		fees:            fees,
+		risk:            risk,
+		lifetime:        lifetime,
+		webhooksHTTP:    webhooksHTTP,
 	}
 }
 
-// GetPaymentLink создание ссылки для оплаты
-func (s *PaymentService) GetPaymentLink(ctx context.Context, paymentID string) (string, error) {
+// GetPaymentLink создание ссылки для оплаты, вместе со ссылкой возвращается конвертация в рубли
+func (s *PaymentService) GetPaymentLink(ctx context.Context, paymentID string) (string, clients.Conversion, error) {
 	s.logger.Info("Getting payment link", zap.String("payment_id", paymentID))
 
-	amount, currency, err := s.repo.GetPaymentDetails(ctx, paymentID) // получаем данные по оплате
+	amount, err := s.repo.GetPaymentDetails(ctx, paymentID) // получаем данные по оплате
 	if err != nil {
-		return "", fmt.Errorf("failed to get payment details: %w", err)
+		return "", clients.Conversion{}, fmt.Errorf("failed to get payment details: %w", err)
 	}
 
-	convertedAmount, err := s.converter.ConvertToRub(amount, currency) // конвертируем сумму в рубли
+	conversion, err := s.converter.ConvertToRub(ctx, amount) // конвертируем сумму в рубли
 	if err != nil {
-		return "", fmt.Errorf("failed to convert amount: %w", err)
+		return "", clients.Conversion{}, fmt.Errorf("failed to convert amount: %w", err)
 	}
+	s.logger.Info("Payment amount converted",
+		zap.String("payment_id", paymentID),
+		zap.Float64("rate", conversion.Rate.Rate),
+		zap.String("rate_source", conversion.Rate.Source),
+		zap.Time("rate_timestamp", conversion.Rate.Timestamp),
+	)
 
-	err = s.repo.UpdatePaymentStatus(ctx, paymentID, models.StatusPending) // если создали ссылку, то скоро оплата, меняем статус
+	payment, err := s.repo.GetPaymentByID(ctx, paymentID) // получаем данные платежа
 	if err != nil {
-		return "", fmt.Errorf("error changing payment status to pending: %w", err)
+		s.logger.Error("Failed to fetch payment by ID", zap.String("payment_id", paymentID), zap.Error(err))
+		return "", clients.Conversion{}, fmt.Errorf("error fetching payment: %w", err)
 	}
 
-	payment, err := s.repo.GetPaymentByID(ctx, paymentID) // получаем данные платежа
+	if s.overdue(payment) { // свипер мог еще не дойти до платежа
+		if err := s.expirePayment(ctx, payment); err != nil {
+			return "", clients.Conversion{}, err
+		}
+		payment.Status = models.StatusExpired
+	}
+
+	switch payment.Status {
+	case models.StatusExpired: // новая ссылка на просроченный платеж не выдается
+		return "", clients.Conversion{}, fmt.Errorf("%w: payment %s", ErrPaymentExpired, paymentID)
+	case models.StatusPending: // ссылку можно запрашивать повторно, статус не меняется
+	case models.StatusFailed: // после неудачной оплаты платеж снова ждет оплаты
+		event, err := s.repo.UpdatePaymentStatus(ctx, paymentID, models.StatusChange{
+			Expected: payment.Status,
+			Status:   models.StatusPending,
+			Actor:    actor(ctx, models.ActorAPI),
+			Reason:   "payment link requested again",
+		})
+		if err != nil {
+			return "", clients.Conversion{}, fmt.Errorf("error changing payment status to pending: %w", err)
+		}
+		s.publish(ctx, event)
+		payment.Status = models.StatusPending
+	default: // оплаченный или возвращенный платеж повторно не оплачивается
+		return "", clients.Conversion{}, &models.TransitionError{From: payment.Status, To: models.StatusPending}
+	}
+
+	provider, err := s.providers.Get(payment.Provider) // шлюз, через который создан платеж
 	if err != nil {
-		s.logger.Error("Failed to fetch payment by ID", zap.String("payment_id", paymentID), zap.Error(err))
-		return "", fmt.Errorf("error fetching payment: %w", err)
+		return "", clients.Conversion{}, err
 	}
 	// создаем ссылку оплаты на основной счет банка
-	link, err := s.paymentClient.QuickPayment(models.CoreAccount, paymentID, "AC", convertedAmount, paymentID, paymentID, paymentID, "")
+	link, err := provider.CreateCheckout(ctx, payment, conversion.Amount)
 	if err != nil {
 		s.logger.Error("Failed to create payment link", zap.String("payment_id", paymentID), zap.Error(err))
-		return "", fmt.Errorf("error creating payment link: %w", err)
+		return "", clients.Conversion{}, fmt.Errorf("error creating payment link: %w", err)
 	}
 
-	s.paymentsQueue.Enqueue(*payment) // добавляем платеж в очередь для проверки статуса и избежания проблем с оплатой
+	// добавляем платеж в очередь для проверки статуса и выплаты, без очереди оплата не дойдет до получателя
+	if err := s.paymentsQueue.Enqueue(ctx, *payment); err != nil {
+		return "", clients.Conversion{}, fmt.Errorf("error enqueueing payment: %w", err)
+	}
 
-	return link, nil
+	return link, conversion, nil
 }
 
 // GetPayment получение оплаты (статуса)
 func (s *PaymentService) GetPayment(ctx context.Context, paymentID string) (string, error) {
 	s.logger.Info("Getting payment", zap.String("payment_id", paymentID))
 
-	status, err := s.paymentClient.CheckPaymentStatus(paymentID) // проверка статуса оплаты
+	payment, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return "error", fmt.Errorf("error fetching payment: %w", err)
+	}
+
+	provider, err := s.providers.Get(payment.Provider)
+	if err != nil {
+		return "error", err
+	}
+
+	status, credited, err := provider.CheckStatus(ctx, payment) // проверка статуса оплаты
 	if err != nil {
 		s.logger.Error("Failed to check payment status", zap.String("payment_id", paymentID), zap.Error(err))
 		return "error", fmt.Errorf("error getting payment status: %w", err)
 	}
 
-	switch status {
-	case "success": // все хорошо и деньги получены
-		payment, err := s.repo.GetPaymentByID(ctx, paymentID)
-		if err != nil {
-			return "error", fmt.Errorf("error fetching payment: %w", err)
-		}
-		if payment.Status != "COMPLETE" { // деньги получены и счет не закрыт
-			err := s.repo.UpdatePaymentStatus(ctx, paymentID, models.StatusSuccess)
-			if err != nil {
-				return "error", fmt.Errorf("error changing payment status to success: %w", err)
-			}
-		} else {
-			return "complete", nil // деньги получены и счет закрыт
-		}
-	case "pending": // деньги не получены, но ждет оплаты
-		err := s.repo.UpdatePaymentStatus(ctx, paymentID, models.StatusPending)
-		if err != nil {
-			return "pending", nil
-		}
-	case "failed": // ошибка
-		err := s.repo.UpdatePaymentStatus(ctx, paymentID, models.StatusFailed)
-		if err != nil {
-			return "error", fmt.Errorf("error changing payment status to failed: %w", err)
+	target, ok := targetStatus(status)
+	if !ok {
+		s.logger.Info("GetPayment: ", zap.String("payment_status", status))
+		return status, nil
+	}
+	if target == models.StatusSuccess {
+		if err := s.verifyPaid(ctx, payment, credited, status); err != nil {
+			return "error", err
 		}
 	}
 
+	current, err := s.syncStatus(ctx, payment, models.StatusChange{
+		Status:           target,
+		Actor:            actor(ctx, models.ActorPoller),
+		Reason:           "provider status check",
+		ProviderResponse: status,
+	})
+	if err != nil {
+		return "error", fmt.Errorf("error changing payment status to %s: %w", target, err)
+	}
+	status = strings.ToLower(string(current)) // отдаем статус платежа в базе, а не ответ шлюза
+
 	s.logger.Info("GetPayment: ", zap.String("payment_status", status))
 	return status, nil
 }
 
-// CreatePayment создание счета оплаты
-func (s *PaymentService) CreatePayment(ctx context.Context, fromUserID, toUserID string, amount float64, currency string) (string, error) {
-	s.logger.Info("Creating payment", zap.String("user_id", fromUserID), zap.Float64("amount", amount), zap.String("currency", currency))
+// HandleProviderNotification применение статуса из уведомления шлюза о платеже, details сохраняются в истории статусов.
+// paid - сумма, зачисленная на основной счет. Оплата, не совпавшая с суммой платежа, не применяется:
+// платеж остается неоплаченным и разбирается вручную по сверке.
+func (s *PaymentService) HandleProviderNotification(ctx context.Context, providerName, paymentID, providerStatus string, paid models.Money, details string) (models.PaymentStatus, error) {
+	s.logger.Info("Handling provider notification", zap.String("provider", providerName), zap.String("payment_id", paymentID), zap.String("provider_status", providerStatus))
+
+	payment, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching payment: %w", err)
+	}
+	if payment.Provider != providerName { // уведомление от шлюза, через который платеж не создавался
+		return "", fmt.Errorf("%w: payment %s belongs to provider %s", ErrProviderMismatch, paymentID, payment.Provider)
+	}
+
+	target, ok := targetStatus(providerStatus)
+	if !ok {
+		return "", fmt.Errorf("unexpected provider status: %s", providerStatus)
+	}
+	if target == models.StatusSuccess {
+		if err := s.verifyPaid(ctx, payment, paid, details); err != nil {
+			return "", err
+		}
+	}
+
+	current, err := s.syncStatus(ctx, payment, models.StatusChange{
+		Status:           target,
+		Actor:            models.ActorNotification + ":" + providerName,
+		Reason:           "provider notification",
+		ProviderResponse: details,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error changing payment status to %s: %w", target, err)
+	}
+	return current, nil
+}
+
+// verifyPaid проверка суммы перед переводом в SUCCESS, несовпадение логируется и учитывается в метрике
+func (s *PaymentService) verifyPaid(ctx context.Context, payment *models.Payment, paid models.Money, details string) error {
+	err := s.checkPaidAmount(ctx, payment, paid)
+	if errors.Is(err, ErrPaidAmountMismatch) {
+		metrics.PaidAmountMismatches.WithLabelValues(payment.Provider).Inc()
+		s.logger.Warn("Paid amount does not match payment", zap.String("payment_id", payment.ID), zap.Stringer("paid", paid), zap.String("paid_currency", paid.Currency), zap.String("details", details), zap.Error(err))
+	}
+	return err
+}
+
+// checkPaidAmount сверка зачисленной суммы с платежом. Шлюз удерживает из зачисления комиссию providerCommission,
+// поэтому рублевый платеж считается оплаченным, если зачислено не больше его суммы и не меньше суммы без комиссии,
+// остальные - если зачислено не меньше суммы в рублях по текущему курсу без комиссии и допуска paidRateTolerance
+func (s *PaymentService) checkPaidAmount(ctx context.Context, payment *models.Payment, paid models.Money) error {
+	if paid.Currency != "RUB" {
+		return fmt.Errorf("%w: paid %s %s, expected RUB", ErrPaidAmountMismatch, paid, paid.Currency)
+	}
+	if payment.Amount.Currency == "RUB" {
+		expected := payment.Amount.MinorUnits
+		if paid.MinorUnits > expected || paid.MinorUnits < expected-percentCeil(expected, providerCommission) {
+			return fmt.Errorf("%w: credited %s, expected %s less commission", ErrPaidAmountMismatch, paid, payment.Amount)
+		}
+		return nil
+	}
+
+	conversion, err := s.converter.ConvertToRub(ctx, payment.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to convert amount: %w", err)
+	}
+	expected := conversion.Amount.MinorUnits
+	if paid.MinorUnits < expected-percentCeil(expected, providerCommission+paidRateTolerance) {
+		return fmt.Errorf("%w: credited %s, expected about %s RUB less commission", ErrPaidAmountMismatch, paid, conversion.Amount)
+	}
+	return nil
+}
+
+// percentCeil percent процентов от amount с округлением вверх до минорной единицы
+func percentCeil(amount, percent int64) int64 {
+	return (amount*percent + 99) / 100
+}
+
+// targetStatus статус платежа, соответствующий статусу шлюза
+func targetStatus(providerStatus string) (models.PaymentStatus, bool) {
+	switch providerStatus {
+	case clients.ProviderStatusSuccess: // все хорошо и деньги получены
+		return models.StatusSuccess, true
+	case clients.ProviderStatusPending: // деньги не получены, но ждет оплаты
+		return models.StatusPending, true
+	case clients.ProviderStatusFailed: // ошибка
+		return models.StatusFailed, true
+	}
+	return "", false
+}
+
+// syncStatus перевод платежа в статус, полученный от шлюза.
+// Запрещенные переходы (например, поздний pending для закрытого счета) не применяются,
+// а при конфликте возвращается статус, который успел записать другой обработчик.
+func (s *PaymentService) syncStatus(ctx context.Context, payment *models.Payment, change models.StatusChange) (models.PaymentStatus, error) {
+	target := change.Status
+	if payment.Status == target {
+		return target, nil
+	}
+	if !models.CanTransition(payment.Status, target) {
+		s.logger.Info("Ignoring provider status", zap.String("payment_id", payment.ID), zap.String("current", string(payment.Status)), zap.String("provider_status", string(target)))
+		return payment.Status, nil
+	}
+
+	change.Expected = payment.Status
+	event, err := s.repo.UpdatePaymentStatus(ctx, payment.ID, change)
+	var conflict *repository.StatusConflictError
+	if errors.As(err, &conflict) {
+		return conflict.Actual, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s.publish(ctx, event)
+	if target == models.StatusSuccess { // ожидающий платеж отложен в очереди до следующего опроса, а просроченный убран из нее совсем
+		paid := *payment
+		paid.Status = target
+		if err := s.paymentsQueue.Enqueue(ctx, paid); err != nil {
+			s.logger.Error("Failed to enqueue paid payment", zap.String("payment_id", payment.ID), zap.Error(err))
+		}
+	}
+	return target, nil
+}
+
+// CreatePayment создание счета оплаты, повтор с тем же idempotencyKey возвращает уже созданный платеж
+func (s *PaymentService) CreatePayment(ctx context.Context, fromUserID, toUserID string, amount models.Money, providerName, idempotencyKey string) (string, error) {
+	s.logger.Info("Creating payment", zap.String("user_id", fromUserID), zap.Stringer("amount", amount), zap.String("currency", amount.Currency), zap.String("provider", providerName))
+
+	if _, err := models.LookupCurrency(amount.Currency); err != nil {
+		return "", err
+	}
+	if !amount.IsPositive() {
+		return "", ErrInvalidAmount
+	}
+
+	provider, err := s.providers.Get(providerName) // платеж запоминает шлюз, чтобы проверки и выплаты шли через него же
+	if err != nil {
+		return "", err
+	}
+
+	fee, err := s.fees.Fee(amount) // комиссия фиксируется при создании, смена правил не меняет ее у старых платежей
+	if err != nil {
+		return "", err
+	}
 
-	paymentID, err := s.repo.CreatePayment(ctx, fromUserID, toUserID, amount, currency)
+	requestFingerprint := fingerprint(fromUserID, toUserID, amount.String(), amount.Currency, provider.Name())
+	paymentID, err := idempotent(ctx, s, operationCreatePayment, idempotencyKey, requestFingerprint, func() (string, error) {
+		return s.createPayment(ctx, fromUserID, toUserID, amount, fee, provider.Name())
+	})
 	if err != nil {
 		s.logger.Error("Failed to create payment", zap.Error(err))
 		return "", err
@@ -120,32 +359,129 @@ func (s *PaymentService) CreatePayment(ctx context.Context, fromUserID, toUserID
 	return paymentID, nil
 }
 
-// RefundPayment возврат средств
-func (s *PaymentService) RefundPayment(ctx context.Context, paymentID string) error {
-	s.logger.Info("Refunding payment", zap.String("payment_id", paymentID))
+// createPayment проверка правилами риска и создание платежа, решение записывается в журнал.
+// Повтор по ключу идемпотентности не проверяется заново, чтобы созданный платеж не учитывался в лимитах дважды.
+func (s *PaymentService) createPayment(ctx context.Context, fromUserID, toUserID string, amount, fee models.Money, provider string) (string, error) {
+	decision, err := s.risk.Evaluate(ctx, fromUserID, toUserID, amount)
+	if err != nil {
+		return "", fmt.Errorf("error evaluating risk rules: %w", err)
+	}
+	if decision.Decision != models.RiskAllow {
+		s.risk.Record(ctx, decision)
+		return "", &models.RiskError{Decision: decision}
+	}
+
+	paymentID, err := s.repo.CreatePayment(ctx, fromUserID, toUserID, amount, fee, provider)
+	if err != nil {
+		return "", err
+	}
+	decision.PaymentID = paymentID
+	s.risk.Record(ctx, decision)
+	return paymentID, nil
+}
+
+// RefundPayment возврат суммы amount отправителю, нулевая сумма означает весь невозвращенный остаток.
+// Повтор с тем же idempotencyKey возвращает уже созданный возврат. Если шлюз не ответил определенно,
+// возврат возвращается в статусе PENDING и завершается позже по истории операций шлюза.
+func (s *PaymentService) RefundPayment(ctx context.Context, paymentID string, amount models.Money, reason, initiatorID, idempotencyKey string) (*models.Refund, error) {
+	requestFingerprint := fingerprint(paymentID, amount.String(), amount.Currency, reason, initiatorID)
+	return idempotent(ctx, s, operationRefundPayment, idempotencyKey, requestFingerprint, func() (*models.Refund, error) {
+		return s.refundPayment(ctx, paymentID, amount, reason, initiatorID)
+	})
+}
+
+// refundPayment резервирование возврата и перевод средств через шлюз платежа
+func (s *PaymentService) refundPayment(ctx context.Context, paymentID string, amount models.Money, reason, initiatorID string) (*models.Refund, error) {
+	s.logger.Info("Refunding payment", zap.String("payment_id", paymentID), zap.Stringer("amount", amount), zap.String("initiator_id", initiatorID))
 
 	payment, err := s.repo.GetPaymentByID(ctx, paymentID) // получение данных о счете
 	if err != nil {
 		s.logger.Error("Failed to get payment by ID", zap.String("payment_id", paymentID), zap.Error(err))
-		return fmt.Errorf("error fetching payment by ID: %w", err)
+		return nil, fmt.Errorf("error fetching payment by ID: %w", err)
+	}
+	if amount.IsZero() && amount.Currency == "" { // полный возврат в валюте платежа
+		amount.Currency = payment.Amount.Currency
 	}
 
-	if payment.Status != "COMPLETE" {
-		err = s.repo.UpdatePaymentStatus(ctx, paymentID, "REFUNDED") // обновление статуса счета
-		if err != nil {
-			return fmt.Errorf("error updating payment status: %w", err)
-		}
-		// создание счета с инвертированными получателями, чтобы счет банка не терял денег и все было легально
-		newPaymentID, err := s.repo.CreatePayment(ctx, payment.ToUserID, payment.FromUserID, payment.Amount, payment.Currency)
-		if err != nil {
-			s.logger.Error("Failed to create new payment", zap.String("payment_id", newPaymentID), zap.Error(err))
-			return fmt.Errorf("error creating payment: %w", err)
-		}
+	provider, err := s.providers.Get(payment.Provider) // возврат идет через тот же шлюз, что и оплата
+	if err != nil {
+		return nil, err
+	}
 
-		s.logger.Info("Payment refund process initiated successfully", zap.String("new_payment_id", newPaymentID))
-		return nil
+	refund, err := s.refunds.CreateRefund(ctx, paymentID, amount, reason, initiatorID) // сумма резервируется до перевода
+	if errors.Is(err, models.ErrInvalidTransition) {
+		return nil, fmt.Errorf("payment is not paid or already paid out: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// отключение клиента не прерывает перевод и его запись: иначе исход перевода неизвестен
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refundTimeout)
+	defer cancel()
+
+	receiver, err := s.wallets.WalletID(ctx, payment.FromUserID)
+	if err != nil {
+		s.failRefund(ctx, refund, err)
+		return nil, fmt.Errorf("error getting sender wallet: %w", err)
+	}
+
+	reference, err := provider.Refund(ctx, payment, refund, receiver)
+	if errors.Is(err, clients.ErrProviderRejected) {
+		s.failRefund(ctx, refund, err)
+		return nil, fmt.Errorf("error refunding payment: %w", err)
 	}
-	return fmt.Errorf("payment has not been paid")
+	if err != nil { // деньги могли уйти: сумма остается зарезервированной, возврат завершит ResolvePendingRefunds
+		s.logger.Warn("Refund outcome unknown, left pending", zap.String("refund_id", refund.ID), zap.String("payment_id", paymentID), zap.Error(err))
+		return refund, nil
+	}
+
+	refund, err = s.completeRefund(ctx, refund.ID, models.RefundStatusSucceeded, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Payment refunded", zap.String("payment_id", paymentID), zap.String("refund_id", refund.ID), zap.Stringer("amount", refund.Amount))
+	return refund, nil
+}
+
+// completeRefund завершение возврата и публикация смены статуса, если платеж возвращен полностью
+func (s *PaymentService) completeRefund(ctx context.Context, refundID string, status models.RefundStatus, reference string) (*models.Refund, error) {
+	refund, event, err := s.refunds.CompleteRefund(ctx, refundID, status, reference)
+	if err != nil {
+		return nil, err
+	}
+	if event != nil {
+		s.publish(ctx, event)
+	}
+	return refund, nil
+}
+
+// failRefund отметка о неудачном возврате, его сумма снова доступна для возврата
+func (s *PaymentService) failRefund(ctx context.Context, refund *models.Refund, cause error) {
+	s.logger.Error("Refund failed", zap.String("refund_id", refund.ID), zap.String("payment_id", refund.PaymentID), zap.Error(cause))
+	if _, _, err := s.refunds.CompleteRefund(ctx, refund.ID, models.RefundStatusFailed, ""); err != nil {
+		s.logger.Error("Failed to mark refund as failed", zap.String("refund_id", refund.ID), zap.Error(err))
+	}
+}
+
+// ListRefunds возвраты платежа и их общая сумма
+func (s *PaymentService) ListRefunds(ctx context.Context, paymentID string) ([]*models.Refund, models.Money, error) {
+	refunds, err := s.refunds.ListRefunds(ctx, paymentID)
+	if err != nil {
+		return nil, models.Money{}, err
+	}
+
+	refunded, err := s.refunds.RefundedAmount(ctx, paymentID)
+	if err != nil {
+		return nil, models.Money{}, err
+	}
+	return refunds, refunded, nil
+}
+
+// RefundedAmount сумма возвратов платежа, которые прошли или проводятся
+func (s *PaymentService) RefundedAmount(ctx context.Context, paymentID string) (models.Money, error) {
+	return s.refunds.RefundedAmount(ctx, paymentID)
 }
 
 // GetPaymentByID получение данных о счете по номеру
@@ -162,34 +498,111 @@ func (s *PaymentService) GetPaymentByID(ctx context.Context, paymentID string) (
 	return payment, nil
 }
 
-// GetPaymentHistory получение истории счетов пользователя
-func (s *PaymentService) GetPaymentHistory(ctx context.Context, userID string, page, limit int) ([]*models.Payment, error) {
-	s.logger.Info("Getting payment history", zap.String("user_id", userID), zap.Int("page", page), zap.Int("limit", limit))
+// GetPaymentHistory страница истории счетов пользователя по фильтрам и курсору
+func (s *PaymentService) GetPaymentHistory(ctx context.Context, query models.HistoryQuery) (*models.HistoryPage, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Getting payment history", zap.String("user_id", query.UserID), zap.String("direction", string(query.Direction)), zap.Bool("cursor", query.Cursor != nil), zap.Int("limit", query.Limit))
 
-	payments, err := s.repo.GetPaymentHistory(ctx, userID, page, limit)
+	page, err := s.repo.GetPaymentHistory(ctx, query)
 	if err != nil {
-		s.logger.Error("Failed to get payment history", zap.String("user_id", userID), zap.Error(err))
+		s.logger.Error("Failed to get payment history", zap.String("user_id", query.UserID), zap.Error(err))
 		return nil, err
 	}
 
-	s.logger.Info("Payment history retrieved", zap.String("user_id", userID))
-	return payments, nil
+	s.logger.Info("Payment history retrieved", zap.String("user_id", query.UserID), zap.Int("count", len(page.Payments)))
+	return page, nil
 }
 
-// UpdatePaymentStatus обновление статуса счета
-func (s *PaymentService) UpdatePaymentStatus(ctx context.Context, paymentID string, status models.PaymentStatus) error {
-	s.logger.Info("Updating payment status", zap.String("payment_id", paymentID), zap.String("status", string(status)))
+// UpdatePaymentStatus обновление статуса счета, если его текущий статус change.Expected
+func (s *PaymentService) UpdatePaymentStatus(ctx context.Context, paymentID string, change models.StatusChange) error {
+	s.logger.Info("Updating payment status", zap.String("payment_id", paymentID), zap.String("expected", string(change.Expected)), zap.String("status", string(change.Status)), zap.String("actor", change.Actor))
 
-	err := s.repo.UpdatePaymentStatus(ctx, paymentID, status)
+	event, err := s.repo.UpdatePaymentStatus(ctx, paymentID, change)
 	if err != nil {
-		s.logger.Error("Failed to update payment status", zap.String("payment_id", paymentID), zap.String("status", string(status)), zap.Error(err))
+		s.logger.Error("Failed to update payment status", zap.String("payment_id", paymentID), zap.String("status", string(change.Status)), zap.Error(err))
 		return err
 	}
+	s.publish(ctx, event)
 
-	s.logger.Info("Payment status updated successfully", zap.String("payment_id", paymentID), zap.String("status", string(status)))
+	s.logger.Info("Payment status updated successfully", zap.String("payment_id", paymentID), zap.String("status", string(change.Status)))
 	return nil
 }
 
+// GetPaymentEvents история статусов платежа
+func (s *PaymentService) GetPaymentEvents(ctx context.Context, paymentID string) ([]*models.PaymentEvent, error) {
+	s.logger.Info("Getting payment events", zap.String("payment_id", paymentID))
+
+	events, err := s.repo.GetPaymentEvents(ctx, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment events: %w", err)
+	}
+	return events, nil
+}
+
+// WatchPayment отправка текущего статуса платежа, а затем каждой его смены, пока ctx не отменен
+// или платеж не дошел до конца обычного пути: COMPLETE, EXPIRED или REFUNDED
+func (s *PaymentService) WatchPayment(ctx context.Context, paymentID string, send func(*models.PaymentEvent) error) error {
+	s.logger.Info("Watching payment", zap.String("payment_id", paymentID))
+
+	events, unsubscribe := s.events.Subscribe(paymentID) // подписываемся до чтения истории, чтобы не пропустить смену между ними
+	defer unsubscribe()
+
+	history, err := s.repo.GetPaymentEvents(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("failed to get payment events: %w", err)
+	}
+	if len(history) == 0 {
+		return repository.ErrPaymentNotFound
+	}
+
+	last := history[len(history)-1]
+	if err := send(last); err != nil {
+		return err
+	}
+
+	for {
+		if last.NewStatus.IsTerminal() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event.ID <= last.ID { // уже отправлено вместе с историей
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+			last = event
+		}
+	}
+}
+
+// publish рассылка смены статуса наблюдателям, ошибка рассылки не отменяет саму смену
+func (s *PaymentService) publish(ctx context.Context, event *models.PaymentEvent) {
+	if s.events == nil || event == nil {
+		return
+	}
+	if err := s.events.Publish(ctx, event); err != nil {
+		s.logger.Warn("Failed to publish payment event", zap.String("payment_id", event.PaymentID), zap.Int64("event_id", event.ID), zap.Error(err))
+	}
+}
+
+// actor пользователь запроса, если он есть, иначе компонент fallback
+func actor(ctx context.Context, fallback string) string {
+	if user, ok := auth.UserFromContext(ctx); ok {
+		return models.UserActor(user.ID)
+	}
+	return fallback
+}
+
 // GetActivePayments Получение активных счетов пользователя
 func (s *PaymentService) GetActivePayments(ctx context.Context, userID string) ([]*models.Payment, error) {
 	s.logger.Info("Getting active payments", zap.String("user_id", userID))
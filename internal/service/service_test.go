@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/db"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"go.uber.org/zap"
+)
+
+// fixedRateConverter конвертер с постоянным курсом
+type fixedRateConverter struct {
+	rate float64
+}
+
+func (c fixedRateConverter) Convert(ctx context.Context, amount models.Money, to string) (clients.Conversion, error) {
+	converted, err := amount.Convert(c.rate, to)
+	return clients.Conversion{Amount: converted, Rate: clients.ExchangeRate{From: amount.Currency, To: to, Rate: c.rate}}, err
+}
+
+func (c fixedRateConverter) ConvertToRub(ctx context.Context, amount models.Money) (clients.Conversion, error) {
+	return c.Convert(ctx, amount, "RUB")
+}
+
+func TestCheckPaidAmount(t *testing.T) {
+	s := &PaymentService{converter: fixedRateConverter{rate: 100}}
+	ctx := context.Background()
+	payment := &models.Payment{ID: pendingID, Amount: rub(10000)}
+
+	assert.NoError(t, s.checkPaidAmount(ctx, payment, rub(10000)))
+	assert.NoError(t, s.checkPaidAmount(ctx, payment, rub(9700))) // зачислено за вычетом комиссии
+	assert.ErrorIs(t, s.checkPaidAmount(ctx, payment, rub(9699)), ErrPaidAmountMismatch)
+	assert.ErrorIs(t, s.checkPaidAmount(ctx, payment, rub(1)), ErrPaidAmountMismatch) // недоплата с верной меткой
+	assert.ErrorIs(t, s.checkPaidAmount(ctx, payment, rub(10001)), ErrPaidAmountMismatch)
+	assert.ErrorIs(t, s.checkPaidAmount(ctx, payment, models.Money{MinorUnits: 10000, Currency: "USD"}), ErrPaidAmountMismatch)
+
+	usd := &models.Payment{ID: pendingID, Amount: models.Money{MinorUnits: 1000, Currency: "USD"}} // 10 USD = 1000 RUB
+	assert.NoError(t, s.checkPaidAmount(ctx, usd, rub(100000)))
+	assert.NoError(t, s.checkPaidAmount(ctx, usd, rub(95000))) // комиссия и сдвиг курса при оплате
+	assert.ErrorIs(t, s.checkPaidAmount(ctx, usd, rub(90000)), ErrPaidAmountMismatch)
+}
+
+// statusProvider шлюз, который отдает статус оплаты и зачисленную сумму
+type statusProvider struct {
+	clients.PaymentProvider
+	credited models.Money
+}
+
+func (p *statusProvider) Name() string {
+	return "yoomoney"
+}
+
+func (p *statusProvider) CheckStatus(ctx context.Context, payment *models.Payment) (string, models.Money, error) {
+	return clients.ProviderStatusSuccess, p.credited, nil
+}
+
+func TestGetPayment_UnderpaidPollIsNotApplied(t *testing.T) {
+	providers, err := clients.NewProviderRegistry("yoomoney", &statusProvider{credited: rub(5000)}) // сумма в ссылке занижена вдвое
+	require.NoError(t, err)
+	s := &PaymentService{
+		repo:      &fakePayments{payments: map[string]*models.Payment{pendingID: {ID: pendingID, Amount: rub(10000), Status: models.StatusPending, Provider: "yoomoney"}}},
+		providers: providers,
+		logger:    zap.NewNop(),
+	}
+
+	_, err = s.GetPayment(context.Background(), pendingID) // статус не меняется: у fakePayments нет UpdatePaymentStatus
+	assert.ErrorIs(t, err, ErrPaidAmountMismatch)
+}
+
+// eventsPayments история статусов платежа для WatchPayment
+type eventsPayments struct {
+	repository.PaymentRepository
+	events []*models.PaymentEvent
+}
+
+func (f *eventsPayments) GetPaymentEvents(ctx context.Context, paymentID string) ([]*models.PaymentEvent, error) {
+	return f.events, nil
+}
+
+func TestWatchPayment_ClosesWhenPaidOut(t *testing.T) {
+	bus := db.NewLocalEventBus(zap.NewNop())
+	s := &PaymentService{
+		repo:   &eventsPayments{events: []*models.PaymentEvent{{ID: 1, PaymentID: pendingID, NewStatus: models.StatusPending}}},
+		events: bus,
+		logger: zap.NewNop(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // без закрытия потока тест упадет по таймауту
+	defer cancel()
+
+	var sent []models.PaymentStatus
+	err := s.WatchPayment(ctx, pendingID, func(event *models.PaymentEvent) error {
+		sent = append(sent, event.NewStatus)
+		if len(sent) == 1 { // подписка уже оформлена, платеж оплачивают и выплачивают
+			require.NoError(t, bus.Publish(ctx, &models.PaymentEvent{ID: 2, PaymentID: pendingID, NewStatus: models.StatusSuccess}))
+			require.NoError(t, bus.Publish(ctx, &models.PaymentEvent{ID: 3, PaymentID: pendingID, NewStatus: models.StatusComplete}))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.NoError(t, ctx.Err(), "stream must close on COMPLETE, not on timeout")
+	assert.Equal(t, []models.PaymentStatus{models.StatusPending, models.StatusSuccess, models.StatusComplete}, sent)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/models"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/webhooks"
+	"go.uber.org/zap"
+)
+
+// ErrTooManyWebhookEndpoints у пользователя уже максимум адресов вебхуков
+var ErrTooManyWebhookEndpoints = errors.New("too many webhook endpoints")
+
+const (
+	maxWebhookEndpoints  = 10  // адресов на одного пользователя
+	maxWebhookDeliveries = 100 // доставок за один запрос
+)
+
+// CreateWebhookEndpoint регистрация адреса вебхука. Ключ подписи генерируется здесь
+// и возвращается в endpoint.Secret, позже его узнать нельзя
+func (s *PaymentService) CreateWebhookEndpoint(ctx context.Context, userID, url string, eventTypes []string) (*models.WebhookEndpoint, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("%w: invalid user id", models.ErrInvalidWebhookEndpoint)
+	}
+	endpoint := &models.WebhookEndpoint{UserID: userID, URL: url, EventTypes: eventTypes}
+	if err := endpoint.Validate(s.webhooksHTTP); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.webhooks.ListWebhookEndpoints(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhookEndpoints {
+		return nil, fmt.Errorf("%w: limit is %d", ErrTooManyWebhookEndpoints, maxWebhookEndpoints)
+	}
+
+	if endpoint.Secret, err = webhooks.NewSecret(); err != nil {
+		return nil, err
+	}
+	if err := s.webhooks.CreateWebhookEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Webhook endpoint created", zap.String("endpoint_id", endpoint.ID), zap.String("user_id", userID), zap.String("url", url))
+	return endpoint, nil
+}
+
+// GetWebhookEndpoint адрес вебхука по id
+func (s *PaymentService) GetWebhookEndpoint(ctx context.Context, endpointID string) (*models.WebhookEndpoint, error) {
+	if _, err := uuid.Parse(endpointID); err != nil {
+		return nil, repository.ErrWebhookEndpointNotFound
+	}
+	return s.webhooks.GetWebhookEndpoint(ctx, endpointID)
+}
+
+// ListWebhookEndpoints адреса вебхуков пользователя
+func (s *PaymentService) ListWebhookEndpoints(ctx context.Context, userID string) ([]*models.WebhookEndpoint, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, nil
+	}
+	return s.webhooks.ListWebhookEndpoints(ctx, userID)
+}
+
+// DeleteWebhookEndpoint удаление адреса, неотправленные доставки удаляются вместе с ним
+func (s *PaymentService) DeleteWebhookEndpoint(ctx context.Context, endpointID string) error {
+	if _, err := uuid.Parse(endpointID); err != nil {
+		return repository.ErrWebhookEndpointNotFound
+	}
+	if err := s.webhooks.DeleteWebhookEndpoint(ctx, endpointID); err != nil {
+		return err
+	}
+	s.logger.Info("Webhook endpoint deleted", zap.String("endpoint_id", endpointID))
+	return nil
+}
+
+// GetWebhookDelivery доставка вебхука по id
+func (s *PaymentService) GetWebhookDelivery(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error) {
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return nil, repository.ErrWebhookDeliveryNotFound
+	}
+	return s.webhooks.GetWebhookDelivery(ctx, deliveryID)
+}
+
+// ListWebhookDeliveries журнал доставок адреса, сначала новые, пустой status - все
+func (s *PaymentService) ListWebhookDeliveries(ctx context.Context, endpointID string, status models.WebhookDeliveryStatus, limit int) ([]*models.WebhookDelivery, error) {
+	if limit <= 0 || limit > maxWebhookDeliveries {
+		limit = maxWebhookDeliveries
+	}
+	deliveries, err := s.webhooks.ListWebhookDeliveries(ctx, endpointID, status, limit)
+	if err != nil {
+		s.logger.Error("Failed to list webhook deliveries", zap.String("endpoint_id", endpointID), zap.Error(err))
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RedeliverWebhook ручной повтор доставки, исчерпавшей попытки. Отправит ее диспетчер при следующем опросе
+func (s *PaymentService) RedeliverWebhook(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := s.webhooks.RedeliverWebhook(ctx, deliveryID)
+	if err != nil {
+		s.logger.Warn("Failed to redeliver webhook", zap.String("delivery_id", deliveryID), zap.Error(err))
+		return nil, err
+	}
+	s.logger.Info("Webhook redelivery scheduled", zap.String("delivery_id", deliveryID), zap.String("event_id", delivery.Event.ID))
+	return delivery, nil
+}
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PostgresTracer спан на каждый запрос pgx. pgx принимает только один трейсер,
+// поэтому вызовы передаются дальше в next (например, в трейсер метрик)
+type PostgresTracer struct {
+	next pgx.QueryTracer
+}
+
+// NewPostgresTracer создание трейсера запросов поверх next, next может быть nil
+func NewPostgresTracer(next pgx.QueryTracer) *PostgresTracer {
+	return &PostgresTracer{next: next}
+}
+
+func (t *PostgresTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation := "QUERY"
+	if fields := strings.Fields(data.SQL); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	ctx = startChildSpan(ctx, "postgres "+operation,
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(data.SQL),
+	)
+	if t.next != nil {
+		ctx = t.next.TraceQueryStart(ctx, conn, data)
+	}
+	return ctx
+}
+
+func (t *PostgresTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if t.next != nil {
+		t.next.TraceQueryEnd(ctx, conn, data)
+	}
+	err := data.Err
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+	}
+	endChildSpan(ctx, err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+// RedisHook спан на каждую команду и конвейер go-redis
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return startChildSpan(ctx, "redis "+cmd.Name(), semconv.DBSystemRedis, semconv.DBOperationName(cmd.Name())), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endChildSpan(ctx, redisError(cmd.Err()))
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return startChildSpan(ctx, "redis pipeline", semconv.DBSystemRedis, attribute.Int("db.redis.pipeline_length", len(cmds))), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if err = redisError(cmd.Err()); err != nil {
+			break
+		}
+	}
+	endChildSpan(ctx, err)
+	return nil
+}
+
+// redisError промах по ключу ошибкой не считается
+func redisError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+type spanKey struct{}
+
+// startChildSpan спан обращения к хранилищу создается только внутри уже идущей трассировки,
+// иначе фоновые опросы очереди и подписки порождали бы по трассе на каждую команду
+func startChildSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	ctx, span := Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func endChildSpan(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(attrs...)
+	span.End()
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInit_UnknownExporter(t *testing.T) {
+	_, err := Init(context.Background(), config.Tracing{Exporter: "jaeger"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown tracing exporter")
+
+	shutdown, err := Init(context.Background(), config.Tracing{Exporter: ExporterNone})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestRedisHook_SpansOnlyInsideTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	rdb.AddHook(RedisHook{})
+
+	rdb.Set(context.Background(), "key", "value", 0) // без родительского спана трассы не будет
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	rdb.Get(ctx, "missing")
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "redis get", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Empty(t, spans[0].Events(), "cache miss must not be recorded as an error")
+}
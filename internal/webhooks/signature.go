@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки запроса вебхука
+const (
+	HeaderSignature = "X-Webhook-Signature" // t=<unix-время>,v1=<hex hmac-sha256>
+	HeaderEventID   = "X-Webhook-Id"        // id события, одинаковый во всех повторах
+	HeaderEventType = "X-Webhook-Event"
+)
+
+// ErrInvalidSignature подпись не совпала или устарела
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// NewSecret случайный ключ подписи для нового адреса
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// Sign значение заголовка подписи: HMAC-SHA256 от "<unix-время>.<тело>".
+// Время в подписи не дает переотправить перехваченный запрос позже.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + unix + ",v1=" + signature(secret, unix, body)
+}
+
+// Verify проверка подписи на стороне получателя, запросы старше tolerance отклоняются
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var unix, sign string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			sign = value
+		}
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || sign == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sign), []byte(signature(secret, unix, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signature(secret, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
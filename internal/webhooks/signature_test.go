@@ -0,0 +1,26 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret, err := NewSecret()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"event-1"}`)
+	header := Sign(secret, now, body)
+	assert.True(t, strings.HasPrefix(header, "t=1700000000,v1="))
+
+	assert.NoError(t, Verify(secret, header, body, 5*time.Minute, now.Add(time.Minute)))
+	assert.ErrorIs(t, Verify("other", header, body, 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(secret, header, []byte(`{"id":"event-2"}`), 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, now.Add(time.Hour)), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(secret, "garbage", body, 5*time.Minute, now), ErrInvalidSignature)
+}
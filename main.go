@@ -3,27 +3,44 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
-	"log"
 	"net"
+	"net/http"
 
+	"gitlab.crja72.ru/gospec/go8/payment/internal/auth"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/broker"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/clients"
 	paymentsDemon "gitlab.crja72.ru/gospec/go8/payment/internal/payment-demon"
 
 	"gitlab.crja72.ru/gospec/go8/payment/internal/config"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/db"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/fees"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/gateway"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/handlers"
+	appHealth "gitlab.crja72.ru/gospec/go8/payment/internal/health"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/lifecycle"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/metrics"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/payment-service/proto"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/repository"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/risk"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/service"
+	"gitlab.crja72.ru/gospec/go8/payment/internal/tracing"
 	"gitlab.crja72.ru/gospec/go8/payment/internal/utils"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 //go:embed migrations/*
 var migrations embed.FS
 
+//go:embed proto/payment.swagger.json
+var openAPISpec []byte
+
 func main() {
 	cfg, err := config.LoadConfig() // создаем логгер
 	if err != nil {
@@ -34,46 +51,145 @@ func main() {
 	defer logger.Sync()
 
 	ctx := context.WithValue(context.Background(), "logger", logger)
+	app := lifecycle.New(logger, cfg.Server.ShutdownTimeout) // компоненты останавливаются в обратном порядке
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing) // настраиваем экспорт спанов
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	app.OnStop("tracing", shutdownTracing) // оставшиеся спаны отправляются последними
 
 	dbConn, err := db.NewPostgres(ctx, cfg, logger) // создаем подключение к БД
 	if err != nil {
 		logger.Fatal("Failed to initialize PostgreSQL", zap.Error(err))
 	}
-
-	defer func() {
-		if dbConn != nil {
-			dbConn.Close()
-			logger.Info("Database connection closed")
-		}
-	}()
+	app.OnStop("postgres", func(context.Context) error {
+		dbConn.Close()
+		return nil
+	})
 
 	if err := db.MigratePostgres(ctx, dbConn, logger, migrations); err != nil { // выполняем миграции
 		logger.Fatal("Failed to apply migrations", zap.Error(err))
 	}
 
-	const grpcServerAddress = "localhost:8888"
+	rdb := db.InitRedis(cfg, logger)
+	app.OnStop("redis", func(context.Context) error { return rdb.Close() })
 
-	authClient, err := clients.NewAuthClient(grpcServerAddress) // создаем клиент для авторизации
+	authClient, err := clients.NewAuthClient(cfg.Auth.Address) // создаем клиент для авторизации
 	if err != nil {
-		log.Fatalf("Failed to create AuthClient: %v", err)
+		logger.Fatal("Failed to create AuthClient", zap.Error(err))
 	}
-	defer authClient.Close()
+	app.OnStop("auth client", func(context.Context) error {
+		authClient.Close()
+		return nil
+	})
 
-	rdb := db.InitRedis(cfg, logger)
-	defer rdb.Close()
+	paymentsQueue, err := db.NewQueue(cfg, rdb, logger) // создаем очередь
+	if err != nil {
+		logger.Fatal("Failed to initialize payments queue", zap.Error(err))
+	}
+	app.OnStop("payments queue", paymentsQueue.Close)
+
+	eventBroker, err := broker.New(ctx, cfg.Broker, logger) // брокер доменных событий для других сервисов
+	if err != nil {
+		logger.Fatal("Failed to initialize broker", zap.Error(err))
+	}
+	app.OnStop("broker", func(context.Context) error { return eventBroker.Close() })
+
+	events := db.NewRedisEventBus(rdb, logger) // рассылка смен статусов между экземплярами сервиса
+	app.Add("event bus", func(ctx context.Context) error {
+		events.Start(ctx)
+		return nil
+	}, nil)
+
+	converter := clients.NewRatesConverter(rdb, cfg.Rates.CacheTTL, logger, clients.NewForexClient(cfg), clients.NewExchangeRateAPIClient(cfg)) // создаем конвертер с кэшем и запасным источником курсов
+
+	providers, err := clients.NewProviderRegistry(cfg.Providers.Default, clients.NewYooMoneyClient(cfg)) // создаем реестр платежных шлюзов
+	if err != nil {
+		logger.Fatal("Failed to initialize payment providers", zap.Error(err))
+	}
 
-	paymentsQueue := db.NewPaymentsQueue() // создаем очередь
+	feeCalculator, err := fees.NewCalculator(cfg.Fees) // комиссия платформы с платежей
+	if err != nil {
+		logger.Fatal("Failed to load fee rules", zap.Error(err))
+	}
+	riskEngine := risk.NewEngine(cfg.Risk, repository.NewRiskRepository(dbConn, logger), logger) // правила риска перед созданием платежа
 
-	converter := clients.NewForexClient(cfg)        // создаем клиент для конвертации
-	paymentClient := clients.NewYooMoneyClient(cfg) // создаем клиент для платежей
+	repo := repository.NewPaymentRepository(dbConn, logger) // создаем репозиторий
+	var paymentCache repository.PaymentCacheInvalidator = repository.NoPaymentCache{}
+	if cfg.Cache.Enabled { // кэш в Redis поверх репозитория
+		cached := repository.NewCachedPaymentRepository(repo, rdb, cfg.Cache, logger)
+		repo, paymentCache = cached, cached
+	}
+	idempotency := repository.NewIdempotencyRepository(dbConn, logger, rdb, cfg.Idempotency.TTL, cfg.Idempotency.LockTimeout)                                                                                                                                 // создаем хранилище ключей идемпотентности
+	refunds := repository.NewRefundRepository(dbConn, logger, paymentCache)                                                                                                                                                                                   // создаем хранилище возвратов
+	deadLetters := repository.NewDeadLetterRepository(dbConn, logger)                                                                                                                                                                                         // платежи, на которых демон исчерпал попытки
+	reconciliations := repository.NewReconciliationRepository(dbConn, logger)                                                                                                                                                                                 // отчеты сверки с историей шлюзов
+	webhooks := repository.NewWebhookRepository(dbConn, logger)                                                                                                                                                                                               // адреса вебхуков и журнал доставок
+	ledger := repository.NewLedgerRepository(dbConn, logger)                                                                                                                                                                                                  // остатки счетов по главной книге
+	svc := service.NewPaymentService(repo, idempotency, refunds, deadLetters, reconciliations, webhooks, ledger, logger, converter, providers, paymentsQueue, authClient, events, feeCalculator, riskEngine, cfg.Expiration.Lifetime, cfg.Webhooks.AllowHTTP) // создаем сервис
+
+	demon := paymentsDemon.NewPaymentDemon(*svc, repo, deadLetters, providers, paymentsQueue, logger, authClient, cfg.Demon) // создаем демон
+	app.Add("payment demon", func(ctx context.Context) error {
+		demon.Start(ctx) // при остановке дорабатывает платеж, взятый в работу
+		return nil
+	}, nil)
+
+	if cfg.Expiration.Lifetime > 0 { // неоплаченные платежи истекают
+		sweeper := paymentsDemon.NewExpirationSweeper(svc, cfg.Expiration, logger)
+		app.Add("expiration sweeper", sweeper.Run, nil)
+	}
 
-	repo := repository.NewPaymentRepository(dbConn, logger, rdb)                            // создаем репозиторий
-	svc := service.NewPaymentService(repo, logger, converter, paymentClient, paymentsQueue) // создаем сервис
+	relay := paymentsDemon.NewOutboxRelay(repository.NewOutboxRepository(dbConn, logger), eventBroker, cfg.Outbox, logger) // публикация доменных событий из outbox
+	app.Add("outbox relay", relay.Run, nil)
 
-	demon := paymentsDemon.NewPaymentDemon(*svc, repo, paymentClient, paymentsQueue, logger, authClient) // создаем демон
-	go demon.Start(ctx)
+	dispatcher := paymentsDemon.NewWebhookDispatcher(webhooks, cfg.Webhooks, logger) // отправка вебхуков интеграторам
+	app.Add("webhook dispatcher", dispatcher.Run, nil)
 
-	grpcServer := grpc.NewServer()                                 // создаем сервер
+	refundChecker := paymentsDemon.NewRefundChecker(svc, cfg.Refunds, logger) // возвраты, исход которых шлюз не сообщил
+	app.Add("refund checker", refundChecker.Run, nil)
+
+	if cfg.Reconciliation.Interval > 0 { // сверка с историей операций шлюзов
+		reconciler := paymentsDemon.NewReconciler(svc, providers, cfg.Reconciliation, logger)
+		app.Add("reconciliation", reconciler.Run, nil)
+	}
+
+	if err := metrics.RegisterQueue(paymentsQueue.Stats); err != nil {
+		logger.Fatal("Failed to register queue metrics", zap.Error(err))
+	}
+
+	gatewayConn, err := gateway.Dial(fmt.Sprintf("localhost:%d", cfg.Server.Port)) // REST-шлюз ходит в gRPC-сервер этого же процесса
+	if err != nil {
+		logger.Fatal("Failed to create gateway connection", zap.Error(err))
+	}
+	app.OnStop("gateway connection", func(context.Context) error { return gatewayConn.Close() })
+	gatewayHandler, err := gateway.NewHandler(ctx, gatewayConn)
+	if err != nil {
+		logger.Fatal("Failed to initialize REST gateway", zap.Error(err))
+	}
+
+	mux := http.NewServeMux() // HTTP-сервер для REST-API, уведомлений платежных шлюзов и метрик
+	handlers.NewNotificationHandler(svc, cfg.Yoomoney.NotificationSecret, logger).Register(mux)
+	mux.Handle("/v1/", gatewayHandler)
+	mux.Handle("/openapi.json", gateway.OpenAPIHandler(openAPISpec))
+	mux.Handle("/metrics", metrics.Handler())
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Server.HTTPPort), Handler: otelhttp.NewHandler(mux, "http",
+		otelhttp.WithFilter(func(r *http.Request) bool { return r.URL.Path != "/metrics" }), // сбор метрик не трассируем
+	)}
+	app.Add("http server", func(context.Context) error {
+		logger.Info(fmt.Sprintf("Starting HTTP server on port %d", cfg.Server.HTTPPort))
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}, httpServer.Shutdown)
+
+	public := []string{healthpb.Health_Check_FullMethodName, healthpb.Health_Watch_FullMethodName} // health-проверки без токена
+	grpcServer := grpc.NewServer(                                                                  // создаем сервер с трассировкой, метриками и проверкой токена
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor(), auth.UnaryServerInterceptor(authClient, cfg.Auth.Admins, logger, public...)),
+		grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor(), auth.StreamServerInterceptor(authClient, cfg.Auth.Admins, logger, public...)),
+	)
 	paymentHandler := handlers.NewPaymentHandler(svc, logger)      // создаем обработчик
 	proto.RegisterPaymentServiceServer(grpcServer, paymentHandler) // подключаем обработчик
 
@@ -81,8 +197,34 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to start gRPC listener", zap.Error(err))
 	}
-	logger.Info(fmt.Sprintf("Starting gRPC server on port %d", cfg.Server.Port))
-	if err := grpcServer.Serve(listener); err != nil {
-		logger.Fatal("Failed to start gRPC server", zap.Error(err))
+	app.Add("grpc server", func(context.Context) error {
+		logger.Info(fmt.Sprintf("Starting gRPC server on port %d", cfg.Server.Port))
+		return grpcServer.Serve(listener)
+	}, func(ctx context.Context) error {
+		events.Close() // иначе GracefulStop будет ждать открытые потоки WatchPayment
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcServer.Stop() // не дождались завершения запросов, обрываем
+		}
+		return nil
+	})
+
+	healthServer := health.NewServer() // стандартный gRPC health-сервис
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	checker := appHealth.NewChecker(healthServer, cfg.Server.HealthInterval, logger, proto.PaymentService_ServiceDesc.ServiceName)
+	checker.Add("postgres", dbConn.Ping)
+	checker.Add("redis", func(ctx context.Context) error { return rdb.Ping(ctx).Err() })
+	checker.Add("auth", authClient.Check)
+	app.Add("health", checker.Run, checker.Shutdown) // при остановке первым переходит в NOT_SERVING
+
+	if err := app.Run(ctx); err != nil {
+		logger.Fatal("Service stopped with errors", zap.Error(err))
 	}
+	logger.Info("Service stopped")
 }
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE payments ADD COLUMN provider varchar(32) NOT NULL DEFAULT 'yoomoney';
+
+-- +goose Down
+ALTER TABLE payments DROP COLUMN IF EXISTS provider;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- суммы хранятся точно, в минорных единицах валюты (копейках, центах)
+ALTER TABLE payments
+	ALTER COLUMN amount TYPE bigint USING round(amount * CASE
+		WHEN currency IN ('JPY', 'KRW', 'VND', 'CLP', 'ISK', 'PYG', 'UGX') THEN 1
+		WHEN currency IN ('KWD', 'BHD', 'OMR', 'JOD', 'TND', 'IQD', 'LYD') THEN 1000
+		ELSE 100
+	END)::bigint;
+
+ALTER TABLE payments RENAME COLUMN amount TO amount_minor;
+
+-- +goose Down
+ALTER TABLE payments RENAME COLUMN amount_minor TO amount;
+
+ALTER TABLE payments
+	ALTER COLUMN amount TYPE double precision USING amount::double precision / CASE
+		WHEN currency IN ('JPY', 'KRW', 'VND', 'CLP', 'ISK', 'PYG', 'UGX') THEN 1
+		WHEN currency IN ('KWD', 'BHD', 'OMR', 'JOD', 'TND', 'IQD', 'LYD') THEN 1000
+		ELSE 100
+	END;
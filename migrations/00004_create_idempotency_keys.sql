@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE idempotency_keys (
+	operation varchar(32) NOT NULL,
+	key varchar(255) NOT NULL,
+	fingerprint varchar(64) NOT NULL,
+	response jsonb,
+	completed boolean NOT NULL DEFAULT FALSE,
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (operation, key)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_keys;
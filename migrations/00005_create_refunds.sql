@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE refunds (
+	id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+	payment_id uuid NOT NULL REFERENCES payments (id),
+	amount_minor bigint NOT NULL CHECK (amount_minor > 0),
+	currency varchar(3) NOT NULL,
+	reason text NOT NULL DEFAULT '',
+	status varchar(20) NOT NULL DEFAULT 'PENDING',
+	initiator_id varchar(64) NOT NULL DEFAULT '',
+	provider_reference varchar(255) NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX refunds_payment_id_idx ON refunds (payment_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS refunds;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE payment_events (
+	id bigserial PRIMARY KEY,
+	payment_id uuid NOT NULL REFERENCES payments (id),
+	old_status varchar(20) NOT NULL DEFAULT '',
+	new_status varchar(20) NOT NULL,
+	actor varchar(64) NOT NULL,
+	reason text NOT NULL DEFAULT '',
+	provider_response text NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX payment_events_payment_id_idx ON payment_events (payment_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS payment_events;
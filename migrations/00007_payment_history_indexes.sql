@@ -0,0 +1,10 @@
+-- +goose Up
+-- история пользователя выбирается по отправителю и получателю с сортировкой (created_at, id) от новых к старым
+CREATE INDEX payments_from_user_history_idx ON payments (from_user_id, created_at DESC, id DESC);
+
+CREATE INDEX payments_to_user_history_idx ON payments (to_user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS payments_to_user_history_idx;
+
+DROP INDEX IF EXISTS payments_from_user_history_idx;
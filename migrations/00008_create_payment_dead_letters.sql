@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE payment_dead_letters (
+	payment_id uuid PRIMARY KEY REFERENCES payments (id),
+	stage varchar(20) NOT NULL,
+	last_error text NOT NULL DEFAULT '',
+	attempts integer NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS payment_dead_letters;
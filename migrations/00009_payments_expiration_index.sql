@@ -0,0 +1,8 @@
+-- +goose Up
+-- поиск неоплаченных платежей с истекшим сроком
+CREATE INDEX payments_unpaid_created_idx ON payments (created_at)
+WHERE
+	status IN ('PENDING', 'FAILED');
+
+-- +goose Down
+DROP INDEX IF EXISTS payments_unpaid_created_idx;
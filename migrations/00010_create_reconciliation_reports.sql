@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE reconciliation_reports (
+	id uuid PRIMARY KEY,
+	provider varchar(50) NOT NULL,
+	window_from timestamptz NOT NULL,
+	window_to timestamptz NOT NULL,
+	operations integer NOT NULL,
+	auto_fix boolean NOT NULL DEFAULT FALSE,
+	mismatches jsonb NOT NULL DEFAULT '[]',
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX reconciliation_reports_created_idx ON reconciliation_reports (created_at DESC);
+
+-- закрытые платежи за окно сверки ищутся по времени смены статуса
+CREATE INDEX payments_complete_updated_idx ON payments (updated_at)
+WHERE
+	status = 'COMPLETE';
+
+-- +goose Down
+DROP INDEX IF EXISTS payments_complete_updated_idx;
+
+DROP TABLE IF EXISTS reconciliation_reports;
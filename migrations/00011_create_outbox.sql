@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE outbox_events (
+	seq bigserial PRIMARY KEY,
+	id uuid NOT NULL UNIQUE,
+	event_type varchar(50) NOT NULL,
+	payment_id uuid NOT NULL,
+	payload jsonb NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	published_at timestamptz,
+	attempts integer NOT NULL DEFAULT 0,
+	last_error text NOT NULL DEFAULT ''
+);
+
+-- релей забирает неопубликованные события по порядку записи
+CREATE INDEX outbox_events_unpublished_idx ON outbox_events (seq)
+WHERE
+	published_at IS NULL;
+
+-- очистка опубликованных событий старше срока хранения
+CREATE INDEX outbox_events_published_idx ON outbox_events (published_at)
+WHERE
+	published_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_events;
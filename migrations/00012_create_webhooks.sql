@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints (
+	id uuid PRIMARY KEY,
+	user_id uuid NOT NULL,
+	url text NOT NULL,
+	secret varchar(100) NOT NULL,
+	event_types text[] NOT NULL DEFAULT '{}',
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_endpoints_user_idx ON webhook_endpoints (user_id);
+
+CREATE TABLE webhook_deliveries (
+	id uuid PRIMARY KEY,
+	endpoint_id uuid NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+	event_id uuid NOT NULL,
+	event_type varchar(50) NOT NULL,
+	payment_id uuid NOT NULL,
+	payload jsonb NOT NULL,
+	occurred_at timestamptz NOT NULL,
+	status varchar(20) NOT NULL DEFAULT 'PENDING',
+	attempts integer NOT NULL DEFAULT 0,
+	last_status_code integer NOT NULL DEFAULT 0,
+	last_error text NOT NULL DEFAULT '',
+	next_attempt_at timestamptz NOT NULL DEFAULT NOW(),
+	delivered_at timestamptz,
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	updated_at timestamptz NOT NULL DEFAULT NOW(),
+	UNIQUE (endpoint_id, event_id)
+);
+
+-- диспетчер забирает доставки, подошедшие к следующей попытке
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+WHERE
+	status = 'PENDING';
+
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+
+DROP TABLE IF EXISTS webhook_endpoints;
@@ -0,0 +1,74 @@
+-- +goose Up
+CREATE TABLE ledger_accounts (
+	code varchar(255) PRIMARY KEY, -- user:<id>, platform:holding, platform:fees, provider:<имя>:clearing
+	kind varchar(20) NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE ledger_entries (
+	id uuid PRIMARY KEY,
+	kind varchar(30) NOT NULL,
+	payment_id uuid NOT NULL,
+	refund_id uuid,
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ledger_entries_payment_idx ON ledger_entries (payment_id);
+
+-- положительная сумма - дебет, отрицательная - кредит
+CREATE TABLE ledger_postings (
+	id bigserial PRIMARY KEY,
+	entry_id uuid NOT NULL REFERENCES ledger_entries (id),
+	account varchar(255) NOT NULL REFERENCES ledger_accounts (code),
+	amount_minor bigint NOT NULL CHECK (amount_minor <> 0),
+	currency varchar(3) NOT NULL
+);
+
+CREATE INDEX ledger_postings_entry_idx ON ledger_postings (entry_id);
+
+CREATE INDEX ledger_postings_account_idx ON ledger_postings (account, currency);
+
+-- проводки неизменяемы, ошибки исправляются новыми проводками
+-- +goose StatementBegin
+CREATE FUNCTION ledger_reject_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'ledger is append-only: % on % is not allowed', TG_OP, TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER ledger_entries_immutable BEFORE UPDATE OR DELETE ON ledger_entries
+FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+
+CREATE TRIGGER ledger_postings_immutable BEFORE UPDATE OR DELETE ON ledger_postings
+FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+
+-- на коммите сумма строк каждой проводки в каждой валюте должна быть нулевой
+-- +goose StatementBegin
+CREATE FUNCTION ledger_check_balance() RETURNS trigger AS $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM ledger_postings WHERE entry_id = NEW.entry_id
+		GROUP BY currency HAVING SUM(amount_minor) <> 0
+	) THEN
+		RAISE EXCEPTION 'ledger entry % is unbalanced', NEW.entry_id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced AFTER INSERT ON ledger_postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION ledger_check_balance();
+
+-- +goose Down
+DROP TABLE IF EXISTS ledger_postings;
+
+DROP TABLE IF EXISTS ledger_entries;
+
+DROP TABLE IF EXISTS ledger_accounts;
+
+DROP FUNCTION IF EXISTS ledger_check_balance();
+
+DROP FUNCTION IF EXISTS ledger_reject_change();
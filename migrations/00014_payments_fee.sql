@@ -0,0 +1,6 @@
+-- +goose Up
+-- комиссия платформы в минорных единицах валюты платежа
+ALTER TABLE payments ADD COLUMN fee_minor bigint NOT NULL DEFAULT 0 CHECK (fee_minor >= 0 AND fee_minor <= amount_minor);
+
+-- +goose Down
+ALTER TABLE payments DROP COLUMN IF EXISTS fee_minor;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE risk_decisions (
+	id uuid PRIMARY KEY,
+	from_user_id uuid NOT NULL,
+	to_user_id uuid NOT NULL,
+	amount_minor bigint NOT NULL,
+	currency varchar(3) NOT NULL,
+	decision varchar(10) NOT NULL,
+	rule varchar(50) NOT NULL DEFAULT '',
+	reason text NOT NULL DEFAULT '',
+	payment_id uuid,
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX risk_decisions_from_user_idx ON risk_decisions (from_user_id, created_at DESC);
+
+-- очередь ручной проверки
+CREATE INDEX risk_decisions_review_idx ON risk_decisions (created_at)
+WHERE
+	decision = 'review';
+
+-- +goose Down
+DROP TABLE IF EXISTS risk_decisions;
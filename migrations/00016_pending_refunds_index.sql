@@ -0,0 +1,8 @@
+-- +goose Up
+-- возвраты с неизвестным исходом, которые дозавершает проверка по истории шлюза
+CREATE INDEX refunds_pending_idx ON refunds (created_at)
+WHERE
+	status = 'PENDING';
+
+-- +goose Down
+DROP INDEX IF EXISTS refunds_pending_idx;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- ключ идемпотентности уникален в пределах пользователя, а не всего сервиса.
+-- Пустой user_id - запросы без пользователя (внутренние вызовы)
+ALTER TABLE idempotency_keys ADD COLUMN user_id varchar(64) NOT NULL DEFAULT '';
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (operation, user_id, key);
+
+-- +goose Down
+-- ключи разных пользователей могут совпадать, а живут ключи IDEMPOTENCY_TTL, поэтому пользовательские ключи удаляются
+DELETE FROM idempotency_keys
+WHERE user_id <> '';
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (operation, key);
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS user_id;
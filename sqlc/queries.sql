@@ -1,6 +1,6 @@
 -- name: CreatePayment :one
-INSERT INTO payments (id, from_user_id, to_user_id, amount, currency, status)
-	VALUES ($1, $2, $3, $4, $5, 'PENDING')
+INSERT INTO payments (id, from_user_id, to_user_id, amount_minor, currency, fee_minor, status, provider)
+	VALUES ($1, $2, $3, $4, $5, $6, 'PENDING', $7)
 RETURNING
 	id;
 
@@ -12,27 +12,498 @@ FROM
 WHERE
 	id = $1;
 
--- name: RefundPayment :exec
+-- name: RefundPayment :execrows
 UPDATE
 	payments
 SET
 	status = 'REFUNDED'
 WHERE
-	id = $1;
+	id = $1
+	AND status IN ('SUCCESS', 'COMPLETE');
 
 -- name: GetPaymentHistory :many
+-- фильтры по статусу, валюте, сумме и дате добавляются в репозитории только при наличии
 SELECT
 	*
 FROM
 	payments
+WHERE (from_user_id = $1
+	OR to_user_id = $1)
+AND (created_at, id) < ($2, $3)
+ORDER BY
+	created_at DESC,
+	id DESC
+LIMIT $4;
+
+-- name: CountPaymentHistory :one
+SELECT
+	count(*)
+FROM
+	payments
 WHERE
 	from_user_id = $1
-LIMIT $2 OFFSET $3;
+	OR to_user_id = $1;
+
+-- name: UpdatePaymentStatus :execrows
+UPDATE
+	payments
+SET
+	status = $1,
+	updated_at = $2
+WHERE
+	id = $3
+	AND status = $4;
+
+-- name: ReserveIdempotencyKey :one
+INSERT INTO idempotency_keys (operation, user_id, key, fingerprint, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (operation, user_id, key)
+	DO UPDATE SET
+		fingerprint = EXCLUDED.fingerprint, response = NULL, completed = FALSE, created_at = EXCLUDED.created_at
+	WHERE
+		idempotency_keys.created_at < $6
+		OR (NOT idempotency_keys.completed
+			AND idempotency_keys.created_at < $7)
+	RETURNING
+		key;
+
+-- name: GetIdempotencyKey :one
+SELECT
+	fingerprint,
+	response,
+	completed
+FROM
+	idempotency_keys
+WHERE
+	operation = $1
+	AND user_id = $2
+	AND key = $3;
+
+-- name: CompleteIdempotencyKey :one
+UPDATE
+	idempotency_keys
+SET
+	response = $1,
+	completed = TRUE
+WHERE
+	operation = $2
+	AND user_id = $3
+	AND key = $4
+RETURNING
+	fingerprint;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE operation = $1
+	AND user_id = $2
+	AND key = $3
+	AND NOT completed;
 
--- name: UpdatePaymentStatus :exec
+-- name: LockPaymentForRefund :one
+SELECT
+	amount_minor,
+	currency,
+	status
+FROM
+	payments
+WHERE
+	id = $1
+FOR UPDATE;
+
+-- name: SumRefunds :one
+SELECT
+	COALESCE(SUM(amount_minor), 0)
+FROM
+	refunds
+WHERE
+	payment_id = $1
+	AND status <> $2;
+
+-- name: CreateRefund :one
+INSERT INTO refunds (id, payment_id, amount_minor, currency, reason, status, initiator_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING
+	*;
+
+-- name: CompleteRefund :one
 UPDATE
+	refunds
+SET
+	status = $1,
+	provider_reference = $2,
+	updated_at = $3
+WHERE
+	id = $4
+	AND status = $5
+RETURNING
+	*;
+
+-- name: MarkPaymentRefunded :execrows
+UPDATE
+	payments
+SET
+	status = $1,
+	updated_at = $2
+WHERE
+	id = $3
+	AND status IN ($4, $5)
+	AND amount_minor <= (
+		SELECT
+			COALESCE(SUM(amount_minor), 0)
+		FROM
+			refunds
+		WHERE
+			payment_id = $3
+			AND status = $6);
+
+-- name: ListRefunds :many
+SELECT
+	*
+FROM
+	refunds
+WHERE
+	payment_id = $1
+ORDER BY
+	created_at;
+
+-- name: InsertPaymentEvent :exec
+INSERT INTO payment_events (payment_id, old_status, new_status, actor, reason, provider_response)
+	VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetPaymentEvents :many
+SELECT
+	*
+FROM
+	payment_events
+WHERE
+	payment_id = $1
+ORDER BY
+	id;
+
+-- name: AddDeadLetter :exec
+INSERT INTO payment_dead_letters (payment_id, stage, last_error, attempts)
+	VALUES ($1, $2, $3, $4)
+ON CONFLICT (payment_id)
+	DO UPDATE SET
+		stage = EXCLUDED.stage, last_error = EXCLUDED.last_error, attempts = EXCLUDED.attempts, updated_at = NOW();
+
+-- name: ListDeadLetters :many
+SELECT
+	*
+FROM
+	payment_dead_letters
+ORDER BY
+	updated_at DESC,
+	payment_id
+LIMIT $1;
+
+-- name: DeleteDeadLetter :execrows
+DELETE FROM payment_dead_letters
+WHERE payment_id = $1;
+
+-- name: GetExpiredPayments :many
+SELECT
+	*
+FROM
+	payments
+WHERE
+	status IN ('PENDING', 'FAILED')
+	AND created_at < $1
+ORDER BY
+	created_at
+LIMIT $2;
+
+-- name: GetPaymentsByIDs :many
+SELECT
+	*
+FROM
+	payments
+WHERE
+	id = ANY ($1::uuid[]);
+
+-- name: GetCompletedPayments :many
+SELECT
+	*
+FROM
 	payments
+WHERE
+	status = 'COMPLETE'
+	AND provider = $1
+	AND updated_at >= $2
+	AND updated_at < $3;
+
+-- name: GetRefundIDs :many
+SELECT
+	id
+FROM
+	refunds
+WHERE
+	id = ANY ($1::uuid[]);
+
+-- name: GetRefundedAmounts :many
+SELECT
+	payment_id,
+	SUM(amount_minor)
+FROM
+	refunds
+WHERE
+	payment_id = ANY ($1::uuid[])
+	AND status <> $2
+GROUP BY
+	payment_id;
+
+-- name: SaveReconciliationReport :one
+INSERT INTO reconciliation_reports (id, provider, window_from, window_to, operations, auto_fix, mismatches)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING
+	id, created_at;
+
+-- name: GetReconciliationReport :one
+SELECT
+	*
+FROM
+	reconciliation_reports
+WHERE
+	id = $1;
+
+-- name: ListReconciliationReports :many
+SELECT
+	*
+FROM
+	reconciliation_reports
+ORDER BY
+	created_at DESC,
+	id
+LIMIT $1;
+
+-- name: InsertOutboxEvent :exec
+INSERT INTO outbox_events (id, event_type, payment_id, payload)
+	VALUES ($1, $2, $3, $4);
+
+-- name: GetUnpublishedOutboxEvents :many
+SELECT
+	seq,
+	id,
+	event_type,
+	payment_id,
+	payload,
+	created_at
+FROM
+	outbox_events
+WHERE
+	published_at IS NULL
+ORDER BY
+	seq
+LIMIT $1
+FOR UPDATE
+	SKIP LOCKED;
+
+-- name: MarkOutboxEventsPublished :exec
+UPDATE
+	outbox_events
+SET
+	published_at = NOW()
+WHERE
+	seq = ANY ($1::bigint[]);
+
+-- name: FailOutboxEvent :exec
+UPDATE
+	outbox_events
+SET
+	attempts = attempts + 1,
+	last_error = $1
+WHERE
+	seq = $2;
+
+-- name: DeletePublishedOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE published_at < $1;
+
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, user_id, url, secret, event_types)
+	VALUES ($1, $2, $3, $4, $5)
+RETURNING
+	id, created_at;
+
+-- name: GetWebhookEndpoint :one
+SELECT
+	id,
+	user_id,
+	url,
+	secret,
+	event_types,
+	created_at
+FROM
+	webhook_endpoints
+WHERE
+	id = $1;
+
+-- name: ListWebhookEndpoints :many
+SELECT
+	id,
+	user_id,
+	url,
+	secret,
+	event_types,
+	created_at
+FROM
+	webhook_endpoints
+WHERE
+	user_id = $1
+ORDER BY
+	created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: InsertWebhookDeliveries :exec
+INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payment_id, payload, occurred_at)
+SELECT
+	gen_random_uuid(),
+	e.id,
+	$1,
+	$2,
+	p.id,
+	$4,
+	$5
+FROM
+	webhook_endpoints e
+	JOIN payments p ON p.id = $3
+WHERE
+	e.user_id IN (p.from_user_id, p.to_user_id)
+	AND (cardinality(e.event_types) = 0
+		OR $2 = ANY (e.event_types));
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE
+	webhook_deliveries d
 SET
-	status = $1
+	next_attempt_at = NOW() + $2 * interval '1 millisecond'
+FROM
+	webhook_endpoints e
+WHERE
+	e.id = d.endpoint_id
+	AND d.id IN (
+		SELECT
+			id
+		FROM
+			webhook_deliveries
+		WHERE
+			status = 'PENDING'
+			AND next_attempt_at <= NOW()
+		ORDER BY
+			next_attempt_at
+		LIMIT $1
+		FOR UPDATE
+			SKIP LOCKED)
+RETURNING
+	d.*,
+	e.url,
+	e.secret;
+
+-- name: SaveWebhookAttempt :exec
+UPDATE
+	webhook_deliveries
+SET
+	status = $1,
+	attempts = $2,
+	last_status_code = $3,
+	last_error = $4,
+	next_attempt_at = $5,
+	delivered_at = $6,
+	updated_at = NOW()
+WHERE
+	id = $7;
+
+-- name: ListWebhookDeliveries :many
+SELECT
+	*
+FROM
+	webhook_deliveries
+WHERE
+	endpoint_id = $1
+	AND ($2 = ''
+		OR status = $2)
+ORDER BY
+	created_at DESC,
+	id
+LIMIT $3;
+
+-- name: RedeliverWebhook :one
+UPDATE
+	webhook_deliveries
+SET
+	status = 'PENDING',
+	attempts = 0,
+	next_attempt_at = NOW(),
+	updated_at = NOW()
+WHERE
+	id = $1
+	AND status = 'FAILED'
+RETURNING
+	*;
+
+-- name: CreateLedgerAccount :exec
+INSERT INTO ledger_accounts (code, kind)
+	VALUES ($1, $2)
+ON CONFLICT
+	DO NOTHING;
+
+-- name: CreateLedgerEntry :one
+INSERT INTO ledger_entries (id, kind, payment_id, refund_id)
+	VALUES ($1, $2, $3, $4)
+RETURNING
+	created_at;
+
+-- name: CreateLedgerPosting :exec
+INSERT INTO ledger_postings (entry_id, account, amount_minor, currency)
+	VALUES ($1, $2, $3, $4);
+
+-- name: GetLedgerBalances :many
+SELECT
+	currency,
+	SUM(amount_minor)
+FROM
+	ledger_postings
 WHERE
-	id = $2;
+	account = $1
+GROUP BY
+	currency
+ORDER BY
+	currency;
+
+-- name: GetPaymentAccountSum :one
+SELECT
+	COALESCE(SUM(p.amount_minor), 0)
+FROM
+	ledger_postings p
+	JOIN ledger_entries e ON e.id = p.entry_id
+WHERE
+	e.payment_id = $1
+	AND p.account = $2
+	AND p.currency = $3;
+
+-- name: GetRiskPaymentStats :one
+SELECT
+	COUNT(*),
+	COALESCE(SUM(amount_minor) FILTER (WHERE currency = $2), 0)
+FROM
+	payments
+WHERE
+	from_user_id = $1
+	AND created_at >= $3;
+
+-- name: GetFirstPaidPayment :one
+SELECT
+	MIN(created_at)
+FROM
+	payments
+WHERE
+	from_user_id = $1
+	AND to_user_id = $2
+	AND status IN ($3, $4);
+
+-- name: CreateRiskDecision :exec
+INSERT INTO risk_decisions (id, from_user_id, to_user_id, amount_minor, currency, decision, rule, reason, payment_id, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);